@@ -0,0 +1,64 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// SpreadExpression is `...value`, which expands the elements of value into
+// the surrounding argument list or array literal.
+type SpreadExpression struct {
+	Token token.Token // the `...` token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadExpression) End() token.Position {
+	if se.Value != nil {
+		return se.Value.End()
+	}
+	return se.Token.End
+}
+func (se *SpreadExpression) String() string { return "..." + se.Value.String() }
+
+// KeywordArgument is a `name = value` argument in a call.
+type KeywordArgument struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (ka *KeywordArgument) expressionNode()      {}
+func (ka *KeywordArgument) TokenLiteral() string { return ka.Name.TokenLiteral() }
+func (ka *KeywordArgument) Pos() token.Position  { return ka.Name.Pos() }
+func (ka *KeywordArgument) End() token.Position {
+	if ka.Value != nil {
+		return ka.Value.End()
+	}
+	return ka.Name.End()
+}
+func (ka *KeywordArgument) String() string {
+	return ka.Name.String() + " = " + ka.Value.String()
+}
+
+// FormatParameters formats a parameter list as it appears in source, e.g.
+// `x, y = 10, ...rest`. defaults is either empty or parallel to params.
+func FormatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	var out []string
+
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			out = append(out, p.String()+" = "+defaults[i].String())
+			continue
+		}
+		out = append(out, p.String())
+	}
+
+	if rest != nil {
+		out = append(out, "..."+rest.String())
+	}
+
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,43 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// AssignStatement updates an existing binding or an element of an array or
+// hash, as in `x = 1`, `total += n`, `arr[i] = v` or `h.key = v`.
+type AssignStatement struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression  // an *Identifier, *IndexExpression or *SelectorExpression
+	Operator string      // "=", "+=", "-=", "*=" or "/="
+	Value    Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Pos() token.Position {
+	if as.Target != nil {
+		return as.Target.Pos()
+	}
+	return as.Token.Pos
+}
+func (as *AssignStatement) End() token.Position {
+	if as.Value != nil {
+		return as.Value.End()
+	}
+	return as.Token.End
+}
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(as.Target.String())
+	out.WriteString(" " + as.Operator + " ")
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
@@ -10,6 +10,11 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+
+	// Pos returns the position of the first character belonging to the node
+	// and End the position immediately after it.
+	Pos() token.Position
+	End() token.Position
 }
 
 type Statement interface {
@@ -34,6 +39,22 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if n := len(p.Statements); n > 0 {
+		return p.Statements[n-1].End()
+	}
+
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -45,8 +66,10 @@ func (p *Program) String() string {
 }
 
 type LetStatement struct {
-	Token      token.Token
-	Name       *Identifier
+	Token token.Token
+	Name  *Identifier
+	// Type is the annotation of the variable, if any, as in `let x: int`.
+	Type       TypeExpression
 	Expression Expression
 }
 
@@ -54,11 +77,27 @@ func (s *LetStatement) statementNode() {}
 func (s *LetStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
+func (s *LetStatement) Pos() token.Position { return s.Token.Pos }
+func (s *LetStatement) End() token.Position {
+	if s.Expression != nil {
+		return s.Expression.End()
+	}
+	if s.Type != nil {
+		return s.Type.End()
+	}
+	if s.Name != nil {
+		return s.Name.End()
+	}
+	return s.Token.End
+}
 func (s *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(s.TokenLiteral() + " ")
 	out.WriteString(s.Name.String())
+	if s.Type != nil {
+		out.WriteString(": " + s.Type.String())
+	}
 	if s.Expression != nil {
 		out.WriteString(" = ")
 
@@ -75,12 +114,22 @@ func (s *LetStatement) String() string {
 type ReturnStatement struct {
 	Token       token.Token
 	ReturnValue Expression
+	// TailCall is set when ReturnValue is a call in tail position, whose
+	// frame may replace the frame of the returning function.
+	TailCall bool
 }
 
 func (s *ReturnStatement) statementNode() {}
 func (s *ReturnStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
+func (s *ReturnStatement) Pos() token.Position { return s.Token.Pos }
+func (s *ReturnStatement) End() token.Position {
+	if s.ReturnValue != nil {
+		return s.ReturnValue.End()
+	}
+	return s.Token.End
+}
 func (s *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -104,6 +153,13 @@ func (s *ExpressionStatement) statementNode() {}
 func (s *ExpressionStatement) TokenLiteral() string {
 	return s.Token.Literal
 }
+func (s *ExpressionStatement) Pos() token.Position { return s.Token.Pos }
+func (s *ExpressionStatement) End() token.Position {
+	if s.Expression != nil {
+		return s.Expression.End()
+	}
+	return s.Token.End
+}
 func (s *ExpressionStatement) String() string {
 	if s.Expression != nil {
 		return s.Expression.String()
@@ -114,10 +170,25 @@ func (s *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the `{` token
 	Statements []Statement
+	Rbrace     token.Token // the `}` token
+	// Locals names the slots of the environment the block runs in, if it
+	// is the body of a function or loop or a catch clause. The resolver
+	// sets it.
+	Locals []string
 }
 
 func (s *BlockStatement) statementNode()       {}
 func (s *BlockStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *BlockStatement) Pos() token.Position  { return s.Token.Pos }
+func (s *BlockStatement) End() token.Position {
+	if s.Rbrace.End.IsValid() {
+		return s.Rbrace.End
+	}
+	if n := len(s.Statements); n > 0 {
+		return s.Statements[n-1].End()
+	}
+	return s.Token.End
+}
 func (s *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -136,6 +207,13 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -156,6 +234,18 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -176,6 +266,8 @@ type BooleanLiteral struct {
 func (bl *BooleanLiteral) expressionNode()      {}
 func (bl *BooleanLiteral) TokenLiteral() string { return bl.Token.Literal }
 func (bl *BooleanLiteral) String() string       { return bl.Token.Literal }
+func (bl *BooleanLiteral) Pos() token.Position  { return bl.Token.Pos }
+func (bl *BooleanLiteral) End() token.Position  { return bl.Token.End }
 
 type IntegerLiteral struct {
 	Token token.Token
@@ -185,6 +277,19 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
 
 type StringLiteral struct {
 	Token token.Token
@@ -193,7 +298,9 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *StringLiteral) String() string       { return sl.Value }
+func (sl *StringLiteral) String() string       { return token.Quote(sl.Value) }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
 
 type IfExpression struct {
 	Token       token.Token // the `if` token
@@ -204,6 +311,19 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	if ie.Condition != nil {
+		return ie.Condition.End()
+	}
+	return ie.Token.End
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -223,17 +343,46 @@ func (ie *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // the `fn` token
 	Parameters []*Identifier
+	// Defaults holds the default value of each parameter, or nil for
+	// parameters without one. It is empty when no parameter has a default.
+	Defaults []Expression
+	// Rest is the `...name` parameter collecting any extra arguments.
+	Rest *Identifier
+	// ParameterTypes holds the annotation of each parameter, or nil for
+	// parameters without one. It is empty when no parameter is annotated.
+	ParameterTypes []TypeExpression
+	// RestType annotates each of the arguments Rest collects, so
+	// `...rest: int` makes rest an array of integers.
+	RestType TypeExpression
+	// ReturnType is the `-> T` annotation of the result, if any.
+	ReturnType TypeExpression
 	Body       *BlockStatement
+	// Name is the name of the let binding the literal is assigned to, if
+	// any. It is only used to display the function.
+	Name string
 }
 
 type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    token.Token // The ')' token
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position {
+	if ce.Function != nil {
+		return ce.Function.Pos()
+	}
+	return ce.Token.Pos
+}
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.End.IsValid() {
+		return ce.Rparen.End
+	}
+	return ce.Token.End
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -252,18 +401,23 @@ func (ce *CallExpression) String() string {
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
-	var params []string
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(formatTypedParameters(fl))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -272,8 +426,21 @@ func (fl *FunctionLiteral) String() string {
 type Identifier struct {
 	Token token.Token
 	Value string
+	// Binding is where the resolver found the variable the identifier
+	// names. It is nil for variables of the top level of a program, which
+	// are looked up by name.
+	Binding *Binding
+}
+
+// Binding locates a variable: it is in slot Slot of the environment Depth
+// levels out from the one the identifier is evaluated in.
+type Binding struct {
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string       { return i.Value }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
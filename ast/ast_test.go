@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/threeaccents/digolang/token"
@@ -27,3 +28,54 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestStringLiteralString(t *testing.T) {
+	lit := &StringLiteral{
+		Token: token.Token{Type: token.STRING, Literal: "say \"hi\"\n"},
+		Value: "say \"hi\"\n",
+	}
+
+	if lit.String() != `"say \"hi\"\n"` {
+		t.Errorf("lit.String() wrong. got=%q", lit.String())
+	}
+}
+
+func TestInspect(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  &Identifier{Value: "x"},
+				Expression: &FunctionLiteral{
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{Expression: &Identifier{Value: "y"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var all, outside []string
+	Inspect(program, func(n Node) bool {
+		if ident, ok := n.(*Identifier); ok {
+			all = append(all, ident.Value)
+		}
+		return true
+	})
+	Inspect(program, func(n Node) bool {
+		if ident, ok := n.(*Identifier); ok {
+			outside = append(outside, ident.Value)
+		}
+		_, ok := n.(*FunctionLiteral)
+		return !ok
+	})
+
+	if strings.Join(all, ",") != "x,y" {
+		t.Errorf("wrong identifiers. got=%v", all)
+	}
+	if strings.Join(outside, ",") != "x" {
+		t.Errorf("wrong identifiers outside functions. got=%v", outside)
+	}
+}
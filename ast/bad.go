@@ -0,0 +1,28 @@
+package ast
+
+import "github.com/threeaccents/digolang/token"
+
+// BadExpression stands in for an expression that failed to parse so that
+// partially parsed nodes keep a non-nil child.
+type BadExpression struct {
+	Token token.Token // the token at which parsing failed
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) Pos() token.Position  { return be.Token.Pos }
+func (be *BadExpression) End() token.Position  { return be.Token.End }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
+// BadStatement covers the tokens skipped while recovering from a statement
+// that could not be parsed at all.
+type BadStatement struct {
+	From token.Token
+	To   token.Token
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.From.Literal }
+func (bs *BadStatement) Pos() token.Position  { return bs.From.Pos }
+func (bs *BadStatement) End() token.Position  { return bs.To.End }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
@@ -0,0 +1,289 @@
+package ast
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// Format returns node as source code, one statement per line with blocks
+// indented by tabs. Unlike String, which shows how the tree is structured,
+// its result parses back to the same tree. Every prefix and infix
+// expression is put in parentheses.
+func Format(node Node) string {
+	f := &formatter{}
+
+	switch n := node.(type) {
+	case *Program:
+		f.statements(n.Statements)
+	case Statement:
+		f.statement(n)
+	case Expression:
+		f.expression(n)
+	default:
+		f.write(node.String())
+	}
+
+	return f.out.String()
+}
+
+type formatter struct {
+	out    strings.Builder
+	indent int
+}
+
+func (f *formatter) write(s string) {
+	f.out.WriteString(s)
+}
+
+func (f *formatter) statements(stmts []Statement) {
+	for _, s := range stmts {
+		f.write(strings.Repeat("\t", f.indent))
+		f.statement(s)
+		f.write("\n")
+	}
+}
+
+func (f *formatter) block(b *BlockStatement) {
+	if b == nil || len(b.Statements) == 0 {
+		f.write("{}")
+		return
+	}
+
+	f.write("{\n")
+	f.indent++
+	f.statements(b.Statements)
+	f.indent--
+	f.write(strings.Repeat("\t", f.indent) + "}")
+}
+
+// statement writes stmt. Statements other than loops end in a semicolon, as
+// an expression continues onto the next line otherwise.
+func (f *formatter) statement(stmt Statement) {
+	switch s := stmt.(type) {
+	case *LetStatement:
+		f.write("let " + s.Name.Value)
+		if s.Type != nil {
+			f.write(": " + s.Type.String())
+		}
+		if s.Expression != nil {
+			f.write(" = ")
+			f.expression(s.Expression)
+		}
+		f.write(";")
+	case *ExportStatement:
+		f.write("export ")
+		f.statement(s.Statement)
+	case *ReturnStatement:
+		f.write("return")
+		if s.ReturnValue != nil {
+			f.write(" ")
+			f.expression(s.ReturnValue)
+		}
+		f.write(";")
+	case *ExpressionStatement:
+		if s.Expression != nil {
+			f.expression(s.Expression)
+		}
+		f.write(";")
+	case *AssignStatement:
+		f.expression(s.Target)
+		f.write(" " + s.Operator + " ")
+		f.expression(s.Value)
+		f.write(";")
+	case *ThrowStatement:
+		f.write("throw ")
+		f.expression(s.Value)
+		f.write(";")
+	case *WhileStatement:
+		f.write("while (")
+		f.expression(s.Condition)
+		f.write(") ")
+		f.block(s.Body)
+	case *ForStatement:
+		f.write("for (" + s.Variable.Value + " in ")
+		f.expression(s.Iterable)
+		f.write(") ")
+		f.block(s.Body)
+	case *BlockStatement:
+		f.block(s)
+	default:
+		f.write(stmt.String())
+	}
+}
+
+func (f *formatter) expression(expr Expression) {
+	switch e := expr.(type) {
+	case *IntegerLiteral:
+		f.write(formatInteger(e))
+	case *FloatLiteral:
+		if strings.HasPrefix(e.Token.Literal, "-") {
+			f.write("(" + e.Token.Literal + ")")
+		} else {
+			f.write(e.Token.Literal)
+		}
+	case *InterpolatedString:
+		f.write(`"`)
+		for i, s := range e.Strings {
+			quoted := token.Quote(s)
+			f.write(quoted[1 : len(quoted)-1])
+			if i < len(e.Expressions) {
+				f.write("${")
+				f.expression(e.Expressions[i])
+				f.write("}")
+			}
+		}
+		f.write(`"`)
+	case *PrefixExpression:
+		f.write("(" + e.Operator)
+		f.expression(e.Right)
+		f.write(")")
+	case *InfixExpression:
+		f.write("(")
+		f.expression(e.Left)
+		f.write(" " + e.Operator + " ")
+		f.expression(e.Right)
+		f.write(")")
+	case *IfExpression:
+		f.write("if (")
+		f.expression(e.Condition)
+		f.write(") ")
+		f.block(e.Consequence)
+		if e.Alternative != nil {
+			f.write(" else ")
+			f.block(e.Alternative)
+		}
+	case *TryExpression:
+		f.write("try ")
+		f.block(e.Block)
+		if e.Catch != nil {
+			f.write(" catch ")
+			if e.Param != nil {
+				f.write("(" + e.Param.Value + ") ")
+			}
+			f.block(e.Catch)
+		}
+		if e.Finally != nil {
+			f.write(" finally ")
+			f.block(e.Finally)
+		}
+	case *FunctionLiteral:
+		f.write("fn(")
+		f.parameters(e)
+		f.write(") ")
+		if e.ReturnType != nil {
+			f.write("-> " + e.ReturnType.String() + " ")
+		}
+		f.block(e.Body)
+	case *CallExpression:
+		f.operand(e.Function)
+		f.write("(")
+		f.list(e.Arguments)
+		f.write(")")
+	case *ArrayLiteral:
+		f.write("[")
+		f.list(e.Elements)
+		f.write("]")
+	case *HashLiteral:
+		f.write("{")
+		for i, key := range e.Keys {
+			if i > 0 {
+				f.write(", ")
+			}
+			f.expression(key)
+			f.write(": ")
+			f.expression(e.Pairs[key])
+		}
+		f.write("}")
+	case *IndexExpression:
+		f.operand(e.Left)
+		f.write("[")
+		f.expression(e.Index)
+		f.write("]")
+	case *SliceExpression:
+		f.operand(e.Left)
+		f.write("[")
+		if e.Low != nil {
+			f.expression(e.Low)
+		}
+		f.write(":")
+		if e.High != nil {
+			f.expression(e.High)
+		}
+		f.write("]")
+	case *SelectorExpression:
+		f.operand(e.Left)
+		f.write("." + e.Selector.Value)
+	case *SpreadExpression:
+		f.write("...")
+		f.expression(e.Value)
+	case *KeywordArgument:
+		f.write(e.Name.Value + " = ")
+		f.expression(e.Value)
+	default:
+		f.write(expr.String())
+	}
+}
+
+// operand writes the expression a call, index or selector applies to, in
+// parentheses if it would otherwise take the operation into its last block.
+func (f *formatter) operand(expr Expression) {
+	switch expr.(type) {
+	case *IfExpression, *TryExpression, *FunctionLiteral:
+		f.write("(")
+		f.expression(expr)
+		f.write(")")
+	default:
+		f.expression(expr)
+	}
+}
+
+func (f *formatter) list(exprs []Expression) {
+	for i, e := range exprs {
+		if i > 0 {
+			f.write(", ")
+		}
+		f.expression(e)
+	}
+}
+
+func (f *formatter) parameters(fl *FunctionLiteral) {
+	for i, p := range fl.Parameters {
+		if i > 0 {
+			f.write(", ")
+		}
+		f.write(p.Value)
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			f.write(": " + fl.ParameterTypes[i].String())
+		}
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			f.write(" = ")
+			f.expression(fl.Defaults[i])
+		}
+	}
+
+	if fl.Rest != nil {
+		if len(fl.Parameters) > 0 {
+			f.write(", ")
+		}
+		f.write("..." + fl.Rest.Value)
+		if fl.RestType != nil {
+			f.write(": " + fl.RestType.String())
+		}
+	}
+}
+
+// formatInteger writes a literal, which the optimizer may have made
+// negative, so that it reads back as the same value: the parser only reads
+// non-negative literals, to which a minus is applied.
+func formatInteger(il *IntegerLiteral) string {
+	switch {
+	case il.Value == math.MinInt64:
+		return "((-9223372036854775807) - 1)"
+	case il.Value < 0:
+		return "(" + strconv.FormatInt(il.Value, 10) + ")"
+	}
+	return il.Token.Literal
+}
@@ -7,13 +7,26 @@ import (
 )
 
 type IndexExpression struct {
-	Token token.Token // the `[` token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the `[` token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // the `]` token
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position {
+	if ie.Left != nil {
+		return ie.Left.Pos()
+	}
+	return ie.Token.Pos
+}
+func (ie *IndexExpression) End() token.Position {
+	if ie.Rbracket.End.IsValid() {
+		return ie.Rbracket.End
+	}
+	return ie.Token.End
+}
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -25,3 +38,44 @@ func (ie *IndexExpression) String() string {
 
 	return out.String()
 }
+
+// SliceExpression is `left[low:high]`. Low and High are nil when omitted.
+type SliceExpression struct {
+	Token    token.Token // the `[` token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Token // the `]` token
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position {
+	if se.Left != nil {
+		return se.Left.Pos()
+	}
+	return se.Token.Pos
+}
+func (se *SliceExpression) End() token.Position {
+	if se.Rbracket.End.IsValid() {
+		return se.Rbracket.End
+	}
+	return se.Token.End
+}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
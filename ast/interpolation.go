@@ -0,0 +1,49 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// InterpolatedString is a string literal with embedded `${ ... }`
+// expressions. Strings holds the literal text around the expressions, so it
+// always has one more element than Expressions.
+type InterpolatedString struct {
+	Token       token.Token // the STRING_HEAD token
+	Strings     []string
+	Expressions []Expression
+	Tail        token.Token // the STRING_TAIL token
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position {
+	if is.Tail.End.IsValid() {
+		return is.Tail.End
+	}
+	if n := len(is.Expressions); n > 0 {
+		return is.Expressions[n-1].End()
+	}
+	return is.Token.End
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for i, s := range is.Strings {
+		if i > 0 {
+			out.WriteString("}")
+		}
+		quoted := token.Quote(s)
+		out.WriteString(quoted[1 : len(quoted)-1])
+		if i < len(is.Expressions) {
+			out.WriteString("${")
+			out.WriteString(is.Expressions[i].String())
+		}
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
@@ -0,0 +1,111 @@
+package ast
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// ImportStatement is either `import "path" as name;`, which binds the whole
+// module to Alias, or `import { a, b } from "path";`, which binds the listed
+// exports directly.
+type ImportStatement struct {
+	Token token.Token // the `import` token
+	Path  *StringLiteral
+	Alias *Identifier
+	Names []*Identifier
+	// Last is the final token of the statement.
+	Last token.Token
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImportStatement) End() token.Position {
+	if is.Last.End.IsValid() {
+		return is.Last.End
+	}
+	return is.Token.End
+}
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("import ")
+
+	if is.Alias != nil || len(is.Names) == 0 {
+		out.WriteString(is.Path.String())
+		if is.Alias != nil {
+			out.WriteString(" as " + is.Alias.String())
+		}
+	} else {
+		var names []string
+		for _, n := range is.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString("{ " + strings.Join(names, ", ") + " } from ")
+		out.WriteString(is.Path.String())
+	}
+
+	out.WriteString(";")
+
+	return out.String()
+}
+
+// BoundNames returns the names the import statement binds.
+func (is *ImportStatement) BoundNames() []string {
+	if len(is.Names) == 0 {
+		name := moduleName(is.Path.Value)
+		if is.Alias != nil {
+			name = is.Alias.Value
+		}
+		if name == "" {
+			return nil
+		}
+		return []string{name}
+	}
+
+	names := make([]string, len(is.Names))
+	for i, n := range is.Names {
+		names[i] = n.Value
+	}
+
+	return names
+}
+
+// moduleName returns the name a module imported without `as` is bound to:
+// the base name of its path without the extension, or "" if that is not an
+// identifier.
+func moduleName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return ""
+		}
+	}
+
+	return name
+}
+
+// ExportStatement marks the binding made by a top level let statement as
+// visible to modules importing the file.
+type ExportStatement struct {
+	Token     token.Token // the `export` token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExportStatement) End() token.Position {
+	if es.Statement != nil {
+		return es.Statement.End()
+	}
+	return es.Token.End
+}
+func (es *ExportStatement) String() string {
+	return "export " + es.Statement.String()
+}
@@ -0,0 +1,43 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// SelectorExpression is `left.name`, a hash lookup by string key or a
+// method of left.
+type SelectorExpression struct {
+	Token    token.Token // the `.` token
+	Left     Expression
+	Selector *Identifier
+}
+
+func (se *SelectorExpression) expressionNode()      {}
+func (se *SelectorExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectorExpression) Pos() token.Position {
+	if se.Left != nil {
+		return se.Left.Pos()
+	}
+	return se.Token.Pos
+}
+func (se *SelectorExpression) End() token.Position {
+	if se.Selector != nil {
+		return se.Selector.End()
+	}
+	return se.Token.End
+}
+func (se *SelectorExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString(".")
+	if se.Selector != nil {
+		out.WriteString(se.Selector.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
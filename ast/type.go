@@ -0,0 +1,145 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// TypeExpression is a type annotation, such as `int`, `[string]` or
+// `fn(int) -> bool`. Annotations are only read by the type checker; running
+// a program ignores them.
+type TypeExpression interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type written as a name, such as `int` or `any`.
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NamedType) End() token.Position  { return nt.Token.End }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ArrayType is `[T]`, an array of T.
+type ArrayType struct {
+	Token    token.Token // the '[' token
+	Element  TypeExpression
+	Rbracket token.Token
+}
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) Pos() token.Position  { return at.Token.Pos }
+func (at *ArrayType) End() token.Position {
+	if at.Rbracket.End.IsValid() {
+		return at.Rbracket.End
+	}
+	if at.Element != nil {
+		return at.Element.End()
+	}
+	return at.Token.End
+}
+func (at *ArrayType) String() string { return "[" + typeString(at.Element) + "]" }
+
+// HashType is `{K: V}`, a hash from K to V.
+type HashType struct {
+	Token  token.Token // the '{' token
+	Key    TypeExpression
+	Value  TypeExpression
+	Rbrace token.Token
+}
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) Pos() token.Position  { return ht.Token.Pos }
+func (ht *HashType) End() token.Position {
+	if ht.Rbrace.End.IsValid() {
+		return ht.Rbrace.End
+	}
+	if ht.Value != nil {
+		return ht.Value.End()
+	}
+	return ht.Token.End
+}
+func (ht *HashType) String() string {
+	return "{" + typeString(ht.Key) + ": " + typeString(ht.Value) + "}"
+}
+
+// FunctionType is `fn(T, U) -> R`. Rest, if set, is the type of the
+// elements of a rest parameter, written `fn(T, ...U)`. A function type
+// without `-> R` returns any.
+type FunctionType struct {
+	Token      token.Token // the `fn` token
+	Parameters []TypeExpression
+	Rest       TypeExpression
+	Rparen     token.Token
+	Result     TypeExpression
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Position  { return ft.Token.Pos }
+func (ft *FunctionType) End() token.Position {
+	if ft.Result != nil {
+		return ft.Result.End()
+	}
+	if ft.Rparen.End.IsValid() {
+		return ft.Rparen.End
+	}
+	return ft.Token.End
+}
+func (ft *FunctionType) String() string {
+	var params []string
+	for _, p := range ft.Parameters {
+		params = append(params, typeString(p))
+	}
+	if ft.Rest != nil {
+		params = append(params, "..."+typeString(ft.Rest))
+	}
+
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Result != nil {
+		out += " -> " + typeString(ft.Result)
+	}
+	return out
+}
+
+func typeString(t TypeExpression) string {
+	if isNil(t) {
+		return "?"
+	}
+	return t.String()
+}
+
+// formatTypedParameters formats the parameter list of fl along with the
+// annotations of its parameters, e.g. `a: int, b = 2, ...rest: [string]`.
+func formatTypedParameters(fl *FunctionLiteral) string {
+	var out []string
+
+	for i, p := range fl.Parameters {
+		s := p.String()
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			s += ": " + fl.ParameterTypes[i].String()
+		}
+		if i < len(fl.Defaults) && fl.Defaults[i] != nil {
+			s += " = " + fl.Defaults[i].String()
+		}
+		out = append(out, s)
+	}
+
+	if fl.Rest != nil {
+		s := "..." + fl.Rest.String()
+		if fl.RestType != nil {
+			s += ": " + fl.RestType.String()
+		}
+		out = append(out, s)
+	}
+
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,177 @@
+package ast
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node. If f returns false the children of the node are skipped.
+// Nil nodes are never passed to f.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *LetStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Type, f)
+		Inspect(n.Expression, f)
+	case *ExportStatement:
+		Inspect(n.Statement, f)
+	case *ImportStatement:
+		Inspect(n.Path, f)
+		Inspect(n.Alias, f)
+		for _, name := range n.Names {
+			Inspect(name, f)
+		}
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *AssignStatement:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *WhileStatement:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	case *ForStatement:
+		Inspect(n.Variable, f)
+		Inspect(n.Iterable, f)
+		Inspect(n.Body, f)
+	case *ThrowStatement:
+		Inspect(n.Value, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *TryExpression:
+		Inspect(n.Block, f)
+		Inspect(n.Param, f)
+		Inspect(n.Catch, f)
+		Inspect(n.Finally, f)
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			Inspect(param, f)
+			if i < len(n.ParameterTypes) {
+				Inspect(n.ParameterTypes[i], f)
+			}
+			if i < len(n.Defaults) {
+				Inspect(n.Defaults[i], f)
+			}
+		}
+		Inspect(n.Rest, f)
+		Inspect(n.RestType, f)
+		Inspect(n.ReturnType, f)
+		Inspect(n.Body, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Inspect(el, f)
+		}
+	case *HashLiteral:
+		for _, key := range n.Keys {
+			Inspect(key, f)
+			Inspect(n.Pairs[key], f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *SliceExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *SelectorExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Selector, f)
+	case *SpreadExpression:
+		Inspect(n.Value, f)
+	case *KeywordArgument:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *InterpolatedString:
+		for _, e := range n.Expressions {
+			Inspect(e, f)
+		}
+	case *ArrayType:
+		Inspect(n.Element, f)
+	case *HashType:
+		Inspect(n.Key, f)
+		Inspect(n.Value, f)
+	case *FunctionType:
+		for _, p := range n.Parameters {
+			Inspect(p, f)
+		}
+		Inspect(n.Rest, f)
+		Inspect(n.Result, f)
+	}
+}
+
+// isNil reports whether node is nil or a nil pointer, as optional children
+// such as a missing else block are.
+func isNil(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *Identifier:
+		return n == nil
+	case *BlockStatement:
+		return n == nil
+	case *StringLiteral:
+		return n == nil
+	case *LetStatement:
+		return n == nil
+	}
+	return false
+}
+
+// Declarations returns the names the statements declare in the scope they
+// run in: those of let and import statements outside of functions, loop
+// bodies and catch clauses. Blocks of if and try expressions share the scope
+// around them.
+func Declarations(stmts []Statement) []string {
+	var names []string
+
+	var visit func(Node) bool
+	visit = func(n Node) bool {
+		switch n := n.(type) {
+		case *LetStatement:
+			names = append(names, n.Name.Value)
+		case *ImportStatement:
+			names = append(names, n.BoundNames()...)
+			return false
+		case *FunctionLiteral:
+			return false
+		case *WhileStatement:
+			Inspect(n.Condition, visit)
+			return false
+		case *ForStatement:
+			Inspect(n.Iterable, visit)
+			return false
+		case *TryExpression:
+			Inspect(n.Block, visit)
+			Inspect(n.Finally, visit)
+			return false
+		}
+		return true
+	}
+
+	for _, s := range stmts {
+		Inspect(s, visit)
+	}
+
+	return names
+}
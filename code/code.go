@@ -0,0 +1,339 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a sequence of encoded instructions: an opcode followed by
+// its operands in big endian order.
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), len(def.OperandWidths))
+	}
+
+	var out bytes.Buffer
+	out.WriteString(def.Name)
+	for _, o := range operands {
+		fmt.Fprintf(&out, " %d", o)
+	}
+
+	return out.String()
+}
+
+type Opcode byte
+
+const (
+	// OpConstant pushes the constant at the index of its operand.
+	OpConstant Opcode = iota
+	OpNull
+	OpTrue
+	OpFalse
+	// OpNil pushes the absence of a value, the result of statements such as
+	// let.
+	OpNil
+	OpPop
+	OpSwap
+
+	// Arithmetic and comparison operators with integer fast paths. Any other
+	// infix operator is an OpInfix with the operator as its operand.
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLess
+	OpGreater
+	OpLessEqual
+	OpGreaterEqual
+	OpInfix
+	OpPrefix
+
+	OpJump
+	// OpBranch pops a condition, which must be a boolean, and jumps if it is
+	// false. Its first operand names the construct the condition belongs to
+	// for the error raised otherwise.
+	OpBranch
+	// OpShortCircuit jumps, leaving the left operand of `&&` or `||` on the
+	// stack, if it decides the expression on its own.
+	OpShortCircuit
+
+	OpGetGlobal
+	OpSetGlobal
+	OpAssignGlobal
+	OpGetLocal
+	OpSetLocal
+	OpAssignLocal
+	// The cell variants access locals captured by closures, which live in a
+	// cell stored in the local slot.
+	OpGetCell
+	OpSetCell
+	OpAssignCell
+	OpGetFree
+	OpSetFree
+	OpAssignFree
+	// OpGetName and OpAssignName resolve a name that may be bound in more
+	// than one scope at runtime, trying each candidate in turn.
+	OpGetName
+	OpAssignName
+	// OpNewCell gives a captured local of a block a fresh, unbound cell.
+	OpNewCell
+	// OpClearLocal unbinds a local of a block.
+	OpClearLocal
+
+	OpArray
+	OpAppend
+	OpExtend
+	OpHash
+	OpHashKey
+	OpIndex
+	OpSlice
+	OpSelect
+	OpSetIndex
+	OpSetField
+	OpInterpolate
+
+	OpCall
+	OpTailCall
+	// OpCallEx calls with keyword arguments, or with spread arguments
+	// collected into an array.
+	OpCallEx
+	OpReturnValue
+	OpClosure
+	// OpCheckArgument raises an arity error for a parameter left without an
+	// argument or default.
+	OpCheckArgument
+	// OpJumpIfBound skips the default of a parameter given an argument.
+	OpJumpIfBound
+
+	OpIter
+	OpIterNext
+
+	OpTry
+	OpEndTry
+	OpThrow
+	OpRethrow
+	// OpError raises a runtime error with the message in the constant of its
+	// operand.
+	OpError
+	OpImport
+)
+
+// Operators of OpBranch.
+const (
+	BranchIf = iota
+	BranchWhile
+)
+
+// Flags of OpCallEx.
+const (
+	CallSpread = 1 << iota
+	CallTail
+)
+
+// Flags of OpSlice.
+const (
+	SliceLow = 1 << iota
+	SliceHigh
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{4}},
+	OpNull:     {"OpNull", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNil:      {"OpNil", []int{}},
+	OpPop:      {"OpPop", []int{}},
+	OpSwap:     {"OpSwap", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLess:         {"OpLess", []int{}},
+	OpGreater:      {"OpGreater", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	// the operand indexes Operators
+	OpInfix:  {"OpInfix", []int{1}},
+	OpPrefix: {"OpPrefix", []int{1}},
+
+	OpJump:         {"OpJump", []int{4}},
+	OpBranch:       {"OpBranch", []int{1, 4}},
+	OpShortCircuit: {"OpShortCircuit", []int{1, 4}},
+
+	OpGetGlobal:    {"OpGetGlobal", []int{4}},
+	OpSetGlobal:    {"OpSetGlobal", []int{4}},
+	OpAssignGlobal: {"OpAssignGlobal", []int{4}},
+	OpGetLocal:     {"OpGetLocal", []int{4}},
+	OpSetLocal:     {"OpSetLocal", []int{4}},
+	OpAssignLocal:  {"OpAssignLocal", []int{4}},
+	OpGetCell:      {"OpGetCell", []int{4}},
+	OpSetCell:      {"OpSetCell", []int{4}},
+	OpAssignCell:   {"OpAssignCell", []int{4}},
+	OpGetFree:      {"OpGetFree", []int{4}},
+	OpSetFree:      {"OpSetFree", []int{4}},
+	OpAssignFree:   {"OpAssignFree", []int{4}},
+	OpGetName:      {"OpGetName", []int{4}},
+	OpAssignName:   {"OpAssignName", []int{4}},
+	OpNewCell:      {"OpNewCell", []int{4}},
+	OpClearLocal:   {"OpClearLocal", []int{4}},
+
+	OpArray:    {"OpArray", []int{4}},
+	OpAppend:   {"OpAppend", []int{}},
+	OpExtend:   {"OpExtend", []int{}},
+	OpHash:     {"OpHash", []int{4}},
+	OpHashKey:  {"OpHashKey", []int{}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSlice:    {"OpSlice", []int{1}},
+	OpSelect:   {"OpSelect", []int{4}},
+	OpSetIndex: {"OpSetIndex", []int{1}},
+	OpSetField: {"OpSetField", []int{1, 4}},
+	// the constant holding the strings and the number of values
+	OpInterpolate: {"OpInterpolate", []int{4, 4}},
+
+	OpCall:     {"OpCall", []int{2}},
+	OpTailCall: {"OpTailCall", []int{2}},
+	// argument count, constant holding the keyword names, flags
+	OpCallEx:        {"OpCallEx", []int{2, 4, 1}},
+	OpReturnValue:   {"OpReturnValue", []int{}},
+	OpClosure:       {"OpClosure", []int{4}},
+	OpCheckArgument: {"OpCheckArgument", []int{4}},
+	OpJumpIfBound:   {"OpJumpIfBound", []int{4, 4}},
+
+	OpIter:     {"OpIter", []int{4}},
+	OpIterNext: {"OpIterNext", []int{4, 4}},
+
+	// the handler address and whether the try expression catches errors
+	OpTry:     {"OpTry", []int{4, 1}},
+	OpEndTry:  {"OpEndTry", []int{}},
+	OpThrow:   {"OpThrow", []int{}},
+	OpRethrow: {"OpRethrow", []int{4}},
+	OpError:   {"OpError", []int{4}},
+	OpImport:  {"OpImport", []int{4}},
+}
+
+// Operators lists the operators of OpInfix, OpPrefix and the compound
+// assignments, indexed by their operand.
+var Operators = []string{
+	"+", "-", "*", "/", "%", "==", "!=", "<", ">", "<=", ">=",
+	"&&", "||", "&", "|", "^", "<<", ">>", "..", "..<", "!", "~", "=",
+}
+
+// Operator returns the operand encoding op in an instruction, or -1 if it
+// is not an operator.
+func Operator(op string) int {
+	for i, o := range Operators {
+		if o == op {
+			return i
+		}
+	}
+	return -1
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+// Make encodes an instruction.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 4:
+			binary.BigEndian.PutUint32(instruction[offset:], uint32(o))
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction, returning them and
+// the number of bytes they take.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 4:
+			operands[i] = int(ReadUint32(ins[offset:]))
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint32(ins Instructions) uint32 {
+	return binary.BigEndian.Uint32(ins)
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
@@ -0,0 +1,96 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 0, 0, 255, 254}},
+		{OpConstant, []int{70000}, []byte{byte(OpConstant), 0, 1, 17, 112}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpInfix, []int{3}, []byte{byte(OpInfix), 3}},
+		{OpGetLocal, []int{258}, []byte{byte(OpGetLocal), 0, 0, 1, 2}},
+		{OpSetGlobal, []int{70000}, []byte{byte(OpSetGlobal), 0, 1, 17, 112}},
+		{OpBranch, []int{BranchWhile, 258}, []byte{byte(OpBranch), BranchWhile, 0, 0, 1, 2}},
+		{OpCall, []int{300}, []byte{byte(OpCall), 1, 44}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+			continue
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpCallEx, 1, 4, CallSpread),
+		Make(OpJump, 100000),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0006 OpConstant 2
+0011 OpConstant 65535
+0016 OpCallEx 1 4 1
+0024 OpJump 100000
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 4},
+		{OpTry, []int{300, 1}, 5},
+		{OpIterNext, []int{70000, 1024}, 8},
+		{OpArray, []int{100000}, 4},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,841 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/code"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/token"
+)
+
+// Bytecode is a compiled program.
+type Bytecode struct {
+	// Main is the top level code of the program.
+	Main      *object.CompiledFunction
+	Constants []object.Object
+	// Globals holds the names of the global slots.
+	Globals []string
+	// Imports holds the import statements OpImport refers to.
+	Imports []*ast.ImportStatement
+}
+
+// maxArguments is the largest argument count the operand of OpCall holds.
+const maxArguments = 0xFFFF
+
+// Compiler lowers a program to bytecode with the same semantics as eval.
+type Compiler struct {
+	constants []object.Object
+	imports   []*ast.ImportStatement
+	globals   *GlobalTable
+
+	fn *function
+}
+
+// loop is a loop being compiled.
+type loop struct {
+	start  int
+	breaks []int
+	// tries is the number of try expressions of the function the loop is
+	// nested in.
+	tries int
+}
+
+// try is a try expression being compiled whose handler is active.
+type try struct {
+	node  *ast.TryExpression
+	scope *scope
+	// loops is the number of loops of the function the try expression is
+	// nested in.
+	loops int
+}
+
+// compileError aborts a compilation.
+type compileError struct {
+	err error
+}
+
+func New() *Compiler {
+	return NewWithState(NewGlobalTable(), nil)
+}
+
+// NewWithState returns a compiler continuing from the globals and constants
+// of the programs compiled before, whose functions may still be called.
+func NewWithState(globals *GlobalTable, constants []object.Object) *Compiler {
+	return &Compiler{globals: globals, constants: constants}
+}
+
+// Compile compiles program. It may only be called once.
+func (c *Compiler) Compile(program *ast.Program) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ce, ok := r.(compileError)
+			if !ok {
+				panic(r)
+			}
+			err = ce.err
+		}
+	}()
+
+	c.fn = &function{captured: capturedNames(program)}
+	c.enterScope(ast.Declarations(program.Statements))
+
+	c.compileBlock(program.Statements, true)
+	c.emit(code.OpReturnValue)
+
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	fn := c.fn
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: fn.instructions,
+			NumLocals:    fn.numLocals,
+			Chains:       fn.chains,
+			Locals:       fn.locals,
+			Name:         "<program>",
+			Positions:    fn.positions,
+		},
+		Constants: c.constants,
+		Globals:   c.globals.names,
+		Imports:   c.imports,
+	}
+}
+
+func (c *Compiler) fail(format string, a ...interface{}) {
+	panic(compileError{fmt.Errorf(format, a...)})
+}
+
+func (c *Compiler) compileBlock(stmts []ast.Statement, keep bool) {
+	if len(stmts) == 0 {
+		if keep {
+			c.emit(code.OpNil)
+		}
+		return
+	}
+
+	for i, s := range stmts {
+		c.compileStatement(s, keep && i == len(stmts)-1)
+	}
+}
+
+// compileStatement compiles s. If keep is set, its value is left on the
+// stack: the value of an expression statement, nil for other statements.
+func (c *Compiler) compileStatement(s ast.Statement, keep bool) {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		c.compileExpression(s.Expression)
+		if !keep {
+			c.emit(code.OpPop)
+		}
+		return
+	case *ast.LetStatement:
+		c.compileLet(s)
+	case *ast.ExportStatement:
+		c.compileLet(s.Statement)
+	case *ast.ImportStatement:
+		c.compileImport(s)
+	case *ast.AssignStatement:
+		c.compileAssign(s)
+	case *ast.WhileStatement:
+		c.compileWhile(s)
+	case *ast.ForStatement:
+		c.compileFor(s)
+	case *ast.BreakStatement:
+		c.compileBreak(false)
+		return
+	case *ast.ContinueStatement:
+		c.compileBreak(true)
+		return
+	case *ast.ReturnStatement:
+		c.compileReturn(s)
+		return
+	case *ast.ThrowStatement:
+		c.compileExpression(s.Value)
+		c.emitAt(s.Pos(), code.OpThrow)
+		return
+	}
+
+	if keep {
+		c.emit(code.OpNil)
+	}
+}
+
+func (c *Compiler) compileLet(s *ast.LetStatement) {
+	if s.Expression == nil {
+		c.emit(code.OpNull)
+	} else {
+		c.compileExpression(s.Expression)
+	}
+	c.store(c.declared(s.Name.Value))
+}
+
+// declared returns the variable name declares in the current scope.
+func (c *Compiler) declared(name string) object.Binding {
+	b, ok := c.fn.scope.names[name]
+	if !ok {
+		c.fail("%s is not declared in its scope", name)
+	}
+	return b
+}
+
+func (c *Compiler) compileImport(s *ast.ImportStatement) {
+	c.imports = append(c.imports, s)
+	c.emitAt(s.Pos(), code.OpImport, len(c.imports)-1)
+
+	names := s.BoundNames()
+	for i := len(names) - 1; i >= 0; i-- {
+		c.store(c.declared(names[i]))
+	}
+}
+
+func (c *Compiler) compileAssign(s *ast.AssignStatement) {
+	c.compileExpression(s.Value)
+
+	operator := code.Operator(s.Operator[:len(s.Operator)-1])
+	if s.Operator == "=" {
+		operator = code.Operator("=")
+	}
+
+	switch target := s.Target.(type) {
+	case *ast.Identifier:
+		if s.Operator != "=" {
+			c.compileIdentifier(target)
+			c.emit(code.OpSwap)
+			c.emitAt(s.Pos(), code.OpInfix, operator)
+		}
+		bindings := c.resolve(c.fn, target.Value)
+		if len(bindings) > 1 {
+			c.emitAt(s.Pos(), code.OpAssignName, c.addChain(bindings))
+			return
+		}
+		switch b := bindings[0]; b.Scope {
+		case object.GlobalBinding:
+			c.emitAt(s.Pos(), code.OpAssignGlobal, b.Index)
+		case object.LocalBinding:
+			c.emitAt(s.Pos(), code.OpAssignLocal, b.Index)
+		case object.CellBinding:
+			c.emitAt(s.Pos(), code.OpAssignCell, b.Index)
+		case object.FreeBinding:
+			c.emitAt(s.Pos(), code.OpAssignFree, b.Index)
+		}
+	case *ast.IndexExpression:
+		c.compileExpression(target.Left)
+		c.compileExpression(target.Index)
+		c.emitAt(s.Pos(), code.OpSetIndex, operator)
+	case *ast.SelectorExpression:
+		c.compileExpression(target.Left)
+		c.emitAt(s.Pos(), code.OpSetField, operator, c.addConstant(&object.String{Value: target.Selector.Value}))
+	default:
+		c.emit(code.OpPop)
+		c.emitAt(s.Pos(), code.OpError, c.addConstant(&object.String{
+			Value: "cannot assign to " + s.Target.String(),
+		}))
+	}
+}
+
+// store pops the value on top of the stack into the variable b.
+func (c *Compiler) store(b object.Binding) {
+	switch b.Scope {
+	case object.GlobalBinding:
+		c.emit(code.OpSetGlobal, b.Index)
+	case object.LocalBinding:
+		c.emit(code.OpSetLocal, b.Index)
+	case object.CellBinding:
+		c.emit(code.OpSetCell, b.Index)
+	case object.FreeBinding:
+		c.emit(code.OpSetFree, b.Index)
+	}
+}
+
+// enterBlockScope declares names in a new scope entered each time the
+// instructions that follow run, unbinding them anew.
+func (c *Compiler) enterBlockScope(names []string) *scope {
+	s := c.enterScope(names)
+
+	for _, name := range names {
+		switch b := s.names[name]; b.Scope {
+		case object.LocalBinding:
+			c.emit(code.OpClearLocal, b.Index)
+		case object.CellBinding:
+			c.emit(code.OpNewCell, b.Index)
+		}
+	}
+
+	return s
+}
+
+func (c *Compiler) compileWhile(s *ast.WhileStatement) {
+	l := c.enterLoop()
+
+	c.compileExpression(s.Condition)
+	exit := c.emitAt(s.Pos(), code.OpBranch, code.BranchWhile, 0xFFFF)
+
+	c.enterBlockScope(ast.Declarations(s.Body.Statements))
+	c.compileBlock(s.Body.Statements, false)
+	c.leaveScope()
+	c.emit(code.OpJump, l.start)
+
+	c.changeOperand(exit, 1, len(c.fn.instructions))
+	c.leaveLoop(l)
+}
+
+func (c *Compiler) compileFor(s *ast.ForStatement) {
+	c.compileExpression(s.Iterable)
+	iterator := c.fn.addLocal("")
+	c.emitAt(s.Pos(), code.OpIter, iterator)
+
+	l := c.enterLoop()
+
+	next := c.emit(code.OpIterNext, iterator, 0xFFFF)
+
+	names := append([]string{s.Variable.Value}, ast.Declarations(s.Body.Statements)...)
+	scope := c.enterBlockScope(names)
+	c.store(scope.names[s.Variable.Value])
+	c.compileBlock(s.Body.Statements, false)
+	c.leaveScope()
+	c.emit(code.OpJump, l.start)
+
+	c.changeOperand(next, 1, len(c.fn.instructions))
+	c.leaveLoop(l)
+}
+
+func (c *Compiler) enterLoop() *loop {
+	l := &loop{start: len(c.fn.instructions), tries: len(c.fn.tries)}
+	c.fn.loops = append(c.fn.loops, l)
+	return l
+}
+
+func (c *Compiler) leaveLoop(l *loop) {
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, 0, len(c.fn.instructions))
+	}
+	c.fn.loops = c.fn.loops[:len(c.fn.loops)-1]
+}
+
+// compileBreak leaves the innermost loop, or starts its next iteration,
+// running the finally clauses of the try expressions left on the way.
+func (c *Compiler) compileBreak(next bool) {
+	fn := c.fn
+	if len(fn.loops) == 0 {
+		return
+	}
+	l := fn.loops[len(fn.loops)-1]
+
+	c.leaveTries(l.tries)
+
+	if next {
+		c.emit(code.OpJump, l.start)
+	} else {
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 0xFFFF))
+	}
+}
+
+func (c *Compiler) compileReturn(s *ast.ReturnStatement) {
+	if call, ok := s.ReturnValue.(*ast.CallExpression); ok && s.TailCall && len(c.fn.tries) == 0 {
+		c.compileCall(call, true)
+		c.emit(code.OpReturnValue)
+		return
+	}
+
+	if s.ReturnValue == nil {
+		c.emit(code.OpNil)
+	} else {
+		c.compileExpression(s.ReturnValue)
+	}
+	c.leaveTries(0)
+	c.emit(code.OpReturnValue)
+}
+
+// leaveTries deactivates the handlers of the try expressions nested deeper
+// than n and runs their finally clauses, innermost first.
+func (c *Compiler) leaveTries(n int) {
+	fn := c.fn
+	tries, loops, scope := fn.tries, fn.loops, fn.scope
+
+	for i := len(tries) - 1; i >= n; i-- {
+		t := tries[i]
+		c.emit(code.OpEndTry)
+		if t.node.Finally != nil {
+			// the finally clause runs outside the try expression
+			fn.tries, fn.loops, fn.scope = tries[:i], loops[:t.loops], t.scope
+			c.compileBlock(t.node.Finally.Statements, false)
+		}
+	}
+
+	fn.tries, fn.loops, fn.scope = tries, loops, scope
+}
+
+func (c *Compiler) enterTry(node *ast.TryExpression, catch bool) int {
+	fn := c.fn
+	fn.tries = append(fn.tries, &try{node: node, scope: fn.scope, loops: len(fn.loops)})
+
+	flag := 0
+	if catch {
+		flag = 1
+	}
+	return c.emit(code.OpTry, 0xFFFF, flag)
+}
+
+func (c *Compiler) leaveTry() {
+	c.emit(code.OpEndTry)
+	c.fn.tries = c.fn.tries[:len(c.fn.tries)-1]
+}
+
+// compileTry compiles a try expression. The finally clause is copied to
+// every way out of the expression: after the try block and the catch
+// clause, before breaks and returns leaving them and in the handler of
+// errors not caught.
+func (c *Compiler) compileTry(node *ast.TryExpression) {
+	var ends []int
+
+	handler := c.enterTry(node, node.Catch != nil)
+	c.compileBlock(node.Block.Statements, true)
+	c.leaveTry()
+	if node.Finally != nil {
+		c.compileBlock(node.Finally.Statements, false)
+	}
+	ends = append(ends, c.emit(code.OpJump, 0xFFFF))
+
+	c.changeOperand(handler, 0, len(c.fn.instructions))
+
+	if node.Catch != nil {
+		var names []string
+		if node.Param != nil {
+			names = append(names, node.Param.Value)
+		}
+		scope := c.enterBlockScope(append(names, ast.Declarations(node.Catch.Statements)...))
+		if node.Param != nil {
+			c.store(scope.names[node.Param.Value])
+		} else {
+			c.emit(code.OpPop)
+		}
+
+		if node.Finally == nil {
+			c.compileBlock(node.Catch.Statements, true)
+			c.leaveScope()
+			ends = append(ends, c.emit(code.OpJump, 0xFFFF))
+		} else {
+			// errors raised by the catch clause run the finally clause too
+			outer := scope.outer
+			c.fn.scope = outer
+			handler = c.enterTry(node, false)
+			c.fn.scope = scope
+
+			c.compileBlock(node.Catch.Statements, true)
+			c.leaveScope()
+			c.leaveTry()
+			c.compileBlock(node.Finally.Statements, false)
+			ends = append(ends, c.emit(code.OpJump, 0xFFFF))
+
+			c.changeOperand(handler, 0, len(c.fn.instructions))
+		}
+	}
+
+	if node.Finally != nil {
+		err := c.fn.addLocal("")
+		c.emit(code.OpSetLocal, err)
+		c.compileBlock(node.Finally.Statements, false)
+		c.emit(code.OpRethrow, err)
+	}
+
+	for _, pos := range ends {
+		c.changeOperand(pos, 0, len(c.fn.instructions))
+	}
+}
+
+func (c *Compiler) compileExpression(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: e.Value}))
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: e.Value}))
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: e.Value}))
+	case *ast.BooleanLiteral:
+		if e.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.InterpolatedString:
+		n := len(e.Expressions)
+		if len(e.Strings) < n {
+			n = len(e.Strings)
+		}
+		strs := make([]object.Object, len(e.Strings))
+		for i, s := range e.Strings {
+			strs[i] = &object.String{Value: s}
+		}
+		for _, expr := range e.Expressions[:n] {
+			c.compileExpression(expr)
+		}
+		c.emitAt(e.Pos(), code.OpInterpolate, c.addConstant(&object.Array{Elements: strs}), n)
+	case *ast.Identifier:
+		c.compileIdentifier(e)
+	case *ast.PrefixExpression:
+		c.compileExpression(e.Right)
+		c.emitAt(e.Pos(), code.OpPrefix, c.operator(e.Operator))
+	case *ast.InfixExpression:
+		c.compileInfix(e)
+	case *ast.IfExpression:
+		c.compileExpression(e.Condition)
+		alternative := c.emitAt(e.Pos(), code.OpBranch, code.BranchIf, 0xFFFF)
+		c.compileBlock(e.Consequence.Statements, true)
+		end := c.emit(code.OpJump, 0xFFFF)
+		c.changeOperand(alternative, 1, len(c.fn.instructions))
+		if e.Alternative != nil {
+			c.compileBlock(e.Alternative.Statements, true)
+		} else {
+			c.emit(code.OpNull)
+		}
+		c.changeOperand(end, 0, len(c.fn.instructions))
+	case *ast.TryExpression:
+		c.compileTry(e)
+	case *ast.ArrayLiteral:
+		c.compileList(e.Elements, e.Pos(), false)
+		if !hasSpread(e.Elements) {
+			c.emitAt(e.Pos(), code.OpArray, len(e.Elements))
+		}
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			c.compileExpression(key)
+			c.emitAt(e.Pos(), code.OpHashKey)
+			c.compileExpression(e.Pairs[key])
+		}
+		c.emitAt(e.Pos(), code.OpHash, len(e.Keys))
+	case *ast.IndexExpression:
+		c.compileExpression(e.Left)
+		c.compileExpression(e.Index)
+		c.emitAt(e.Pos(), code.OpIndex)
+	case *ast.SliceExpression:
+		c.compileExpression(e.Left)
+		flags := 0
+		if e.Low != nil {
+			c.compileExpression(e.Low)
+			flags |= code.SliceLow
+		}
+		if e.High != nil {
+			c.compileExpression(e.High)
+			flags |= code.SliceHigh
+		}
+		c.emitAt(e.Pos(), code.OpSlice, flags)
+	case *ast.SelectorExpression:
+		c.compileExpression(e.Left)
+		c.emitAt(e.Pos(), code.OpSelect, c.addConstant(&object.String{Value: e.Selector.Value}))
+	case *ast.CallExpression:
+		c.compileCall(e, false)
+	case *ast.FunctionLiteral:
+		c.compileFunction(e)
+	case *ast.SpreadExpression:
+		c.emitAt(e.Pos(), code.OpError, c.addConstant(&object.String{
+			Value: "unexpected spread: " + e.String(),
+		}))
+	default:
+		c.emit(code.OpNil)
+	}
+}
+
+func (c *Compiler) compileIdentifier(e *ast.Identifier) {
+	if builtin, ok := eval.LookupBuiltin(e.Value); ok {
+		c.compileBuiltin(e, builtin)
+		return
+	}
+
+	bindings := c.resolve(c.fn, e.Value)
+	if len(bindings) > 1 {
+		c.emitAt(e.Pos(), code.OpGetName, c.addChain(bindings))
+		return
+	}
+
+	switch b := bindings[0]; b.Scope {
+	case object.GlobalBinding:
+		c.emitAt(e.Pos(), code.OpGetGlobal, b.Index)
+	case object.LocalBinding:
+		c.emitAt(e.Pos(), code.OpGetLocal, b.Index)
+	case object.CellBinding:
+		c.emitAt(e.Pos(), code.OpGetCell, b.Index)
+	case object.FreeBinding:
+		c.emitAt(e.Pos(), code.OpGetFree, b.Index)
+	}
+}
+
+// compileBuiltin compiles a use of the name of a builtin, which refers to
+// a variable of that name while one is bound.
+func (c *Compiler) compileBuiltin(e *ast.Identifier, builtin *object.Builtin) {
+	bindings := c.candidates(c.fn, e.Value)
+	if i, ok := c.globals.index[e.Value]; ok {
+		global := object.Binding{Scope: object.GlobalBinding, Index: i}
+		if !containsBinding(bindings, global) {
+			bindings = append(bindings, global)
+		}
+	}
+
+	if len(bindings) == 0 {
+		c.emit(code.OpConstant, c.addConstant(builtin))
+		return
+	}
+
+	bindings = append(bindings, object.Binding{Scope: object.BuiltinBinding, Index: c.addConstant(builtin)})
+	c.emitAt(e.Pos(), code.OpGetName, c.addChain(bindings))
+}
+
+func containsBinding(bindings []object.Binding, b object.Binding) bool {
+	for _, other := range bindings {
+		if other == b {
+			return true
+		}
+	}
+	return false
+}
+
+var infixOps = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLess,
+	">":  code.OpGreater,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+func (c *Compiler) compileInfix(e *ast.InfixExpression) {
+	c.compileExpression(e.Left)
+
+	if e.Operator == "&&" || e.Operator == "||" {
+		operator := c.operator(e.Operator)
+		end := c.emit(code.OpShortCircuit, operator, 0xFFFF)
+		c.compileExpression(e.Right)
+		c.emitAt(e.Pos(), code.OpInfix, operator)
+		c.changeOperand(end, 1, len(c.fn.instructions))
+		return
+	}
+
+	c.compileExpression(e.Right)
+	if op, ok := infixOps[e.Operator]; ok {
+		c.emitAt(e.Pos(), op)
+	} else {
+		c.emitAt(e.Pos(), code.OpInfix, c.operator(e.Operator))
+	}
+}
+
+func (c *Compiler) operator(op string) int {
+	i := code.Operator(op)
+	if i < 0 {
+		c.fail("unknown operator %s", op)
+	}
+	return i
+}
+
+func contains(slots []int, slot int) bool {
+	for _, s := range slots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSpread(exprs []ast.Expression) bool {
+	for _, e := range exprs {
+		if _, ok := e.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compileList pushes the values of exprs. If they include spread
+// expressions, or asArray is set, it pushes a single array holding them
+// instead.
+func (c *Compiler) compileList(exprs []ast.Expression, pos token.Position, asArray bool) {
+	if !asArray && !hasSpread(exprs) {
+		for _, e := range exprs {
+			c.compileExpression(e)
+		}
+		return
+	}
+
+	c.emit(code.OpArray, 0)
+	for _, e := range exprs {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			c.compileExpression(spread.Value)
+			c.emitAt(pos, code.OpExtend)
+		} else {
+			c.compileExpression(e)
+			c.emit(code.OpAppend)
+		}
+	}
+}
+
+func (c *Compiler) compileCall(e *ast.CallExpression, tail bool) {
+	c.compileExpression(e.Function)
+
+	n := 0
+	for n < len(e.Arguments) {
+		if _, ok := e.Arguments[n].(*ast.KeywordArgument); ok {
+			break
+		}
+		n++
+	}
+	positional, keywords := e.Arguments[:n], e.Arguments[n:]
+
+	// more arguments than the operand holds are passed as an array, like
+	// spread arguments
+	spread := hasSpread(positional) || n > maxArguments
+
+	c.compileList(positional, e.Pos(), spread)
+
+	if len(keywords) == 0 && !spread {
+		if tail {
+			c.emitAt(e.Pos(), code.OpTailCall, n)
+		} else {
+			c.emitAt(e.Pos(), code.OpCall, n)
+		}
+		return
+	}
+
+	var names []object.Object
+	for _, arg := range keywords {
+		kw, ok := arg.(*ast.KeywordArgument)
+		if !ok {
+			c.emitAt(e.Pos(), code.OpError, c.addConstant(&object.String{
+				Value: "positional argument follows keyword argument",
+			}))
+			return
+		}
+		c.compileExpression(kw.Value)
+		names = append(names, &object.String{Value: kw.Name.Value})
+	}
+
+	flags := 0
+	if spread {
+		flags |= code.CallSpread
+	}
+	if tail {
+		flags |= code.CallTail
+	}
+	c.emitAt(e.Pos(), code.OpCallEx, n, c.addConstant(&object.Array{Elements: names}), flags)
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) {
+	nodes := []ast.Node{node.Body}
+	for _, d := range node.Defaults {
+		if d != nil {
+			nodes = append(nodes, d)
+		}
+	}
+
+	fn := &function{outer: c.fn, captured: capturedNames(nodes...)}
+	c.fn = fn
+
+	var names, params []string
+	for _, p := range node.Parameters {
+		names = append(names, p.Value)
+		params = append(params, p.Value)
+	}
+	if node.Rest != nil {
+		names = append(names, node.Rest.Value)
+	}
+	names = append(names, ast.Declarations(node.Body.Statements)...)
+	scope := c.enterScope(names)
+
+	for _, name := range names {
+		if b := scope.names[name]; b.Scope == object.CellBinding && !contains(fn.cells, b.Index) {
+			fn.cells = append(fn.cells, b.Index)
+		}
+	}
+
+	// parameters without an argument take their default or fail the call
+	entries := make([]int, len(params)+1)
+	for i, p := range node.Parameters {
+		entries[i] = len(fn.instructions)
+
+		if i < len(node.Defaults) && node.Defaults[i] != nil {
+			skip := c.emit(code.OpJumpIfBound, i, 0xFFFF)
+			c.compileExpression(node.Defaults[i])
+			c.store(scope.names[p.Value])
+			c.changeOperand(skip, 1, len(fn.instructions))
+		} else {
+			c.emit(code.OpCheckArgument, i)
+		}
+	}
+	entries[len(params)] = len(fn.instructions)
+
+	c.compileBlock(node.Body.Statements, true)
+	c.emit(code.OpReturnValue)
+
+	c.fn = fn.outer
+
+	compiled := &object.CompiledFunction{
+		Instructions:  fn.instructions,
+		NumLocals:     fn.numLocals,
+		NumParameters: len(params),
+		Rest:          node.Rest != nil,
+		Entries:       entries,
+		Cells:         fn.cells,
+		Captures:      fn.captures,
+		Chains:        fn.chains,
+		Parameters:    params,
+		Locals:        fn.locals,
+		Free:          fn.free,
+		Name:          node.Name,
+		Arity:         eval.Arity(node.Parameters, node.Defaults, node.Rest),
+		Positions:     fn.positions,
+		Literal:       node,
+	}
+
+	c.emitAt(node.Pos(), code.OpClosure, c.addConstant(compiled))
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) addChain(bindings []object.Binding) int {
+	c.fn.chains = append(c.fn.chains, bindings)
+	return len(c.fn.chains) - 1
+}
+
+// emit appends an instruction and returns its offset.
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	fn := c.fn
+	pos := len(fn.instructions)
+	fn.instructions = append(fn.instructions, code.Make(op, operands...)...)
+	return pos
+}
+
+// emitAt appends an instruction that may raise an error, which is reported
+// at pos.
+func (c *Compiler) emitAt(pos token.Position, op code.Opcode, operands ...int) int {
+	fn := c.fn
+	if n := len(fn.positions); n == 0 || fn.positions[n-1].Pos != pos {
+		fn.positions = append(fn.positions, object.Position{Offset: len(fn.instructions), Pos: pos})
+	}
+	return c.emit(op, operands...)
+}
+
+// changeOperand replaces operand i of the instruction at offset pos.
+func (c *Compiler) changeOperand(pos, i, operand int) {
+	ins := c.fn.instructions
+	op := code.Opcode(ins[pos])
+
+	operands, _ := code.ReadOperands(mustLookup(op), ins[pos+1:])
+	operands[i] = operand
+
+	copy(ins[pos:], code.Make(op, operands...))
+}
+
+func mustLookup(op code.Opcode) *code.Definition {
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		panic(err)
+	}
+	return def
+}
@@ -0,0 +1,190 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/threeaccents/digolang/code"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "if (true) { 10 } else { 20 }",
+			expectedConstants: []interface{}{10, 20},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBranch, code.BranchIf, 17),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 22),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "let x = 1; x",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "let f = fn(a) { let b = a; fn() { b } }; f(1)()",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpCheckArgument, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetCell, 1),
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalsAcrossPrograms(t *testing.T) {
+	globals := NewGlobalTable()
+
+	first := NewWithState(globals, nil)
+	if err := first.Compile(parser.New(lexer.New("let a = 1; let b = 2")).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	second := NewWithState(globals, first.Bytecode().Constants)
+	if err := second.Compile(parser.New(lexer.New("let c = b")).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := second.Bytecode()
+	expected := []string{"a", "b", "c"}
+	if len(bytecode.Globals) != len(expected) {
+		t.Fatalf("wrong globals. want=%v, got=%v", expected, bytecode.Globals)
+	}
+	for i, name := range expected {
+		if bytecode.Globals[i] != name {
+			t.Errorf("wrong global %d. want=%q, got=%q", i, name, bytecode.Globals[i])
+		}
+	}
+	if len(bytecode.Constants) != 2 {
+		t.Errorf("wrong number of constants. want=2, got=%d", len(bytecode.Constants))
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		compiler := New()
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		testInstructions(t, tt.expectedInstructions, bytecode.Main.Instructions)
+		testConstants(t, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testInstructions(t *testing.T, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if actual.String() != concatted.String() {
+		t.Errorf("wrong instructions.\nwant=%q\ngot =%q", concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(expected), len(actual))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok {
+				t.Errorf("constant %d is not Integer. got=%T (%+v)", i, actual[i], actual[i])
+				continue
+			}
+			if integer.Value != int64(constant) {
+				t.Errorf("constant %d has wrong value. want=%d, got=%d", i, constant, integer.Value)
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("constant %d is not CompiledFunction. got=%T (%+v)", i, actual[i], actual[i])
+				continue
+			}
+			testInstructions(t, constant, fn.Instructions)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package compiler
+
+import (
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/code"
+	"github.com/threeaccents/digolang/object"
+)
+
+// GlobalTable assigns slots to the global variables of a program. A REPL
+// keeps one table across the programs it compiles so later lines see the
+// variables of earlier ones.
+type GlobalTable struct {
+	index map[string]int
+	names []string
+}
+
+func NewGlobalTable() *GlobalTable {
+	return &GlobalTable{index: map[string]int{}}
+}
+
+// define returns the slot of the global called name, adding one if needed.
+func (g *GlobalTable) define(name string) int {
+	if i, ok := g.index[name]; ok {
+		return i
+	}
+
+	g.index[name] = len(g.names)
+	g.names = append(g.names, name)
+
+	return len(g.names) - 1
+}
+
+// scope holds the variables of one environment of the tree-walking
+// evaluator: the top level of the program, a function call, an iteration
+// of a loop or a catch clause. Variables are declared when the scope is
+// entered, but are not bound until their let statement runs, so a name
+// resolves to an outer variable until then, as it does in eval.
+type scope struct {
+	outer *scope
+	names map[string]object.Binding
+}
+
+// function is the state of the function being compiled.
+type function struct {
+	outer *function
+
+	instructions code.Instructions
+	positions    []object.Position
+
+	numLocals int
+	locals    []string
+	cells     []int
+
+	// free lists the variables of enclosing functions the function
+	// captures, with their bindings in the enclosing function.
+	free     []string
+	freeFrom []object.Binding
+	captures []object.Capture
+
+	chains [][]object.Binding
+
+	scope *scope
+	// captured holds the names used by the functions nested inside this
+	// one. Locals with these names live in cells so closures can share them.
+	captured map[string]bool
+
+	loops []*loop
+	tries []*try
+}
+
+// addLocal allocates a local slot.
+func (fn *function) addLocal(name string) int {
+	fn.locals = append(fn.locals, name)
+	fn.numLocals++
+	return fn.numLocals - 1
+}
+
+// capture returns the index of the free variable bound to b in the
+// enclosing function, adding it if needed.
+func (fn *function) capture(name string, b object.Binding) int {
+	for i, from := range fn.freeFrom {
+		if from == b {
+			return i
+		}
+	}
+
+	fn.free = append(fn.free, name)
+	fn.freeFrom = append(fn.freeFrom, b)
+	fn.captures = append(fn.captures, object.Capture{
+		Local: b.Scope == object.CellBinding,
+		Index: b.Index,
+	})
+
+	return len(fn.free) - 1
+}
+
+// enterScope declares names in a new scope of fn. At the top level of a
+// program, which is the only scope of the outermost function, they are
+// globals.
+func (c *Compiler) enterScope(names []string) *scope {
+	fn := c.fn
+	s := &scope{outer: fn.scope, names: map[string]object.Binding{}}
+
+	for _, name := range names {
+		if _, ok := s.names[name]; ok {
+			continue
+		}
+
+		switch {
+		case fn.outer == nil && fn.scope == nil:
+			s.names[name] = object.Binding{Scope: object.GlobalBinding, Index: c.globals.define(name)}
+		case fn.captured[name]:
+			s.names[name] = object.Binding{Scope: object.CellBinding, Index: fn.addLocal(name)}
+		default:
+			s.names[name] = object.Binding{Scope: object.LocalBinding, Index: fn.addLocal(name)}
+		}
+	}
+
+	fn.scope = s
+
+	return s
+}
+
+func (c *Compiler) leaveScope() {
+	c.fn.scope = c.fn.scope.outer
+}
+
+// resolve returns the variables name may refer to at the current point of
+// fn, innermost first. At runtime it refers to the first of them that is
+// bound. A name declared nowhere is a global, which a later program run by
+// the same REPL may still declare.
+func (c *Compiler) resolve(fn *function, name string) []object.Binding {
+	bindings := c.candidates(fn, name)
+	if len(bindings) == 0 {
+		bindings = append(bindings, object.Binding{Scope: object.GlobalBinding, Index: c.globals.define(name)})
+	}
+	return bindings
+}
+
+func (c *Compiler) candidates(fn *function, name string) []object.Binding {
+	var bindings []object.Binding
+
+	for s := fn.scope; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			bindings = append(bindings, b)
+		}
+	}
+
+	if fn.outer == nil {
+		return bindings
+	}
+
+	for _, b := range c.candidates(fn.outer, name) {
+		if b.Scope != object.GlobalBinding {
+			b = object.Binding{Scope: object.FreeBinding, Index: fn.capture(name, b)}
+		}
+		bindings = append(bindings, b)
+	}
+
+	return bindings
+}
+
+// capturedNames returns the names used inside the function literals nested
+// in nodes.
+func capturedNames(nodes ...ast.Node) map[string]bool {
+	names := map[string]bool{}
+
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			fl, ok := n.(*ast.FunctionLiteral)
+			if !ok {
+				return true
+			}
+
+			ast.Inspect(fl, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Identifier); ok {
+					names[ident.Value] = true
+				}
+				return true
+			})
+			return false
+		})
+	}
+
+	return names
+}
@@ -0,0 +1,99 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+var severityNames = map[Severity]string{
+	Error:   "error",
+	Warning: "warning",
+	Note:    "note",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Span is the half-open source range [Start, End) a diagnostic refers to.
+type Span struct {
+	Start token.Position `json:"start"`
+	End   token.Position `json:"end"`
+}
+
+// SpanOf returns the span covered by an AST node.
+func SpanOf(n interface {
+	Pos() token.Position
+	End() token.Position
+}) Span {
+	return Span{Start: n.Pos(), End: n.End()}
+}
+
+// TokenSpan returns the span covered by a single token.
+func TokenSpan(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
+
+// Related points at a secondary location that helps explain a diagnostic,
+// e.g. the opening bracket of an unterminated list.
+type Related struct {
+	Span    Span   `json:"span"`
+	Message string `json:"message"`
+}
+
+type Diagnostic struct {
+	Severity Severity  `json:"severity"`
+	Code     string    `json:"code,omitempty"`
+	Span     Span      `json:"span"`
+	Message  string    `json:"message"`
+	Hints    []string  `json:"hints,omitempty"`
+	Related  []Related `json:"related,omitempty"`
+}
+
+// Errorf builds an error diagnostic with a formatted message.
+func Errorf(span Span, code string, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Span:     span,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// Warningf builds a warning diagnostic with a formatted message.
+func Warningf(span Span, code string, format string, a ...interface{}) Diagnostic {
+	d := Errorf(span, code, format, a...)
+	d.Severity = Warning
+	return d
+}
+
+func (d Diagnostic) Error() string {
+	return d.Span.Start.String() + ": " + d.Message
+}
+
+// HasErrors reports whether any of the diagnostics has error severity.
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+func TestPrinterText(t *testing.T) {
+	src := "let a = 1;\nlet x = add(1,\tfoo;\n"
+
+	d := Errorf(Span{
+		Start: token.Position{Filename: "main.digo", Offset: 26, Line: 2, Column: 16},
+		End:   token.Position{Filename: "main.digo", Offset: 29, Line: 2, Column: 19},
+	}, "P0001", "expected next token to be ), got ; instead")
+	d.Hints = []string{"close the argument list"}
+	d.Related = []Related{{
+		Span: Span{
+			Start: token.Position{Filename: "main.digo", Offset: 22, Line: 2, Column: 12},
+			End:   token.Position{Filename: "main.digo", Offset: 23, Line: 2, Column: 13},
+		},
+		Message: "to match this (",
+	}}
+
+	var out bytes.Buffer
+	p := NewPrinter(&out)
+	p.AddSource("main.digo", src)
+	if err := p.Print([]Diagnostic{d}); err != nil {
+		t.Fatalf("Print returned error: %s", err)
+	}
+
+	expected := `error[P0001]: expected next token to be ), got ; instead
+  --> main.digo:2:16
+  |
+2 | let x = add(1,	foo;
+  |               	^^^
+  = hint: close the argument list
+  note: to match this (
+  --> main.digo:2:12
+  |
+2 | let x = add(1,	foo;
+  |            ^
+
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestPrinterJSON(t *testing.T) {
+	d := Errorf(Span{
+		Start: token.Position{Line: 1, Column: 5, Offset: 4},
+		End:   token.Position{Line: 1, Column: 6, Offset: 5},
+	}, "P0002", "no prefix parse function for %s found", ")")
+
+	var out bytes.Buffer
+	p := NewPrinter(&out)
+	p.JSON = true
+	if err := p.Print([]Diagnostic{d}); err != nil {
+		t.Fatalf("Print returned error: %s", err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %s\n%s", err, out.String())
+	}
+
+	if len(decoded) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%d", len(decoded))
+	}
+
+	if decoded[0]["severity"] != "error" {
+		t.Errorf("wrong severity. got=%v", decoded[0]["severity"])
+	}
+	if decoded[0]["code"] != "P0002" {
+		t.Errorf("wrong code. got=%v", decoded[0]["code"])
+	}
+	if decoded[0]["message"] != "no prefix parse function for ) found" {
+		t.Errorf("wrong message. got=%v", decoded[0]["message"])
+	}
+
+	start := decoded[0]["span"].(map[string]interface{})["start"].(map[string]interface{})
+	if start["line"] != float64(1) || start["column"] != float64(5) {
+		t.Errorf("wrong span start. got=%v", start)
+	}
+}
@@ -0,0 +1,118 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Printer writes diagnostics either as human readable source excerpts with a
+// caret underline or, when JSON is set, as a JSON array.
+type Printer struct {
+	JSON bool
+
+	out     io.Writer
+	sources map[string]string
+}
+
+func NewPrinter(out io.Writer) *Printer {
+	return &Printer{
+		out:     out,
+		sources: make(map[string]string),
+	}
+}
+
+// AddSource registers the contents of filename so that diagnostics pointing
+// into it can quote the offending line.
+func (p *Printer) AddSource(filename, src string) {
+	p.sources[filename] = src
+}
+
+func (p *Printer) Print(diags []Diagnostic) error {
+	if p.JSON {
+		if diags == nil {
+			diags = []Diagnostic{}
+		}
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(diags)
+	}
+
+	var out bytes.Buffer
+	for _, d := range diags {
+		p.render(&out, d)
+	}
+
+	_, err := p.out.Write(out.Bytes())
+	return err
+}
+
+func (p *Printer) render(out *bytes.Buffer, d Diagnostic) {
+	out.WriteString(d.Severity.String())
+	if d.Code != "" {
+		out.WriteString("[" + d.Code + "]")
+	}
+	out.WriteString(": " + d.Message + "\n")
+
+	p.excerpt(out, d.Span)
+
+	for _, hint := range d.Hints {
+		out.WriteString("  = hint: " + hint + "\n")
+	}
+
+	for _, rel := range d.Related {
+		out.WriteString("  note: " + rel.Message + "\n")
+		p.excerpt(out, rel.Span)
+	}
+
+	out.WriteString("\n")
+}
+
+func (p *Printer) excerpt(out *bytes.Buffer, span Span) {
+	out.WriteString("  --> " + span.Start.String() + "\n")
+
+	src, ok := p.sources[span.Start.Filename]
+	if !ok || !span.Start.IsValid() || span.Start.Offset > len(src) {
+		return
+	}
+
+	lineStart := strings.LastIndexByte(src[:span.Start.Offset], '\n') + 1
+	lineEnd := strings.IndexByte(src[span.Start.Offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(src)
+	} else {
+		lineEnd += span.Start.Offset
+	}
+	line := strings.TrimRight(src[lineStart:lineEnd], "\r")
+
+	end := span.End.Offset
+	if end > lineStart+len(line) || span.End.Line != span.Start.Line {
+		end = lineStart + len(line)
+	}
+
+	// keep tabs so the caret lines up with the quoted source
+	var pad strings.Builder
+	for _, r := range src[lineStart:span.Start.Offset] {
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	width := 1
+	if end > span.Start.Offset {
+		width = utf8.RuneCountInString(src[span.Start.Offset:end])
+	}
+
+	num := strconv.Itoa(span.Start.Line)
+	gutter := strings.Repeat(" ", len(num))
+
+	fmt.Fprintf(out, "%s |\n", gutter)
+	fmt.Fprintf(out, "%s | %s\n", num, line)
+	fmt.Fprintf(out, "%s | %s%s\n", gutter, pad.String(), strings.Repeat("^", width))
+}
@@ -0,0 +1,380 @@
+package digo
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// adapt wraps the Go function fn in a builtin called name, checking that
+// the types of its parameters and results can be converted.
+func adapt(name string, fn interface{}) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("cannot register %s: %T is not a function", name, fn)
+	}
+	t := v.Type()
+
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		if t.IsVariadic() && i == t.NumIn()-1 {
+			in = in.Elem()
+		}
+		if !convertible(in) {
+			return nil, fmt.Errorf("cannot register %s: unsupported parameter type %s", name, in)
+		}
+	}
+
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("cannot register %s: too many results", name)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("cannot register %s: second result must be an error", name)
+	case t.NumOut() > 0 && t.Out(0) != errorType && !convertible(t.Out(0)):
+		return nil, fmt.Errorf("cannot register %s: unsupported result type %s", name, t.Out(0))
+	}
+
+	return &object.Builtin{
+		Fn: func(_ *object.State, args ...object.Object) object.Object {
+			in, err := arguments(name, t, args)
+			if err != nil {
+				return err
+			}
+			return call(name, v, in)
+		},
+	}, nil
+}
+
+// call calls the function fn of the builtin called name and converts its
+// results. A panic in fn is raised in the script as an error it can catch,
+// rather than ending the host program.
+func call(name string, fn reflect.Value, in []reflect.Value) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = eval.NewError("`%s` panicked: %v", name, r)
+		}
+	}()
+
+	return results(name, fn.Call(in))
+}
+
+// arguments converts the arguments of a call of the builtin called name to
+// the parameter types of t.
+func arguments(name string, t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	n := t.NumIn()
+	if t.IsVariadic() {
+		if len(args) < n-1 {
+			return nil, eval.NewError("wrong number of arguments. got=%d, want=at least %d", len(args), n-1)
+		}
+	} else if len(args) != n {
+		return nil, eval.NewError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			// the result of a builtin returning nothing
+			arg = eval.NULL
+		}
+
+		var param reflect.Type
+		if t.IsVariadic() && i >= n-1 {
+			param = t.In(n - 1).Elem()
+		} else {
+			param = t.In(i)
+		}
+
+		v, ok := fromObject(arg, param)
+		if !ok {
+			return nil, eval.NewError("argument %d to `%s` must be %s, got %s",
+				i+1, name, typeName(param), arg.Type())
+		}
+		in[i] = v
+	}
+
+	return in, nil
+}
+
+// results converts the results of a call of the builtin called name.
+func results(name string, out []reflect.Value) object.Object {
+	if n := len(out); n > 0 && out[n-1].Type() == errorType {
+		if err := out[n-1]; !err.IsNil() {
+			return eval.NewError("%s", err.Interface().(error).Error())
+		}
+		out = out[:n-1]
+	}
+
+	if len(out) == 0 {
+		return eval.NULL
+	}
+
+	obj, err := toObject(out[0])
+	if err != nil {
+		return eval.NewError("result of `%s`: %v", name, err)
+	}
+	return obj
+}
+
+// convertible reports whether values of type t can be converted to and
+// from objects.
+func convertible(t reflect.Type) bool {
+	if t.Implements(objectType) || objectType.AssignableTo(t) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	case reflect.Slice, reflect.Ptr:
+		return convertible(t.Elem())
+	case reflect.Map:
+		return convertible(t.Key()) && convertible(t.Elem())
+	case reflect.Interface:
+		return t.NumMethod() == 0
+	}
+
+	return false
+}
+
+// typeName names the type of the objects a value of Go type t is converted
+// from, for error messages.
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return object.INTEGER_OBJ
+	case reflect.Float32, reflect.Float64:
+		return object.FLOAT_OBJ
+	case reflect.String:
+		return object.STRING_OBJ
+	case reflect.Bool:
+		return object.BOOLEAN_OBJ
+	case reflect.Slice:
+		return object.ARRAY_OBJ + " of " + typeName(t.Elem())
+	case reflect.Map:
+		return object.HASH_OBJ + " of " + typeName(t.Key()) + " to " + typeName(t.Elem())
+	case reflect.Ptr:
+		return typeName(t.Elem())
+	}
+	return t.String()
+}
+
+// fromObject converts obj to a value of type t, reporting whether it could.
+// An empty interface receives the Go value closest to obj.
+func fromObject(obj object.Object, t reflect.Type) (reflect.Value, bool) {
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		if v := goValue(obj); v != nil {
+			return reflect.ValueOf(v), true
+		}
+		return reflect.Zero(t), true
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), true
+	}
+
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*object.Integer)
+		if !ok || v.OverflowInt(i.Value) {
+			return v, false
+		}
+		v.SetInt(i.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := obj.(*object.Integer)
+		if !ok || i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+			return v, false
+		}
+		v.SetUint(uint64(i.Value))
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			v.SetFloat(n.Value)
+		case *object.Integer:
+			v.SetFloat(float64(n.Value))
+		default:
+			return v, false
+		}
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return v, false
+		}
+		v.SetString(s.Value)
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return v, false
+		}
+		v.SetBool(b.Value)
+	case reflect.Slice:
+		arr, ok := obj.(*object.Array)
+		if !ok {
+			return v, false
+		}
+		v = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			ev, ok := fromObject(el, t.Elem())
+			if !ok {
+				return v, false
+			}
+			v.Index(i).Set(ev)
+		}
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return v, false
+		}
+		v = reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Ordered() {
+			key, ok := fromObject(pair.Key, t.Key())
+			if !ok {
+				return v, false
+			}
+			val, ok := fromObject(pair.Value, t.Elem())
+			if !ok {
+				return v, false
+			}
+			v.SetMapIndex(key, val)
+		}
+	case reflect.Ptr:
+		if obj == eval.NULL {
+			return v, true
+		}
+		elem, ok := fromObject(obj, t.Elem())
+		if !ok {
+			return v, false
+		}
+		v.Set(reflect.New(t.Elem()))
+		v.Elem().Set(elem)
+	default:
+		return v, false
+	}
+
+	return v, true
+}
+
+// goValue returns the Go value closest to obj: an int64, float64, string,
+// bool, []interface{} or map[interface{}]interface{}, nil for null, or obj
+// itself if it has no Go counterpart.
+func goValue(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value
+	case *object.Float:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return nil
+	case *object.Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = goValue(el)
+		}
+		return elements
+	case *object.Hash:
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Ordered() {
+			m[goValue(pair.Key)] = goValue(pair.Value)
+		}
+		return m
+	}
+	return obj
+}
+
+// toObject converts the Go value v to an object.
+func toObject(v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return eval.NULL, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return eval.NULL, nil
+		}
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return eval.NULL, nil
+		}
+		return toObject(v.Elem())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return eval.TRUE, nil
+		}
+		return eval.FALSE, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		return toHash(v)
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a value", v.Type())
+}
+
+// toHash converts the map v to a hash, with its keys in sorted order.
+func toHash(v reflect.Value) (object.Object, error) {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	hash := &object.Hash{}
+	for _, k := range keys {
+		key, err := toObject(k)
+		if err != nil {
+			return nil, err
+		}
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		val, err := toObject(v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+
+		hash.Set(hashable.HashKey(), object.HashPair{Key: key, Value: val})
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,223 @@
+// Package digo embeds the digo interpreter in Go programs.
+//
+// An Interpreter keeps the global variables of the programs it evaluates, so
+// later calls of Eval see the variables of earlier ones, as lines of the REPL
+// do. Go values and functions are made available to scripts with Set and
+// Register, and script functions are called from Go with Call:
+//
+//	in := digo.New()
+//	in.Register("shout", strings.ToUpper)
+//	in.Eval(ctx, `let greet = fn(name) { shout("hello " + name) }`)
+//	result, err := in.Call("greet", "world")
+package digo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
+	"github.com/threeaccents/digolang/types"
+)
+
+// Interpreter evaluates programs in an environment of globals it keeps
+// between them. It is not safe for concurrent use.
+type Interpreter struct {
+	env *object.Environment
+
+	filename   string
+	searchPath []string
+	limits     eval.Limits
+	typeCheck  bool
+}
+
+// Option configures an Interpreter.
+type Option func(*Interpreter)
+
+// WithLimits bounds the resources each call of Eval or Call may use,
+// including the depth of nested function calls.
+func WithLimits(limits eval.Limits) Option {
+	return func(in *Interpreter) { in.limits = limits }
+}
+
+// WithSearchPath sets the directories modules are looked up in, in place of
+// those listed in the DIGOPATH environment variable.
+func WithSearchPath(dirs ...string) Option {
+	return func(in *Interpreter) { in.searchPath = dirs }
+}
+
+// WithFilename sets the name of the file the evaluated source comes from,
+// which positions in errors refer to and relative imports are resolved
+// against.
+func WithFilename(name string) Option {
+	return func(in *Interpreter) { in.filename = name }
+}
+
+// WithTypeCheck makes Eval check the type annotations of a program before
+// running it, returning the type errors found instead of running it.
+func WithTypeCheck() Option {
+	return func(in *Interpreter) { in.typeCheck = true }
+}
+
+// New returns an Interpreter with no globals but the builtins.
+func New(opts ...Option) *Interpreter {
+	in := &Interpreter{searchPath: eval.DefaultSearchPath()}
+	for _, opt := range opts {
+		opt(in)
+	}
+
+	path := in.filename
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	in.env = object.NewModuleEnvironment(path, eval.NewLoader(in.searchPath...))
+
+	return in
+}
+
+// DiagnosticError is returned by Eval for a program that does not parse, or
+// that does not type check when type checking is enabled.
+type DiagnosticError struct {
+	Diagnostics []diag.Diagnostic
+}
+
+func (e *DiagnosticError) Error() string {
+	var msgs []string
+	for _, d := range e.Diagnostics {
+		if d.Severity == diag.Error {
+			msgs = append(msgs, d.Error())
+		}
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// RuntimeError is returned for an error a program raised and did not catch.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	kind := e.Err.Kind
+	if kind == "" {
+		kind = "Error"
+	}
+	msg := kind + ": " + e.Err.Message
+	if e.Err.Pos.IsValid() {
+		return e.Err.Pos.String() + ": " + msg
+	}
+	return msg
+}
+
+// Traceback formats the error along with the calls it unwound through.
+func (e *RuntimeError) Traceback() string {
+	return e.Err.Traceback()
+}
+
+// Eval runs src, returning the value of its last statement. The evaluation
+// stops with an *eval.LimitError once ctx is done or it exceeds the limits
+// of the interpreter.
+func (in *Interpreter) Eval(ctx context.Context, src string) (object.Object, error) {
+	l := lexer.New(src)
+	if in.filename != "" {
+		l = lexer.NewFile(in.filename, src)
+	}
+
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &DiagnosticError{Diagnostics: p.Errors()}
+	}
+
+	if in.typeCheck {
+		if diags := types.Check(program); diag.HasErrors(diags) {
+			return nil, &DiagnosticError{Diagnostics: diags}
+		}
+	}
+
+	resolver.Resolve(program)
+
+	return result(eval.EvalContext(ctx, program, in.env, in.limits))
+}
+
+// Call calls the global function called name with args, converted to values
+// as by Set.
+func (in *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	fn, ok := in.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined: %s", name)
+	}
+
+	objs := make([]object.Object, len(args))
+	for i, arg := range args {
+		obj, err := toObject(reflect.ValueOf(arg))
+		if err != nil {
+			return nil, fmt.Errorf("argument %d to %s: %v", i+1, name, err)
+		}
+		objs[i] = obj
+	}
+
+	return result(eval.RunContext(context.Background(), in.env, in.limits, func() object.Object {
+		return eval.Call(in.env.State(), fn, objs, nil)
+	}))
+}
+
+// Set sets the global variable called name to value. Integers, floats,
+// strings and booleans become the values of the same type, slices and
+// arrays become arrays, maps become hashes and nil becomes null; values
+// that already are objects are used as they are. Functions are registered
+// as by Register.
+func (in *Interpreter) Set(name string, value interface{}) error {
+	if reflect.ValueOf(value).Kind() == reflect.Func {
+		return in.Register(name, value)
+	}
+
+	obj, err := toObject(reflect.ValueOf(value))
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %v", name, err)
+	}
+
+	in.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the value of the global variable called name.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	return in.env.Get(name)
+}
+
+// Register makes the Go function fn callable by scripts as name. Arguments
+// are converted to the types of the parameters of fn, failing the call if
+// they cannot be, and results are converted to values as by Set. A final
+// error result is raised as a runtime error when it is not nil, which
+// scripts may catch.
+func (in *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := adapt(name, fn)
+	if err != nil {
+		return err
+	}
+
+	in.env.Set(name, builtin)
+	return nil
+}
+
+// result turns an error the program did not catch into a *RuntimeError.
+func result(obj object.Object, err error) (object.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if e, ok := obj.(*object.Error); ok && !e.Handled {
+		return nil, &RuntimeError{Err: e}
+	}
+
+	return obj, nil
+}
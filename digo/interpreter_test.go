@@ -0,0 +1,253 @@
+package digo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/object"
+)
+
+func testEval(t *testing.T, in *Interpreter, src string) object.Object {
+	t.Helper()
+
+	result, err := in.Eval(context.Background(), src)
+	if err != nil {
+		t.Fatalf("Eval(%q) failed: %v", src, err)
+	}
+	return result
+}
+
+func TestEvalKeepsGlobals(t *testing.T) {
+	in := New()
+
+	testEval(t, in, "let x = 40")
+	testEval(t, in, "let add = fn(a, b) { a + b }")
+
+	if got := testEval(t, in, "add(x, 2)").Inspect(); got != "42" {
+		t.Errorf("wrong result. want=42, got=%s", got)
+	}
+}
+
+func TestSetAndGet(t *testing.T) {
+	in := New()
+
+	values := []struct {
+		name     string
+		value    interface{}
+		expected string
+	}{
+		{"i", 3, "3"},
+		{"u", uint8(7), "7"},
+		{"f", 1.5, "1.5"},
+		{"s", "hi", "hi"},
+		{"b", true, "true"},
+		{"n", nil, "null"},
+		{"xs", []int{1, 2}, "[1, 2]"},
+		{"h", map[string]int{"b": 2, "a": 1}, `{"a": 1, "b": 2}`},
+		{"o", &object.Integer{Value: 9}, "9"},
+	}
+
+	for _, v := range values {
+		if err := in.Set(v.name, v.value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", v.name, err)
+		}
+		obj, ok := in.Get(v.name)
+		if !ok {
+			t.Fatalf("Get(%q) found nothing", v.name)
+		}
+		if obj.Inspect() != v.expected {
+			t.Errorf("Get(%q) wrong. want=%s, got=%s", v.name, v.expected, obj.Inspect())
+		}
+	}
+
+	// booleans must be the singletons the evaluator compares against
+	if got := testEval(t, in, `if (b) { "yes" } else { "no" }`).Inspect(); got != "yes" {
+		t.Errorf("wrong result. want=yes, got=%s", got)
+	}
+	if got := testEval(t, in, "isNull(n)"); got != eval.TRUE {
+		t.Errorf("isNull(n) wrong. got=%s", got.Inspect())
+	}
+
+	if err := in.Set("c", make(chan int)); err == nil {
+		t.Errorf("Set of a channel did not fail")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	in := New()
+
+	register := func(name string, fn interface{}) {
+		if err := in.Register(name, fn); err != nil {
+			t.Fatalf("Register(%q) failed: %v", name, err)
+		}
+	}
+
+	register("startsWith", func(s string, n int) (bool, error) {
+		if n < 0 {
+			return false, errors.New("negative length")
+		}
+		return len(s) >= n && strings.HasPrefix(s, strings.Repeat("a", n)), nil
+	})
+	register("sum", func(xs ...float64) float64 {
+		total := 0.0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	})
+	register("keys", func(h map[string]int) []string {
+		var keys []string
+		for k := range h {
+			keys = append(keys, k)
+		}
+		return keys
+	})
+	register("describe", func(v interface{}) string {
+		switch v.(type) {
+		case int64:
+			return "int"
+		case []interface{}:
+			return "array"
+		case nil:
+			return "null"
+		}
+		return "other"
+	})
+	register("length", func(arr *object.Array) int { return len(arr.Elements) })
+	register("nothing", func() {})
+	register("boom", func() int { panic("x") })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`startsWith("aab", 2)`, "true"},
+		{`startsWith("ab", 2)`, "false"},
+		{"sum()", "0.0"},
+		{"sum(1, 2.5)", "3.5"},
+		{`keys({"a": 1})`, `["a"]`},
+		{"describe(1)", "int"},
+		{"describe([1])", "array"},
+		{"describe(nothing())", "null"},
+		{"length([1, 2, 3])", "3"},
+		{`try { startsWith("a", -1) } catch (e) { e.message }`, "negative length"},
+		{"try { boom() } catch (e) { e.message }", "`boom` panicked: x"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(t, in, tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s: wrong result. want=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+
+	errs := []struct {
+		input    string
+		expected string
+	}{
+		{`startsWith("a")`, "RuntimeError: wrong number of arguments. got=1, want=2"},
+		{`startsWith(1, 2)`, "RuntimeError: argument 1 to `startsWith` must be STRING, got INTEGER"},
+		{`keys({"a": "b"})`, "RuntimeError: argument 1 to `keys` must be HASH of STRING to INTEGER, got HASH"},
+		{`startsWith("a", -1)`, "RuntimeError: negative length"},
+		{"boom()", "RuntimeError: `boom` panicked: x"},
+	}
+
+	for _, tt := range errs {
+		_, err := in.Eval(context.Background(), tt.input)
+
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Errorf("%s: expected a RuntimeError, got %v", tt.input, err)
+			continue
+		}
+		if !strings.HasSuffix(err.Error(), tt.expected) {
+			t.Errorf("%s: wrong error. want=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+
+	invalid := []interface{}{
+		42,
+		func(c chan int) {},
+		func() (int, int) { return 0, 0 },
+		func() (int, int, error) { return 0, 0, nil },
+	}
+	for _, fn := range invalid {
+		if err := in.Register("bad", fn); err == nil {
+			t.Errorf("Register(%T) did not fail", fn)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	in := New()
+	in.Register("shout", strings.ToUpper)
+	testEval(t, in, `let greet = fn(name, greeting = "hello") { shout(greeting + " " + name) }`)
+
+	result, err := in.Call("greet", "world")
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if got := result.Inspect(); got != "HELLO WORLD" {
+		t.Errorf("wrong result. want=HELLO WORLD, got=%s", got)
+	}
+
+	if _, err := in.Call("missing"); err == nil || err.Error() != "undefined: missing" {
+		t.Errorf("wrong error for an undefined function: %v", err)
+	}
+
+	if _, err := in.Call("greet"); err == nil {
+		t.Errorf("call with too few arguments did not fail")
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	ctx := context.Background()
+
+	var diagErr *DiagnosticError
+	if _, err := New().Eval(ctx, "let = 1"); !errors.As(err, &diagErr) {
+		t.Errorf("expected a DiagnosticError for a syntax error, got %v", err)
+	}
+
+	checked := New(WithTypeCheck(), WithFilename("main.digo"))
+	_, err := checked.Eval(ctx, `let x: int = "a"`)
+	if !errors.As(err, &diagErr) || err.Error() != "main.digo:1:14: cannot use string as int in declaration of x" {
+		t.Errorf("wrong type checking error: %v", err)
+	}
+
+	_, err = New(WithLimits(eval.Limits{MaxSteps: 100})).Eval(ctx, "while (true) { 1 }")
+	if !errors.Is(err, eval.ErrStepLimit) {
+		t.Errorf("expected the step limit to be exceeded, got %v", err)
+	}
+
+	_, err = New(WithFilename("main.digo")).Eval(ctx, "let f = fn() { 1 + true }; f()")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError, got %v", err)
+	}
+	if !strings.Contains(runtimeErr.Traceback(), "main.digo:1:16, in f") {
+		t.Errorf("traceback does not point at the error:\n%s", runtimeErr.Traceback())
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	shallow := New(WithLimits(eval.Limits{MaxDepth: 10}))
+	deep := New()
+
+	src := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }"
+	testEval(t, shallow, src)
+	testEval(t, deep, src)
+
+	if _, err := shallow.Call("f", 20); err == nil || !strings.HasSuffix(err.Error(), "maximum call depth of 10 exceeded") {
+		t.Errorf("expected a stack overflow, got %v", err)
+	}
+
+	result, err := deep.Call("f", 20)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if got := result.Inspect(); got != "20" {
+		t.Errorf("wrong result. want=20, got=%s", got)
+	}
+}
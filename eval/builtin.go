@@ -2,13 +2,17 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/threeaccents/digolang/object"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			if len(args) > 1 || len(args) == 0 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -17,20 +21,163 @@ var builtins = map[string]*object.Builtin{
 			switch arg := args[0].(type) {
 			case *object.String:
 				return &object.Integer{
-					Value: int64(len(arg.Value)),
+					Value: int64(utf8.RuneCountInString(arg.Value)),
 				}
 			case *object.Array:
 				return &object.Integer{
 					Value: int64(len(arg.Elements)),
 				}
+			case *object.Range:
+				return &object.Integer{
+					Value: arg.Len(),
+				}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
 			}
 		},
 	},
+	"byteLen": &object.Builtin{
+		Fn: func(state *object.State, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `byteLen` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			return &object.Integer{
+				Value: int64(len(args[0].(*object.String).Value)),
+			}
+		},
+	},
+	"byteAt": &object.Builtin{
+		Fn: func(state *object.State, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("first argument to `byteAt` must be STRING, got %s",
+					args[0].Type())
+			}
+			if args[1].Type() != object.INTEGER_OBJ {
+				return newError("second argument to `byteAt` must be INTEGER, got %s",
+					args[1].Type())
+			}
+
+			str := args[0].(*object.String).Value
+			i := args[1].(*object.Integer).Value
+			if i < 0 || i >= int64(len(str)) {
+				return NULL
+			}
+
+			return &object.Integer{Value: int64(str[i])}
+		},
+	},
+	"int": &object.Builtin{
+		Fn: func(state *object.State, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError("cannot convert %s to INTEGER", arg.Quoted())
+				}
+				return &object.Integer{Value: value}
+			default:
+				return newError("argument to `int` not supported, got %s",
+					args[0].Type())
+			}
+		},
+	},
+	"float": &object.Builtin{
+		Fn: func(state *object.State, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.Float:
+				return arg
+			case *object.String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("cannot convert %s to FLOAT", arg.Quoted())
+				}
+				return &object.Float{Value: value}
+			default:
+				return newError("argument to `float` not supported, got %s",
+					args[0].Type())
+			}
+		},
+	},
+	"array": &object.Builtin{
+		Fn: func(state *object.State, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch args[0].(type) {
+			case *object.Array, *object.Hash, *object.String, *object.Range:
+			default:
+				return newError("argument to `array` not supported, got %s",
+					args[0].Type())
+			}
+
+			elements, err := spread(state, args[0])
+			if err != nil {
+				return err
+			}
+
+			return &object.Array{Elements: elements}
+		},
+	},
+	"error": &object.Builtin{
+		Fn: func(state *object.State, args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1..2",
+					len(args))
+			}
+
+			for _, arg := range args {
+				if arg.Type() != object.STRING_OBJ {
+					return newError("argument to `error` must be STRING, got %s",
+						arg.Type())
+				}
+			}
+
+			err := &object.Error{
+				Message: args[0].(*object.String).Value,
+				Kind:    thrownError,
+				Handled: true,
+			}
+			if len(args) == 2 {
+				err.Kind = args[1].(*object.String).Value
+			}
+
+			return err
+		},
+	},
 	"isNull": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			if len(args) > 1 || len(args) == 0 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -46,7 +193,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"println": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			var msgs []interface{}
 
 			for _, arg := range args {
@@ -59,7 +206,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -78,7 +225,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -98,7 +245,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1",
 					len(args))
@@ -111,6 +258,9 @@ var builtins = map[string]*object.Builtin{
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
 			if length > 0 {
+				if err := charge(state, int64(length-1)); err != nil {
+					return err
+				}
 				newElements := make([]object.Object, length-1, length-1)
 				copy(newElements, arr.Elements[1:length])
 				return &object.Array{Elements: newElements}
@@ -120,7 +270,7 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(state *object.State, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newError("wrong number of arguments. got=%d, want=2",
 					len(args))
@@ -132,6 +282,9 @@ var builtins = map[string]*object.Builtin{
 
 			arr := args[0].(*object.Array)
 			length := len(arr.Elements)
+			if err := charge(state, int64(length+1)); err != nil {
+				return err
+			}
 
 			newElements := make([]object.Object, length+1, length+1)
 			copy(newElements, arr.Elements)
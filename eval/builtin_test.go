@@ -18,7 +18,7 @@ func TestIsNullBuiltinFunctions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		switch expected := tt.expected.(type) {
 		case bool:
@@ -46,7 +46,7 @@ func TestPrintlnBuiltinFunctions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		if evaluated != nil {
 			t.Errorf("wrong type. got=%T (%+v)", evaluated, evaluated)
@@ -63,12 +63,20 @@ func TestLenBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("é")`, 1},
+		{`len("größe 😀")`, 7},
+		{`byteLen("é")`, 2},
+		{`byteLen("größe 😀")`, 12},
+		{`byteLen([])`, "argument to `byteLen` must be STRING, got ARRAY"},
+		{`byteAt("é", 0)`, 0xc3},
+		{`byteAt("é", 1)`, 0xa9},
+		{`byteAt("é", "0")`, "second argument to `byteAt` must be INTEGER, got STRING"},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		switch expected := tt.expected.(type) {
 		case int:
@@ -87,3 +95,42 @@ func TestLenBuiltinFunctions(t *testing.T) {
 		}
 	}
 }
+
+func TestNumberConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(7)`, 7},
+		{`int(" 42 ")`, 42},
+		{`int("4.2")`, `cannot convert "4.2" to INTEGER`},
+		{`int(1e300)`, "cannot convert 1e+300 to INTEGER"},
+		{`int(true)`, "argument to `int` not supported, got BOOLEAN"},
+		{`float(2)`, 2.0},
+		{`float("2.5")`, 2.5},
+		{`float("abc")`, `cannot convert "abc" to FLOAT`},
+		{`float()`, "wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
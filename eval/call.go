@@ -0,0 +1,85 @@
+package eval
+
+import (
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/token"
+)
+
+// DefaultMaxDepth is the maximum number of nested function calls of an
+// evaluation whose Limits do not set one. A call beyond it raises a stack
+// overflow error instead. Tail calls do not nest.
+const DefaultMaxDepth = 10000
+
+// CheckDepth returns the stack overflow error for a function call made in
+// the evaluation state belongs to, or nil if the call may nest one deeper.
+func CheckDepth(state *object.State) *object.Error {
+	max := state.MaxDepth
+	if max == 0 {
+		max = DefaultMaxDepth
+	}
+
+	if state.Depth >= max {
+		return newError("stack overflow: maximum call depth of %d exceeded", max)
+	}
+	return nil
+}
+
+// tailCall is a call of a function in tail position. It is returned to the
+// evalFunctionLiteral running the calling function, which makes the call in
+// place of the current one rather than nesting it.
+type tailCall struct {
+	fn     *object.Function
+	caller *object.Function
+	args   []object.Object
+	kwargs []KeywordArg
+	pos    token.Position
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalCallExpression evaluates a call. When tail is set, a call of a
+// function is returned as a tailCall instead of being made.
+func evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	function := Eval(node.Function, env)
+	if unwinds(function) {
+		return function
+	}
+	args, kwargs, err := evalArguments(node.Arguments, env)
+	if err != nil {
+		return err
+	}
+
+	if fn, ok := function.(*object.Function); ok && tail {
+		return &tailCall{fn: fn, args: args, kwargs: kwargs, pos: node.Pos()}
+	}
+
+	result := applyFunction(env.State(), function, args, kwargs)
+
+	if _, ok := frameName(function); ok && isError(result) {
+		// record where the call applyFunction added a frame for was made
+		if err := result.(*object.Error); err.Pos.IsValid() {
+			err.Stack[len(err.Stack)-1].Pos = node.Pos()
+		}
+	}
+	return result
+}
+
+// addTailFrames records the last tail call made before fn raised err. The
+// frames of the tail calls before it are gone; the trace skips from the
+// function originally called to the one that made the last tail call.
+func addTailFrames(err *object.Error, fn *object.Function, tail *tailCall, original *object.Function) {
+	if err.Pos.IsValid() {
+		name, _ := frameName(fn)
+		err.Stack = append(err.Stack, object.Frame{Function: name, Pos: tail.pos})
+	} else {
+		// the call itself failed
+		err.Pos = tail.pos
+	}
+
+	if tail.caller != original {
+		name, _ := frameName(tail.caller)
+		err.Stack = append(err.Stack, object.Frame{Function: name})
+	}
+}
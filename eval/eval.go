@@ -2,6 +2,9 @@ package eval
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/threeaccents/digolang/ast"
 	"github.com/threeaccents/digolang/object"
@@ -11,9 +14,41 @@ var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(n ast.Node, env *object.Environment) object.Object {
+	state := env.State()
+	if err := step(state); err != nil {
+		setErrorPos(err, n)
+		return err
+	}
+
+	result := evalNode(n, env)
+
+	switch n.(type) {
+	case *ast.HashLiteral, *ast.InfixExpression, *ast.InterpolatedString, *ast.SliceExpression:
+		if err := allocate(state, result); err != nil {
+			result = err
+		}
+	}
+	setErrorPos(result, n)
+
+	return result
+}
+
+// setErrorPos records n as the place obj was raised if obj is an error
+// without a position. The innermost node an error passes through is where
+// it was raised.
+func setErrorPos(obj object.Object, n ast.Node) {
+	if err, ok := obj.(*object.Error); ok && !err.Handled && !err.Pos.IsValid() {
+		err.Pos = n.Pos()
+	}
+}
+
+func evalNode(n ast.Node, env *object.Environment) object.Object {
 	switch node := n.(type) {
 	case *ast.Program:
 		return evalProgram(node.Statements, env)
@@ -25,73 +60,106 @@ func Eval(n ast.Node, env *object.Environment) object.Object {
 		return &object.Integer{
 			Value: node.Value,
 		}
+	case *ast.FloatLiteral:
+		return &object.Float{
+			Value: node.Value,
+		}
 	case *ast.StringLiteral:
 		return &object.String{
 			Value: node.Value,
 		}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
-		if len(elements) == 1 && isError(elements[0]) {
-			return elements[0]
-		}
-		return &object.Array{Elements: elements}
+		return evalArrayLiteral(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	case *ast.BooleanLiteral:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
 		right := Eval(node.Right, env)
-		if isError(right) {
+		if unwinds(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if unwinds(left) {
 			return left
 		}
 		index := Eval(node.Index, env)
-		if isError(index) {
+		if unwinds(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
-	case *ast.InfixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
-			return right
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.SelectorExpression:
+		left := Eval(node.Left, env)
+		if unwinds(left) {
+			return left
 		}
+		return evalSelectorExpression(left, node.Selector.Value)
+	case *ast.SpreadExpression:
+		return newError("unexpected spread: %s", node.String())
+	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
-		if isError(left) {
+		if unwinds(left) {
 			return left
 		}
+		if shortCircuits(node.Operator, left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if unwinds(right) {
+			return right
+		}
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
 
 		return evalIfExpression(node, env)
 	case *ast.LetStatement:
-		evalLetStatement(node, env)
+		return evalLetStatement(node, env)
+	case *ast.ExportStatement:
+		return evalLetStatement(node.Statement, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-
-		return applyFunction(function, args)
+		return evalCallExpression(node, env, false)
 	case *ast.FunctionLiteral:
 		return &object.Function{
+			Name:       node.Name,
 			Body:       node.Body,
 			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
 			Env:        env,
 		}
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
-		if isError(val) {
+		var val object.Object
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok && node.TailCall {
+			val = evalCallExpression(call, env, true)
+			setErrorPos(val, call)
+		} else {
+			val = Eval(node.ReturnValue, env)
+		}
+		if unwinds(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
@@ -105,28 +173,28 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		Pairs: make(map[object.HashKey]object.HashPair),
 	}
 
-	for pkey, pvalue := range node.Pairs {
+	for _, pkey := range node.Keys {
 		key := Eval(pkey, env)
-		if isError(key) {
-			return nil
+		if unwinds(key) {
+			return key
 		}
 
 		hashable, ok := key.(object.Hashable)
 		if !ok {
-			return newError("error")
+			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(pvalue, env)
-		if isError(key) {
-			return nil
+		value := Eval(node.Pairs[pkey], env)
+		if unwinds(value) {
+			return value
 		}
 
 		hashKey := hashable.HashKey()
 
-		hash.Pairs[hashKey] = object.HashPair{
+		hash.Set(hashKey, object.HashPair{
 			Key:   key,
 			Value: value,
-		}
+		})
 	}
 
 	return hash
@@ -138,6 +206,10 @@ func evalIndexExpression(left object.Object, index object.Object) object.Object
 		return evalArrayIndexExpression(left, index)
 	case object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case object.STRING_OBJ:
+		return evalStringIndexExpression(left, index)
+	case object.RANGE_OBJ:
+		return evalRangeIndexExpression(left, index)
 	default:
 		return newError("")
 	}
@@ -171,64 +243,483 @@ func evalArrayIndexExpression(left object.Object, index object.Object) object.Ob
 	}
 
 	elements := left.(*object.Array).Elements
-	indexVal := index.(*object.Integer).Value
 
-	if indexVal < 0 || indexVal > int64(len(elements)-1) {
+	i, ok := normalizeIndex(index.(*object.Integer).Value, int64(len(elements)))
+	if !ok {
 		return NULL
 	}
 
-	return elements[indexVal]
+	return elements[i]
+}
+
+// evalStringIndexExpression indexes a string by rune, returning the rune at
+// that position as a string.
+func evalStringIndexExpression(left object.Object, index object.Object) object.Object {
+	if index.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: %s%s%s", "[", index.Type(), "]")
+	}
+
+	runes := []rune(left.(*object.String).Value)
+
+	i, ok := normalizeIndex(index.(*object.Integer).Value, int64(len(runes)))
+	if !ok {
+		return NULL
+	}
+
+	return &object.String{Value: string(runes[i])}
+}
+
+func evalRangeIndexExpression(left object.Object, index object.Object) object.Object {
+	if index.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: %s%s%s", "[", index.Type(), "]")
+	}
+
+	v, ok := left.(*object.Range).Index(index.(*object.Integer).Value)
+	if !ok {
+		return NULL
+	}
+
+	return &object.Integer{Value: v}
+}
+
+// normalizeIndex resolves a negative index, which counts from the end, and
+// reports whether the result lies within a sequence of the given length.
+func normalizeIndex(i, length int64) (int64, bool) {
+	if i < 0 {
+		i += length
+	}
+
+	return i, i >= 0 && i < length
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if unwinds(left) {
+		return left
+	}
+
+	var low, high object.Object
+	if node.Low != nil {
+		low = Eval(node.Low, env)
+		if unwinds(low) {
+			return low
+		}
+	}
+	if node.High != nil {
+		high = Eval(node.High, env)
+		if unwinds(high) {
+			return high
+		}
+	}
+
+	return evalSlice(left, low, high)
+}
+
+// evalSlice slices left between low and high, either of which may be nil.
+func evalSlice(left, low, high object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		lo, hi, err := sliceBounds(low, high, int64(len(left.Elements)))
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, hi-lo)
+		copy(elements, left.Elements[lo:hi])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		runes := []rune(left.Value)
+		lo, hi, err := sliceBounds(low, high, int64(len(runes)))
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: string(runes[lo:hi])}
+	case *object.Range:
+		lo, hi, err := sliceBounds(low, high, left.Len())
+		if err != nil {
+			return err
+		}
+		return &object.Range{Start: left.At(lo), End: left.At(hi)}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceBounds resolves the bounds of a slice of a sequence of the given
+// length. Missing bounds default to the start and end, negative bounds count
+// from the end, and bounds outside the sequence are clamped to it.
+func sliceBounds(low, high object.Object, length int64) (int64, int64, *object.Error) {
+	bound := func(obj object.Object, missing int64) (int64, *object.Error) {
+		if obj == nil {
+			return missing, nil
+		}
+		i, ok := obj.(*object.Integer)
+		if !ok {
+			return 0, newError("slice bounds must be INTEGER, got %s", obj.Type())
+		}
+
+		v := i.Value
+		if v < 0 {
+			v += length
+		}
+		if v < 0 {
+			v = 0
+		}
+		if v > length {
+			v = length
+		}
+		return v, nil
+	}
+
+	lo, err := bound(low, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := bound(high, length)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if lo > hi {
+		lo = hi
+	}
+
+	return lo, hi, nil
 }
 
-func evalLetStatement(node *ast.LetStatement, env *object.Environment) {
+func evalLetStatement(node *ast.LetStatement, env *object.Environment) object.Object {
 	if node.Expression == nil {
-		env.Set(node.Name.Value, NULL)
-		return
+		bind(env, node.Name, NULL)
+		return nil
 	}
 	val := Eval(node.Expression, env)
-	if isError(val) {
+	if unwinds(val) {
+		return val
+	}
+	bind(env, node.Name, val)
+	return nil
+}
+
+// locals returns the names of the slots of the environment block runs in.
+func locals(block *ast.BlockStatement) []string {
+	if block == nil {
+		return nil
+	}
+	return block.Locals
+}
+
+// bind binds the variable ident declares in env, in the slot the resolver
+// gave it if it has one.
+func bind(env *object.Environment, ident *ast.Identifier, val object.Object) {
+	if b := ident.Binding; b != nil && b.Depth == 0 {
+		env.SetSlot(b.Slot, val)
 		return
 	}
-	env.Set(node.Name.Value, val)
+	env.Set(ident.Value, val)
+}
+
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if unwinds(val) {
+		return val
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if node.Operator != "=" {
+			current := evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+			val = evalInfixExpression(compoundOperator(node.Operator), current, val)
+			if isError(val) {
+				return val
+			}
+		}
+		if b := target.Binding; b != nil && env.AssignSlot(b.Depth, b.Slot, val) {
+			return nil
+		}
+		if !env.Assign(target.Value, val) {
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+		return nil
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if unwinds(index) {
+			return index
+		}
+		return evalIndexAssignment(node.Operator, left, index, val)
+	case *ast.SelectorExpression:
+		left := Eval(target.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		return evalFieldAssignment(node.Operator, left, target.Selector.Value, val)
+	default:
+		return newError("cannot assign to %s", node.Target.String())
+	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// compoundOperator returns the infix operator of a compound assignment such
+// as `+=`.
+func compoundOperator(operator string) string {
+	return operator[:len(operator)-1]
+}
+
+// evalFieldAssignment stores val under the key name of a hash.
+func evalFieldAssignment(operator string, left object.Object, name string, val object.Object) object.Object {
+	if left.Type() != object.HASH_OBJ {
+		return newError("cannot assign to field %s of %s", name, left.Type())
+	}
+	return evalIndexAssignment(operator, left, &object.String{Value: name}, val)
+}
+
+// evalIndexAssignment stores val at index in an array or hash, mutating it
+// in place. Negative array indices count from the end, as they do when
+// reading.
+func evalIndexAssignment(operator string, left, index, val object.Object) object.Object {
+	switch container := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("unknown operator: %s%s%s", "[", index.Type(), "]")
+		}
+		n, ok := normalizeIndex(i.Value, int64(len(container.Elements)))
+		if !ok {
+			return newError("index out of range: %d", i.Value)
+		}
+		if operator != "=" {
+			val = evalInfixExpression(compoundOperator(operator), container.Elements[n], val)
+			if isError(val) {
+				return val
+			}
+		}
+		container.Elements[n] = val
+		return nil
+	case *object.Hash:
+		hashable, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		key := hashable.HashKey()
+		if operator != "=" {
+			pair, ok := container.Pairs[key]
+			if !ok {
+				return newError("key not found: %s", index.Inspect())
+			}
+			val = evalInfixExpression(compoundOperator(operator), pair.Value, val)
+			if isError(val) {
+				return val
+			}
+		}
+		container.Set(key, object.HashPair{Key: index, Value: val})
+		return nil
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+// KeywordArg is a `name = value` argument of a call.
+type KeywordArg struct {
+	Name  string
+	Value object.Object
+}
+
+func applyFunction(state *object.State, fn object.Object, args []object.Object, kwargs []KeywordArg) object.Object {
+	result := callFunction(state, fn, args, kwargs)
+
+	// errors raised by evaluating code inside the call, as opposed to errors
+	// about the call itself, record it in their stack
+	if name, ok := frameName(fn); ok && isError(result) {
+		if err := result.(*object.Error); err.Pos.IsValid() {
+			err.Stack = append(err.Stack, object.Frame{Function: name})
+		}
+	}
+
+	return result
+}
+
+func callFunction(state *object.State, fn object.Object, args []object.Object, kwargs []KeywordArg) object.Object {
 	switch funcType := fn.(type) {
 	case *object.Function:
-		return evalFunctionLiteral(funcType, args)
+		return evalFunctionLiteral(funcType, args, kwargs)
 	case *object.Builtin:
-		return evalBuiltin(funcType, args)
+		if len(kwargs) > 0 {
+			return newError("builtin functions do not accept keyword arguments")
+		}
+		return evalBuiltin(state, funcType, args)
+	case *object.Selector:
+		if len(kwargs) > 0 {
+			return newError("methods do not accept keyword arguments")
+		}
+		return applyMethod(state, funcType, args)
+	case *object.Closure:
+		names := make([]string, len(kwargs))
+		values := make([]object.Object, len(kwargs))
+		for i, kw := range kwargs {
+			names[i], values[i] = kw.Name, kw.Value
+		}
+		return funcType.Runtime.CallClosure(funcType, args, names, values)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func evalBuiltin(fn *object.Builtin, args []object.Object) object.Object {
-	return fn.Fn(args...)
+func evalBuiltin(state *object.State, fn *object.Builtin, args []object.Object) object.Object {
+	return fn.Fn(state, args...)
 }
 
-func evalFunctionLiteral(fn *object.Function, args []object.Object) object.Object {
-	extendedEnv := object.NewInnerEnvironment(fn.Env)
+func evalFunctionLiteral(fn *object.Function, args []object.Object, kwargs []KeywordArg) object.Object {
+	state := fn.Env.State()
+	if err := CheckDepth(state); err != nil {
+		return err
+	}
+	state.Depth++
+	defer func() { state.Depth-- }()
+
+	original := fn
+	var tail *tailCall
+
+	for {
+		var result object.Object
+		extendedEnv, err := bindArguments(fn, args, kwargs)
+		if err != nil {
+			result = err
+		} else {
+			result = Eval(fn.Body, extendedEnv)
+		}
+		if returnValue, ok := result.(*object.ReturnValue); ok {
+			result = returnValue.Value
+		}
+
+		next, ok := result.(*tailCall)
+		if !ok {
+			if tail != nil && isError(result) {
+				addTailFrames(result.(*object.Error), fn, tail, original)
+			}
+			return result
+		}
+
+		next.caller = fn
+		tail = next
+		fn, args, kwargs = next.fn, next.args, next.kwargs
+	}
+}
+
+// bindArguments returns the environment a call of fn runs in, with its
+// parameters bound to args and kwargs. Parameters left without an argument
+// take their default value, evaluated in that environment so a default can
+// refer to earlier parameters. A default that returns from the call ends
+// it like an error, with the returned value in place of the error.
+func bindArguments(fn *object.Function, args []object.Object, kwargs []KeywordArg) (*object.Environment, object.Object) {
+	env := object.NewScopeEnvironment(fn.Env, locals(fn.Body))
+
+	arityError := func() *object.Error {
+		return newError("wrong number of arguments. got=%d, want=%s",
+			len(args)+len(kwargs), arity(fn))
+	}
+
+	if len(args) > len(fn.Parameters) && fn.Rest == nil {
+		return nil, arityError()
+	}
+
+	bound := make([]bool, len(fn.Parameters))
+	for i := 0; i < len(args) && i < len(fn.Parameters); i++ {
+		bind(env, fn.Parameters[i], args[i])
+		bound[i] = true
+	}
+
+	for _, kw := range kwargs {
+		i := parameterIndex(fn, kw.Name)
+		if i < 0 {
+			return nil, newError("unexpected keyword argument: %s", kw.Name)
+		}
+		if bound[i] {
+			return nil, newError("multiple values for argument: %s", kw.Name)
+		}
+		env.Set(kw.Name, kw.Value)
+		bound[i] = true
+	}
+
+	for i, param := range fn.Parameters {
+		if bound[i] {
+			continue
+		}
+		if i >= len(fn.Defaults) || fn.Defaults[i] == nil {
+			return nil, arityError()
+		}
+
+		val := Eval(fn.Defaults[i], env)
+		if unwinds(val) {
+			return nil, val
+		}
+		bind(env, param, val)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		bind(env, fn.Rest, &object.Array{Elements: rest})
+	}
+
+	return env, nil
+}
 
-	for paramIdx, param := range fn.Parameters {
-		extendedEnv.Set(param.Value, args[paramIdx])
+func parameterIndex(fn *object.Function, name string) int {
+	for i, param := range fn.Parameters {
+		if param.Value == name {
+			return i
+		}
 	}
+	return -1
+}
 
-	evaluated := Eval(fn.Body, extendedEnv)
+// arity describes how many arguments fn accepts, e.g. "2", "1..3" or
+// "at least 1".
+func arity(fn *object.Function) string {
+	return Arity(fn.Parameters, fn.Defaults, fn.Rest)
+}
 
-	if returnValue, ok := evaluated.(*object.ReturnValue); ok {
-		return returnValue.Value
+// Arity describes how many arguments a function with the given parameters
+// accepts.
+func Arity(params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier) string {
+	required := 0
+	for i := range params {
+		if i >= len(defaults) || defaults[i] == nil {
+			required++
+		}
 	}
 
-	return evaluated
+	switch {
+	case rest != nil:
+		return fmt.Sprintf("at least %d", required)
+	case required == len(params):
+		return fmt.Sprintf("%d", required)
+	default:
+		return fmt.Sprintf("%d..%d", required, len(params))
+	}
 }
 
 func evalExpressions(arguments []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
 	for _, arg := range arguments {
+		if spread, ok := arg.(*ast.SpreadExpression); ok {
+			items, err := evalSpread(spread, env)
+			if err != nil {
+				return []object.Object{err}
+			}
+			result = append(result, items...)
+			continue
+		}
+
 		v := Eval(arg, env)
-		if isError(v) {
+		if unwinds(v) {
 			return []object.Object{v}
 		}
 
@@ -238,6 +729,114 @@ func evalExpressions(arguments []ast.Expression, env *object.Environment) []obje
 	return result
 }
 
+// evalArrayLiteral evaluates an array literal. Its spread elements are
+// charged as they are expanded and the rest up front.
+func evalArrayLiteral(node *ast.ArrayLiteral, env *object.Environment) object.Object {
+	n := 0
+	for _, el := range node.Elements {
+		if _, ok := el.(*ast.SpreadExpression); !ok {
+			n++
+		}
+	}
+	if err := charge(env.State(), int64(n)); err != nil {
+		return err
+	}
+
+	elements := evalExpressions(node.Elements, env)
+	if len(elements) == 1 && unwinds(elements[0]) {
+		return elements[0]
+	}
+	return &object.Array{Elements: elements}
+}
+
+// evalSpread evaluates the value of `...value` and returns its elements.
+func evalSpread(node *ast.SpreadExpression, env *object.Environment) ([]object.Object, object.Object) {
+	v := Eval(node.Value, env)
+	if unwinds(v) {
+		return nil, v
+	}
+
+	items, err := spread(env.State(), v)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// spread returns the elements of v in iteration order, charging them to
+// state before collecting them.
+func spread(state *object.State, v object.Object) ([]object.Object, *object.Error) {
+	next, err := iterate(v)
+	if err != nil {
+		return nil, newError("cannot spread %s", v.Type())
+	}
+
+	n := length(v)
+	if err := charge(state, n); err != nil {
+		return nil, err
+	}
+
+	items := []object.Object{}
+	for item, ok := next(); ok; item, ok = next() {
+		if err := poll(state, len(items)); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// length returns the number of values iterating over obj yields.
+func length(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return int64(len(obj.Elements))
+	case *object.Hash:
+		return int64(len(obj.Pairs))
+	case *object.String:
+		return int64(utf8.RuneCountInString(obj.Value))
+	case *object.Range:
+		return obj.Len()
+	}
+	return 0
+}
+
+// evalArguments evaluates the arguments of a call in order, splitting them
+// into positional and keyword arguments. The parser ensures keyword
+// arguments come last.
+func evalArguments(arguments []ast.Expression, env *object.Environment) ([]object.Object, []KeywordArg, object.Object) {
+	n := 0
+	for n < len(arguments) {
+		if _, ok := arguments[n].(*ast.KeywordArgument); ok {
+			break
+		}
+		n++
+	}
+
+	args := evalExpressions(arguments[:n], env)
+	if len(args) == 1 && unwinds(args[0]) {
+		return nil, nil, args[0]
+	}
+
+	var kwargs []KeywordArg
+	for _, arg := range arguments[n:] {
+		kw, ok := arg.(*ast.KeywordArgument)
+		if !ok {
+			return nil, nil, newError("positional argument follows keyword argument")
+		}
+
+		v := Eval(kw.Value, env)
+		if unwinds(v) {
+			return nil, nil, v
+		}
+		kwargs = append(kwargs, KeywordArg{Name: kw.Name.Value, Value: v})
+	}
+
+	return args, kwargs, nil
+}
+
 func evalBuiltinIdentifier(node *ast.Identifier) object.Object {
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
@@ -245,22 +844,29 @@ func evalBuiltinIdentifier(node *ast.Identifier) object.Object {
 	return NULL
 }
 
+// evalIdentifier looks a name up in the slot the resolver found for it, if
+// any, then by name. Variables shadow builtins of the same name.
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
+	if b := node.Binding; b != nil {
+		if val := env.GetSlot(b.Depth, b.Slot); val != nil {
+			return val
+		}
 	}
 
-	val, ok := env.Get(node.Value)
-	if !ok {
-		return newError("identifier not found: %s", node.Value)
+	if val, ok := env.Get(node.Value); ok {
+		return val
 	}
 
-	return val
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+
+	return newError("identifier not found: %s", node.Value)
 }
 
 func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(node.Condition, env)
-	if isError(condition) {
+	if unwinds(condition) {
 		return condition
 	}
 
@@ -277,7 +883,109 @@ func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Ob
 	}
 }
 
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if unwinds(condition) {
+			return condition
+		}
+
+		if condition.Type() != object.BOOLEAN_OBJ {
+			return newError("unknown operator: %s%s%s", "while(", condition.Type(), ")")
+		}
+
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		result := Eval(node.Body, object.NewScopeEnvironment(env, locals(node.Body)))
+		if result == BREAK {
+			return nil
+		}
+		if result != nil && result != CONTINUE {
+			if result.Type() == object.RETURN_VALUE_OBJ || isError(result) {
+				return result
+			}
+		}
+	}
+}
+
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if unwinds(iterable) {
+		return iterable
+	}
+
+	next, err := iterate(iterable)
+	if err != nil {
+		return err
+	}
+
+	for item, ok := next(); ok; item, ok = next() {
+		loopEnv := object.NewScopeEnvironment(env, locals(node.Body))
+		bind(loopEnv, node.Variable, item)
+
+		result := Eval(node.Body, loopEnv)
+		if result == BREAK {
+			return nil
+		}
+		if result != nil && result != CONTINUE {
+			if result.Type() == object.RETURN_VALUE_OBJ || isError(result) {
+				return result
+			}
+		}
+	}
+
+	return nil
+}
+
+// iterate returns a function yielding the values a for-in loop visits: the
+// elements of an array, the keys of a hash in insertion order, the
+// characters of a string or the integers of a range.
+func iterate(obj object.Object) (func() (object.Object, bool), *object.Error) {
+	var items []object.Object
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		items = make([]object.Object, len(obj.Elements))
+		copy(items, obj.Elements)
+	case *object.Hash:
+		for _, pair := range obj.Ordered() {
+			items = append(items, pair.Key)
+		}
+	case *object.String:
+		for _, r := range obj.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	case *object.Range:
+		var i int64
+		return func() (object.Object, bool) {
+			if i >= obj.Len() {
+				return nil, false
+			}
+			i++
+			return &object.Integer{Value: obj.At(i - 1)}, true
+		}, nil
+	default:
+		return nil, newError("cannot iterate over %s", obj.Type())
+	}
+
+	i := 0
+	return func() (object.Object, bool) {
+		if i >= len(items) {
+			return nil, false
+		}
+		i++
+		return items[i-1], true
+	}, nil
+}
+
 func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	// an integer mixed with a float is promoted to a float
+	if isNumber(left) && isNumber(right) && (left.Type() == object.FLOAT_OBJ || right.Type() == object.FLOAT_OBJ) {
+		return evalFloatInfixExpression(operator, left, right)
+	}
+
 	if left.Type() != right.Type() {
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -295,17 +1003,69 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 	return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 }
 
+// shortCircuits reports whether a `&&` or `||` expression is decided by its
+// left operand alone, in which case the right operand is not evaluated.
+func shortCircuits(operator string, left object.Object) bool {
+	switch operator {
+	case "&&":
+		return left == FALSE
+	case "||":
+		return left == TRUE
+	}
+	return false
+}
+
 func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
-	if operator != "+" {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
+}
 
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for i, s := range node.Strings {
+		out.WriteString(s)
+		if i >= len(node.Expressions) {
+			break
+		}
+
+		v := Eval(node.Expressions[i], env)
+		if unwinds(v) {
+			return v
+		}
+
+		switch v := v.(type) {
+		case *object.String:
+			out.WriteString(v.Value)
+		case nil:
+			out.WriteString(NULL.Inspect())
+		default:
+			out.WriteString(v.Inspect())
+		}
+	}
 
 	return &object.String{
-		Value: leftVal + rightVal,
+		Value: out.String(),
 	}
 }
 
@@ -315,6 +1075,10 @@ func evalBooleanInfixExpression(operator string, left object.Object, right objec
 		return nativeBoolToBooleanObject(left == right)
 	case "!=":
 		return nativeBoolToBooleanObject(left != right)
+	case "&&":
+		return nativeBoolToBooleanObject(left == TRUE && right == TRUE)
+	case "||":
+		return nativeBoolToBooleanObject(left == TRUE || right == TRUE)
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
@@ -333,11 +1097,43 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "..":
+		return &object.Range{Start: leftVal, End: rightVal, Inclusive: true}
+	case "..<":
+		return &object.Range{Start: leftVal, End: rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal << uint64(rightVal)}
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> uint64(rightVal)}
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -348,12 +1144,63 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	}
 }
 
+func evalFloatInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Integer, *object.Float:
+		return true
+	}
+	return false
+}
+
+// toFloat returns the value of an Integer or Float as a float64.
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangPrefixExpression(right)
 	case "-":
 		return evalMinusPrefixExpression(right)
+	case "~":
+		return evalTildePrefixExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -370,6 +1217,10 @@ func evalBangPrefixExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixExpression(right object.Object) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
+
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: %s%s", "-", right.Type())
 	}
@@ -381,6 +1232,16 @@ func evalMinusPrefixExpression(right object.Object) object.Object {
 	}
 }
 
+func evalTildePrefixExpression(right object.Object) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: %s%s", "~", right.Type())
+	}
+
+	return &object.Integer{
+		Value: ^right.(*object.Integer).Value,
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
@@ -394,11 +1255,8 @@ func evalBlockStatement(stmts []ast.Statement, env *object.Environment) object.O
 	for _, statement := range stmts {
 		result = Eval(statement, env)
 
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		if unwinds(result) {
+			return result
 		}
 	}
 
@@ -415,7 +1273,9 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 		case *object.ReturnValue:
 			return result.Value
 		case *object.Error:
-			return result
+			if !result.Handled {
+				return result
+			}
 		}
 	}
 
@@ -430,13 +1290,25 @@ func isTruthy(obj object.Object) bool {
 	return false
 }
 
+// isError reports whether obj is an error that has not been handled by a
+// catch clause and so must unwind evaluation.
 func isError(obj object.Object) bool {
-	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+	if err, ok := obj.(*object.Error); ok {
+		return !err.Handled
 	}
 	return false
 }
 
+// unwinds reports whether obj ends the evaluation of the expressions around
+// it: an unhandled error, the value of a return statement on its way to the
+// function it returns from, or a break or continue on its way to its loop.
+func unwinds(obj object.Object) bool {
+	if _, ok := obj.(*object.ReturnValue); ok {
+		return true
+	}
+	return obj == BREAK || obj == CONTINUE || isError(obj)
+}
+
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: runtimeError}
 }
@@ -1,11 +1,15 @@
 package eval
 
 import (
+	"context"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/threeaccents/digolang/lexer"
 	"github.com/threeaccents/digolang/object"
 	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
 )
 
 func TestHashLiterals(t *testing.T) {
@@ -19,7 +23,7 @@ func TestHashLiterals(t *testing.T) {
 		false: 6
 	}`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.Hash)
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
@@ -84,7 +88,7 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -106,7 +110,7 @@ func TestBangOperator(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
@@ -114,7 +118,7 @@ func TestBangOperator(t *testing.T) {
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
@@ -124,10 +128,326 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedInspect string
+	}{
+		{`"tab\there"`, "tab\there"},
+		{"`raw \\n`", "raw \\n"},
+		{`["a\"b", "c"]`, `["a\"b", "c"]`},
+		{`{"k\n": "v"}`, `{"k\n": "v"}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil || evaluated.Inspect() != tt.expectedInspect {
+			t.Errorf("wrong result for %s. expected=%q, got=%+v",
+				tt.input, tt.expectedInspect, evaluated)
+		}
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "digo"; "hello ${name}!"`, "hello digo!"},
+		{`"${1 + 2} = 3"`, "3 = 3"},
+		{`"${true} ${[1, "a"]} ${{"k": 2}["k"]}"`, `true [1, "a"] 2`},
+		{`let f = fn(x) { "<${x}>" }; "${f("${f(1)}")}"`, "<<1>>"},
+		{`"${if (false) { 1 }}"`, "null"},
+		{`"\${literal} and $ alone"`, "${literal} and $ alone"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	evaluated := testEval(t, `"value: ${-true}"`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "unknown operator: -BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"größe"[2]`, "ö"},
+		{`"😀!"[1]`, "!"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[-4]`, nil},
+		{`byteAt("abc", 3)`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		expected, ok := tt.expected.(string)
+		if !ok {
+			testNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2.5", 2.5},
+		{".5", 0.5},
+		{"-1.5", -1.5},
+		{"1e3", 1000.0},
+		{"1.5 + 1.5", 3.0},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2.0},
+		{"7 / 2.0", 3.5},
+		{"7 / 2", 3},
+		{"float(7) / 2", 3.5},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"2 > 1.5", true},
+		{"1.5 < 1", false},
+		{"2.0 != 2", false},
+		{`{1: "one"}[1.0]`, "one"},
+		{`{1.5: "x"}[1.5]`, "x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %s. expected=%q, got=%+v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"0.25", "0.25"},
+		{"1e21", "1e+21"},
+		{"-0.0", "-0.0"},
+		{"[1, 1.0]", "[1, 1.0]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %s. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExtendedOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7.5 % 2", 1.5},
+		{"3 <= 3", true},
+		{"4 <= 3", false},
+		{"3 >= 4", false},
+		{"2.5 >= 2", true},
+		{`"abc" < "abd"`, true},
+		{`"b" >= "a"`, true},
+		{`"a" == "a"`, true},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"true && false", false},
+		{"true && true", true},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"7 / 0", "division by zero"},
+		{"7 % 0", "division by zero"},
+		{"1 << -1", "negative shift count: -1"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"1 && true", "type mismatch: INTEGER && BOOLEAN"},
+		{"true && 1", "type mismatch: BOOLEAN && INTEGER"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestShortCircuitEvaluation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && missing", false},
+		{"true || missing", true},
+		{"false && 1 / 0 == 0", false},
+		{"let f = fn() { missing }; true || f()", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	evaluated := testEval(t, "true && missing")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: missing" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestEvaluationOrder(t *testing.T) {
+	evaluated := testEval(t, "missingLeft + missingRight")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: missingLeft" {
+		t.Errorf("left operand was not evaluated first. got=%q", errObj.Message)
+	}
+}
+
+func TestRangesAndSlices(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0..3", "0..3"},
+		{"0..<3", "0..<3"},
+		{"array(0..3)", "[0, 1, 2, 3]"},
+		{"array(0..<3)", "[0, 1, 2]"},
+		{"array(3..1)", "[]"},
+		{"len(0..<10)", "10"},
+		{"len(5..1)", "0"},
+		{"(1..10)[2]", "3"},
+		{"(1..10)[-1]", "10"},
+		{"(1..10)[10]", "null"},
+		{"array((0..<10)[2:5])", "[2, 3, 4]"},
+		{"len(0..9223372036854775807)", "9223372036854775807"},
+		{"len(-2..9223372036854775806)", "9223372036854775807"},
+		{"len((-9223372036854775807 - 1)..9223372036854775807)", "9223372036854775807"},
+		{"len(9223372036854775807..9223372036854775807)", "1"},
+		{"len(9223372036854775807..<9223372036854775807)", "0"},
+		{"(-9223372036854775807..0)[0]", "-9223372036854775807"},
+		{"(-9223372036854775807..0)[-1]", "0"},
+		{"(-2..9223372036854775806)[-1]", "9223372036854775806"},
+		{"((-9223372036854775807 - 1)..9223372036854775807)[-1]", "9223372036854775807"},
+		{"((-9223372036854775807 - 1)..9223372036854775807)[9223372036854775807]", "-1"},
+		{"((-9223372036854775807 - 1)..<9223372036854775807)[-9223372036854775807 - 1]", "-1"},
+		{"(0..<9223372036854775807)[-9223372036854775807 - 1]", "null"},
+		{"let r = []; for (i in -1..9223372036854775807) { r = push(r, i); if (len(r) == 2) { break } }; r", "[-1, 0]"},
+		{"let r = []; for (i in 9223372036854775806..9223372036854775807) { r = push(r, i) }; r", "[9223372036854775806, 9223372036854775807]"},
+		{"let n = 4; let sum = 0; for (i in 1..n) { sum += i }; sum", "10"},
+		{"let sum = 0; for (i in 0..<1000000) { if (i == 3) { break } sum += i }; sum", "3"},
+		{"[1, 2, 3, 4, 5][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4, 5][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4, 5][3:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][-2:]", "[4, 5]"},
+		{"[1, 2, 3, 4, 5][:-1]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3][:]", "[1, 2, 3]"},
+		{"[1, 2, 3][2:1]", "[]"},
+		{"[1, 2, 3][-10:10]", "[1, 2, 3]"},
+		{"let a = [1, 2, 3]; let b = a[:]; b[0] = 9; a[0]", "1"},
+		{`"größe"[1:3]`, "rö"},
+		{`"hello"[-3:]`, "llo"},
+		{`array("hé")`, `["h", "é"]`},
+		{`[1, 2][0:"1"]`, "slice bounds must be INTEGER, got STRING"},
+		{`5[1:2]`, "slice operator not supported: INTEGER"},
+		{`1..2.5`, "unknown operator: INTEGER .. FLOAT"},
+		{`array(1)`, "argument to `array` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %s", tt.input)
+			continue
+		}
+
+		actual := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			actual = errObj.Message
+		}
+
+		if actual != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.Array)
 	if !ok {
 		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
@@ -186,12 +506,20 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-3]",
+			1,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -204,7 +532,7 @@ func TestArrayIndexExpressions(t *testing.T) {
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	str, ok := evaluated.(*object.String)
 	if !ok {
 		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
@@ -240,7 +568,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
 }
@@ -248,7 +576,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	fn, ok := evaluated.(*object.Function)
 	if !ok {
 		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
@@ -284,7 +612,7 @@ func TestFunctionApplication(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
@@ -293,6 +621,13 @@ func TestEvalIntegerExpression(t *testing.T) {
 		input    string
 		expected int64
 	}{
+		{"0xFF", 255},
+		{"0o755", 493},
+		{"0b1010", 10},
+		{"0O17", 15},
+		{"0", 0},
+		{"1_000_000", 1000000},
+		{"0x_7f + 1", 128},
 		{"5", 5},
 		{"10", 10},
 		{"-10", -10},
@@ -312,18 +647,43 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
 
-func testEval(input string) object.Object {
+// Backends run each program testEval evaluates in another way, such as on
+// the bytecode VM, and report where the result differs from want. The tests
+// in package eval_test register them, since the packages they use import
+// eval.
+var Backends []func(t *testing.T, input string, limits Limits, want object.Object)
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	return testEvalLimits(t, input, Limits{})
+}
+
+// testEvalLimits is testEval for limits that do not stop the evaluation,
+// such as a call depth.
+func testEvalLimits(t *testing.T, input string, limits Limits) object.Object {
+	t.Helper()
+
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	resolver.Resolve(program)
 	env := object.NewEnvironment()
 
-	return Eval(program, env)
+	evaluated, err := EvalContext(context.Background(), program, env, limits)
+	if err != nil {
+		t.Fatalf("evaluation of %q stopped: %v", input, err)
+	}
+	for _, compare := range Backends {
+		compare(t, input, limits, evaluated)
+	}
+
+	return evaluated
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -341,6 +701,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	result, ok := obj.(*object.Boolean)
 	if !ok {
@@ -379,11 +754,101 @@ func TestReturnStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
 
+// A return, break or continue inside an expression leaves the function or
+// loop at once instead of leaving its value in the expression.
+func TestControlFlowInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn() { let y = try { return 3 } catch (e) { 0 }; 99 }; f()", 3},
+		{"fn() { let x = if (true) { return 1 }; 99 }()", 1},
+		{`fn() { "a${if (true) { return 1 }}b" }()`, 1},
+		{"fn() { while (if (true) { return 4 }) {} 99 }()", 4},
+		{"fn() { for (x in if (true) { return 5 }) {} 99 }()", 5},
+		{"fn() { 1 + if (true) { return 6 } }()", 6},
+		{"fn() { [1, if (true) { return 7 }, 3]; 99 }()", 7},
+		{"fn() { len(if (true) { return 8 }); 99 }()", 8},
+		{"fn() { let a = [1]; a[if (true) { return 9 }] = 2; 99 }()", 9},
+		{"fn() { {1: if (true) { return 10 }}; 99 }()", 10},
+		{"fn() { -(if (true) { return 11 }); 99 }()", 11},
+		{"fn() { throw if (true) { return 12 } }()", 12},
+		{"fn(x = if (true) { return 13 }) { 99 }()", 13},
+		{"fn() { return if (true) { return 14 } }()", 14},
+		{"let x = if (true) { return 15 }; 99", 15},
+		{"let n = 0; while (n < 3) { n += 1; let a = if (true) { break }; n = 100 }; n", 1},
+		{`let m = 0; for (i in 0..<3) { let s = "x${if (true) { continue }}"; m += 1 }; m`, 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x, y = 10) { x + y }; f(1)", 11},
+		{"let f = fn(x, y = 10) { x + y }; f(1, 2)", 3},
+		{"let f = fn(x, y = x * 2) { x + y }; f(3)", 9},
+		{"let f = fn(head, ...tail) { len(tail) }; f(1, 2, 3)", 2},
+		{"let f = fn(head, ...tail) { len(tail) }; f(1)", 0},
+		{"let sum = fn(...xs) { let t = 0; for (x in xs) { t += x }; t }; sum(1, 2, 3, 4)", 10},
+		{"let add = fn(a, b, c) { a + b * c }; add(...[1, 2, 3])", 7},
+		{"let add = fn(a, b, c) { a + b * c }; add(1, ...[2], 3)", 7},
+		{"let sum = fn(...xs) { len(xs) }; sum(...1..5, ...\"ab\")", 7},
+		{"len([0, ...[1, 2], ...0..<3])", 6},
+		{"let sub = fn(a, b) { a - b }; sub(b = 1, a = 10)", 9},
+		{"let f = fn(a, b = 2, c = 3) { a * 100 + b * 10 + c }; f(1, c = 9)", 129},
+		{"let f = fn(x) { x }; f()", "wrong number of arguments. got=0, want=1"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"let f = fn(x, y = 1) { x }; f(1, 2, 3)", "wrong number of arguments. got=3, want=1..2"},
+		{"let f = fn(x, ...r) { x }; f()", "wrong number of arguments. got=0, want=at least 1"},
+		{"let f = fn(x) { x }; f(y = 1)", "unexpected keyword argument: y"},
+		{"let f = fn(x) { x }; f(1, x = 2)", "multiple values for argument: x"},
+		{"let f = fn(...r) { r }; f(r = 1)", "unexpected keyword argument: r"},
+		{"len(x = 1)", "builtin functions do not accept keyword arguments"},
+		{"let f = fn(a) { a }; f(...5)", "cannot spread INTEGER"},
+		{"let xs = [1]; ...xs", "unexpected spread: ...xs"},
+		{"let f = fn(x = missing) { x }; f()", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestFunctionInspect(t *testing.T) {
+	evaluated := testEval(t, "fn(x, y = 10, ...rest) { x }")
+
+	expected := "fn(x, y = 10, ...rest) {\nx\n}"
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong Inspect. expected=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -393,7 +858,63 @@ let newAdder = fn(x) {
 let addTwo = newAdder(2);
 addTwo(2);`
 
-	testIntegerObject(t, testEval(input), 4)
+	testIntegerObject(t, testEval(t, input), 4)
+}
+
+func TestScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn() { let x = 1; let g = fn() { x += 1; x }; g(); g() }; f()", 3},
+		{"let f = fn() { let g = fn() { h() }; let h = fn() { 5 }; g() }; f()", 5},
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()", 3},
+		{"let f = fn() { let x = 1; let r = 0; while (true) { let g = fn() { x }; let x = 2; r = g(); break }; r }; f()", 2},
+		{"let f = fn(n) { let s = 0; for (i in 0..<n) { let sq = i * i; s += sq }; s }; f(4)", 14},
+		{"let f = fn() { try { throw 1 } catch (e) { let x = e.message; len(x) } }; f()", 1},
+		{"let f = fn(a, b = a * 2) { if (true) { let c = a + b }; c }; f(1)", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestTypeAnnotationsIgnored(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x: int = 5; x", 5},
+		{"let add = fn(a: int, b: int = 2) -> int { a + b }; add(1)", 3},
+		{"let sum = fn(...xs: int) -> int { let s: int = 0; for (x in xs) { s += x }; s }; sum(1, 2, 3)", 6},
+		{"let apply = fn(f: fn(int) -> int, xs: [int]) -> [int] { xs.map(f) }; apply(fn(x) { x * 2 }, [1, 2])[1]", 4},
+		// annotations are not checked at runtime
+		{`let x: string = 1; x`, 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestShadowingBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`len("abc")`, 3},
+		{`let len = fn(x) { 42 }; len("abc")`, 42},
+		{"let f = fn() { let puts = 5; puts }; f()", 5},
+		{"let f = fn(len) { len }; f(7)", 7},
+		{"let r = 0; for (len in [1, 2]) { r += len }; r", 3},
+		{`let g = fn() { len("ab") }; let len = fn(x) { 1 }; g()`, 1},
+		{`let f = fn() { let x = len("ab"); let len = 9; x + len }; f()`, 11},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
 }
 
 func TestErrorHandling(t *testing.T) {
@@ -452,7 +973,7 @@ func TestErrorHandling(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
@@ -480,7 +1001,410 @@ func TestLetStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", 6},
+		{"let x = 1.5; x *= 2; x", 3.0},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let count = 0; let inc = fn() { count += 1 }; inc(); inc(); count", 2},
+		{"let x = 1; let shadow = fn() { let x = 5; x = 6; x }; shadow() + x", 7},
+		{"let arr = [1, 2, 3]; arr[1] = 20; arr[2] += 10; arr[0] + arr[1] + arr[2]", 34},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] *= 10; h["a"] + h["b"]`, 12},
+		{"let a = [0]; let b = a; b[0] = 9; a[0]", 9},
+		{"y = 1", "cannot assign to undeclared identifier: y"},
+		{"let arr = [1, 2, 3]; arr[-1] = 9; arr[-3] += 10; arr[0] + arr[2]", 20},
+		{"let arr = [1]; arr[5] = 2", "index out of range: 5"},
+		{"let arr = [1]; arr[-2] = 2", "index out of range: -2"},
+		{`let h = {}; h["k"] += 1`, `key not found: k`},
+		{`let x = 1; x += "a"`, "type mismatch: INTEGER + STRING"},
+		{"let x = 1; x /= 0", "division by zero"},
+		{"let x = missing; x", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { i += 1 }; i", 5},
+		{"let i = 0; while (false) { i += 1 }; i", 0},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { sum += x }; sum", 10},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break } sum += x }; sum", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x % 2 == 0) { continue } sum += x }; sum", 4},
+		{"let i = 0; while (true) { i += 1; if (i == 100000) { break } }; i", 100000},
+		{`let out = ""; for (k in {"b": 1, "a": 2, "c": 3}) { out += k }; out`, "bac"},
+		{`let out = ""; for (c in "größe") { out = c + out }; out`, "eßörg"},
+		{"let n = 0; for (x in []) { n += 1 }; n", 0},
+		{"let find = fn(xs) { for (x in xs) { if (x > 2) { return x } } -1 }; find([1, 5, 3])", 5},
+		{"let count = 0; for (i in [1, 2, 3]) { for (j in [1, 2, 3]) { if (j > i) { break } count += 1 } }; count", 6},
+		{"let fns = []; for (x in [1, 2]) { fns = push(fns, fn() { x }) }; fns[0]() + fns[1]() * 10", 21},
+		{"let x = 7; for (x in [1]) { }; x", 7},
+		{"for (x in 5) { }", "cannot iterate over INTEGER"},
+		{"while (1) { }", "unknown operator: while(INTEGER)"},
+		{"for (x in [1]) { missing }", "identifier not found: missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+				}
+				continue
+			}
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	input := `let h = {"z": 1, "a": 2, 3: 3}; h["m"] = 4; h["z"] = 5; h`
+
+	evaluated := testEval(t, input)
+	expected := `{"z": 5, "a": 2, 3: 3, "m": 4}`
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong Inspect. expected=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
+func TestSelectorsAndMethods(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let h = {"name": "digo", "n": 2}; h.n`, 2},
+		{`let h = {"name": "digo"}; h.name`, "digo"},
+		{`let h = {"a": {"b": 3}}; h.a.b`, 3},
+		{`let h = {"n": 1}; h.n += 4; h.new = 2; h.n * h.new`, 10},
+		{`let h = {}; h.missing`, nil},
+		{`"Digo".upper()`, "DIGO"},
+		{`"  x y ".trim().split(" ").join("-")`, "x-y"},
+		{`"hello".replace("l", "L")`, "heLLo"},
+		{`"hello".startsWith("he")`, true},
+		{`[1, 2, 3].map(fn(x) { x * 2 }).reduce(fn(acc, x) { acc + x }, 0)`, 12},
+		{`[1, 2, 3, 4].filter(fn(x) { x % 2 == 0 }).join(",")`, "2,4"},
+		{`["a", "b"].indexOf("b")`, 1},
+		{`[1, 2].contains(3)`, false},
+		{`let h = {"b": 1, "a": 2}; h.keys().join("")`, "ba"},
+		{`{"a": 1}.has("a")`, true},
+		{`let h = {"keys": 5}; h.keys`, 5},
+		{`let m = "abc".upper; m()`, "ABC"},
+		{`5.abs()`, errorValue("undefined method abs for INTEGER")},
+		{`"abc".upper(1)`, errorValue("wrong number of arguments. got=1, want=0")},
+		{`"abc".split(1)`, errorValue("argument to `split` must be STRING, got INTEGER")},
+		{`[1].map(fn(x) { x }, n = 1)`, errorValue("methods do not accept keyword arguments")},
+		{`let s = "x"; s.y = 1`, errorValue("cannot assign to field y of STRING")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value for %s. got=%q, want=%q", tt.input, str.Value, expected)
+			}
+		case errorValue:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// errorValue marks an expected value as an error message rather than a
+// string result.
+type errorValue string
+
+func TestRegisterMethod(t *testing.T) {
+	RegisterMethod(object.INTEGER_OBJ, "double", func(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+		return &object.Integer{Value: receiver.(*object.Integer).Value * 2}
+	})
+	defer delete(methods[object.INTEGER_OBJ], "double")
+
+	testIntegerObject(t, testEval(t, "let x = 21; x.double()"), 42)
+}
+
+func TestRegisterMethodWhileRunning(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			testBooleanObject(t, testEval(t, `[1, 2].contains(2)`), true)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		RegisterMethod(object.INTEGER_OBJ, "m"+strconv.Itoa(i), func(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+			return receiver
+		})
+	}
+	<-done
+
+	methodsMu.Lock()
+	for i := 0; i < 100; i++ {
+		delete(methods[object.INTEGER_OBJ], "m"+strconv.Itoa(i))
+	}
+	methodsMu.Unlock()
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { len(1) } catch (e) { e.message }`, "argument to `len` not supported, got INTEGER"},
+		{`try { 1 + "a" } catch (e) { e.kind }`, "RuntimeError"},
+		{`try { throw "boom" } catch (e) { e.message + "/" + e.kind }`, "boom/Error"},
+		{`try { throw 42 } catch (e) { e.value }`, 42},
+		{`try { throw error("bad", "ValueError") } catch (e) { e.kind }`, "ValueError"},
+		{`let e = error("x"); e.message`, "x"},
+		{`try { 1 / 0 } catch { 7 }`, 7},
+		{`let n = 0; try { n = 1 } finally { n += 10 }; n`, 11},
+		{`let n = 0; try { throw "x" } catch (e) { n = 1 } finally { n += 10 }; n`, 11},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let n = 0; while (true) { try { break } finally { n = 5 } }; n`, 5},
+		{`try { try { throw "inner" } finally { 1 } } catch (e) { e.message }`, "inner"},
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e.message }`, "a"},
+		{`try { throw "x" } catch (e) { e }; 3`, 3},
+		{`let f = fn() { 1 + "a" }; let g = fn() { f() }; try { g() } catch (e) { e.stack }`,
+			[]string{"f at 1:42", "g at 1:55"}},
+		{`try { [1, 2].map(fn(x) { throw x }) } catch (e) { e.value }`, 1},
+		{`try { throw "x" } finally { 1 }`, errorValue("x")},
+		{`try { 1 } finally { throw "f" }`, errorValue("f")},
+		{`try { throw "x" } catch (e) { throw "y" }`, errorValue("y")},
+		{`error(1)`, errorValue("argument to `error` must be STRING, got INTEGER")},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value for %s. got=%q, want=%q", tt.input, str.Value, expected)
+			}
+		case []string:
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("object is not Array for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			var got []string
+			for _, el := range arr.Elements {
+				got = append(got, el.(*object.String).Value)
+			}
+			if strings.Join(got, "|") != strings.Join(expected, "|") {
+				t.Errorf("wrong stack for %s. got=%q, want=%q", tt.input, got, expected)
+			}
+		case errorValue:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Handled {
+				t.Errorf("object is not an unhandled Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestTraceback(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + true",
+			"Traceback (most recent call last):\n" +
+				"  1:1, in <program>\n" +
+				"RuntimeError: type mismatch: INTEGER + BOOLEAN\n",
+		},
+		{
+			"let check = fn(x) {\n  x + \"a\"\n}\nlet run = fn(xs) {\n  xs.map(fn(x) { check(x) })\n}\nrun([1])",
+			"Traceback (most recent call last):\n" +
+				"  7:1, in <program>\n" +
+				"  5:3, in run\n" +
+				"  5:18, in <anonymous>\n" +
+				"  2:3, in check\n" +
+				"RuntimeError: type mismatch: INTEGER + STRING\n",
+		},
+		{
+			"let f = fn(x) { x }\nf(1, 2)",
+			"Traceback (most recent call last):\n" +
+				"  2:1, in <program>\n" +
+				"RuntimeError: wrong number of arguments. got=2, want=1\n",
+		},
+		{
+			"let fail = fn() { throw error(\"nope\", \"ValueError\") }\nfail()",
+			"Traceback (most recent call last):\n" +
+				"  2:1, in <program>\n" +
+				"  1:19, in fail\n" +
+				"ValueError: nope\n",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("object is not Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if got := errObj.Traceback(); got != tt.expected {
+			t.Errorf("wrong traceback for %q.\nexpected:\n%s\ngot:\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = fn(n, acc) { if (n == 0) { return acc } return sum(n - 1, acc + n) }; sum(100000, 0)", 5000050000},
+		{`let even = fn(n) { if (n == 0) { return true } return odd(n - 1) };
+		  let odd = fn(n) { if (n == 0) { return false } return even(n - 1) };
+		  even(50001)`, false},
+		{"let loop = fn(n) { while (true) { return n } }; let f = fn(n) { if (n == 0) { return 7 } return loop(0) + f(n - 1) }; f(3)", 7},
+		{"let f = fn(n) { return len(\"ab\") }; f(1)", 2},
+		{"let f = fn(n) { if (n == 0) { return 1 + \"a\" } return f(n - 1) }; try { f(20000) } catch (e) { e.message }",
+			"type mismatch: INTEGER + STRING"},
+		{"let f = fn(n) { return f() }; try { f(1) } catch (e) { e.message }",
+			"wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value for %s. got=%q, want=%q", tt.input, str.Value, expected)
+			}
+		}
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(49)", 49},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(50)",
+			errorValue("stack overflow: maximum call depth of 50 exceeded")},
+		{"let f = fn(n) { 1 + f(n) }; let r = try { f(1) } catch (e) { e.kind }; [r, f(0)]",
+			errorValue("stack overflow: maximum call depth of 50 exceeded")},
+		{"let f = fn(n) { 1 + f(n) }; try { f(1) } catch (e) { 0 }; let g = fn(n) { if (n == 0) { 0 } else { 1 + g(n - 1) } }; g(49)", 49},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalLimits(t, tt.input, Limits{MaxDepth: 50})
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case errorValue:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != string(expected) {
+				t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	env := object.NewEnvironment()
+	program := parser.New(lexer.New("let f = fn(n) { 1 + f(n) }; try { f(1) } catch (e) { 0 }")).ParseProgram()
+	Eval(program, env)
+	if depth := env.State().Depth; depth != 0 {
+		t.Errorf("call depth not restored after stack overflow. got=%d", depth)
 	}
 }
 
@@ -498,7 +1422,7 @@ func TestIfElseExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			testIntegerObject(t, evaluated, int64(integer))
@@ -0,0 +1,179 @@
+package eval
+
+import (
+	"context"
+	"errors"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/token"
+)
+
+// Errors wrapped by a LimitError when an evaluation exceeds its Limits.
+var (
+	ErrStepLimit  = errors.New("step limit exceeded")
+	ErrAllocLimit = errors.New("allocation limit exceeded")
+)
+
+// contextCheckInterval is the number of steps between checks of the
+// context of an evaluation.
+const contextCheckInterval = 1024
+
+// Limits bounds the resources an evaluation started by EvalContext may use.
+// A zero field means no limit, except for MaxDepth.
+type Limits struct {
+	// MaxSteps is the maximum number of nodes evaluated.
+	MaxSteps int64
+	// MaxAlloc is the maximum total size of the values created, counting
+	// strings in bytes and arrays and hashes in elements.
+	MaxAlloc int64
+	// MaxDepth is the maximum number of nested function calls, or zero for
+	// DefaultMaxDepth. Calls nest on the Go stack, so there is always a
+	// limit.
+	MaxDepth int
+}
+
+// LimitError is returned by EvalContext when the evaluation was stopped
+// because its context was done or it exceeded one of its limits. Unlike
+// runtime errors it cannot be caught by the script.
+type LimitError struct {
+	// Err is ErrStepLimit, ErrAllocLimit or the error of the context.
+	Err error
+	// Pos is the position evaluation stopped at.
+	Pos token.Position
+}
+
+func (e *LimitError) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Err.Error()
+	}
+	return e.Err.Error()
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// EvalContext evaluates node in env like Eval, but stops once ctx is done or
+// the evaluation exceeds limits, returning a *LimitError. Errors raised by
+// the script are returned as *object.Error results, as with Eval.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) (object.Object, error) {
+	return RunContext(ctx, env, limits, func() object.Object {
+		return Eval(node, env)
+	})
+}
+
+// RunContext runs an evaluation in env, such as a call of Eval or a run of
+// the bytecode VM, under ctx and limits. It returns like EvalContext.
+func RunContext(ctx context.Context, env *object.Environment, limits Limits, run func() object.Object) (object.Object, error) {
+	state := env.State()
+
+	saved := *state
+	defer func() {
+		depth := state.Depth
+		*state = saved
+		state.Depth = depth
+	}()
+
+	state.Context = ctx
+	state.Steps, state.MaxSteps = 0, limits.MaxSteps
+	state.Allocated, state.MaxAlloc = 0, limits.MaxAlloc
+	state.MaxDepth = limits.MaxDepth
+	state.Abort = nil
+
+	if err := ctx.Err(); err != nil {
+		return nil, &LimitError{Err: err}
+	}
+
+	result := run()
+	if state.Abort != nil {
+		limitErr := &LimitError{Err: state.Abort}
+		if err, ok := result.(*object.Error); ok {
+			limitErr.Pos = err.Pos
+		}
+		return nil, limitErr
+	}
+
+	return result, nil
+}
+
+// step counts the evaluation of one node, stopping the evaluation once its
+// step limit is exceeded or its context is done.
+func step(state *object.State) *object.Error {
+	if state.Abort != nil {
+		return abortError(state)
+	}
+
+	state.Steps++
+
+	if state.MaxSteps > 0 && state.Steps > state.MaxSteps {
+		state.Abort = ErrStepLimit
+		return abortError(state)
+	}
+
+	if state.Context != nil && state.Steps%contextCheckInterval == 0 {
+		if err := state.Context.Err(); err != nil {
+			state.Abort = err
+			return abortError(state)
+		}
+	}
+
+	return nil
+}
+
+// allocate counts the size of obj, a newly created value, stopping the
+// evaluation once its allocation limit is exceeded.
+func allocate(state *object.State, obj object.Object) *object.Error {
+	if state.MaxAlloc == 0 {
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.String:
+		return charge(state, int64(len(obj.Value)))
+	case *object.Array:
+		return charge(state, int64(len(obj.Elements)))
+	case *object.Hash:
+		return charge(state, int64(len(obj.Pairs)))
+	}
+
+	return nil
+}
+
+// charge counts size units of a value about to be created, in the units of
+// allocate, stopping the evaluation once its allocation limit is exceeded.
+// Operations whose results may be large charge them before building them,
+// so a script cannot exhaust memory before the limit is noticed.
+func charge(state *object.State, size int64) *object.Error {
+	if state.MaxAlloc == 0 {
+		return nil
+	}
+
+	state.Allocated += size
+	if state.Allocated > state.MaxAlloc || state.Allocated < 0 {
+		state.Abort = ErrAllocLimit
+		return abortError(state)
+	}
+
+	return nil
+}
+
+// poll is called on iteration i of a loop that runs inside a single step,
+// such as one in a builtin building a large value. It checks the context of
+// the evaluation every contextCheckInterval iterations.
+func poll(state *object.State, i int) *object.Error {
+	if i%contextCheckInterval != contextCheckInterval-1 || state.Context == nil {
+		return nil
+	}
+
+	if err := state.Context.Err(); err != nil {
+		state.Abort = err
+		return abortError(state)
+	}
+
+	return nil
+}
+
+// abortError returns the error unwinding an evaluation that was stopped.
+// try expressions let it pass.
+func abortError(state *object.State) *object.Error {
+	return &object.Error{Message: state.Abort.Error(), Kind: limitError, Fatal: true}
+}
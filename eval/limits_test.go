@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+)
+
+func testEvalContext(ctx context.Context, input string, limits Limits) (object.Object, error) {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	return EvalContext(ctx, program, object.NewEnvironment(), limits)
+}
+
+func TestEvalContextLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected error
+	}{
+		{"while (true) {}", Limits{MaxSteps: 1000}, ErrStepLimit},
+		{"let f = fn() { f() }; f()", Limits{MaxSteps: 5000}, ErrStepLimit},
+		{`let s = "ab"; while (true) { s = s + s }`, Limits{MaxAlloc: 1 << 20}, ErrAllocLimit},
+		{"let a = []; while (true) { a = push(a, 1) }", Limits{MaxAlloc: 10000}, ErrAllocLimit},
+		{"[1, 2, 3].map(fn(x) { x })", Limits{MaxAlloc: 5}, ErrAllocLimit},
+		{"while (true) { try { 1 } catch (e) { 2 } }", Limits{MaxSteps: 1000}, ErrStepLimit},
+		{"while (true) { try { while (true) {} } catch (e) { 1 } finally { 2 } }", Limits{MaxSteps: 1000}, ErrStepLimit},
+		// each of these would exhaust memory if it was charged only once built
+		{"array(0..50000000000)", Limits{MaxAlloc: 1000}, ErrAllocLimit},
+		{"[1, ...0..50000000000]", Limits{MaxAlloc: 1000}, ErrAllocLimit},
+		{"let f = fn(...xs) { xs }; f(...0..50000000000)", Limits{MaxAlloc: 1000}, ErrAllocLimit},
+		{`let s = array(0..9999).join(""); s.replace("", s)`, Limits{MaxAlloc: 1 << 20}, ErrAllocLimit},
+		{`let s = "ab"; while (true) { s = s.upper() + s }`, Limits{MaxAlloc: 1 << 20}, ErrAllocLimit},
+	}
+
+	for _, tt := range tests {
+		result, err := testEvalContext(context.Background(), tt.input, tt.limits)
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("wrong error for %q. expected=%v, got=%v (result %v)", tt.input, tt.expected, err, result)
+			continue
+		}
+
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || !limitErr.Pos.IsValid() {
+			t.Errorf("error for %q is not a LimitError with a position. got=%#v", tt.input, err)
+		}
+	}
+}
+
+func TestEvalContextWithinLimits(t *testing.T) {
+	result, err := testEvalContext(context.Background(),
+		"let sum = 0; for (i in 1..100) { sum += i }; sum", Limits{MaxSteps: 10000, MaxAlloc: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 5050)
+
+	result, err = testEvalContext(context.Background(), "1 + true", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if errObj, ok := result.(*object.Error); !ok || errObj.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected the runtime error as result. got=%T (%+v)", result, result)
+	}
+}
+
+func TestEvalContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := testEvalContext(ctx, "while (true) {}", Limits{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded. got=%v", err)
+	}
+
+	// a builtin building a huge value takes a single step
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = testEvalContext(ctx, "array(0..50000000000)", Limits{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded. got=%v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	_, err = testEvalContext(ctx, "1", Limits{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled. got=%v", err)
+	}
+}
+
+func TestEvalContextRestoresState(t *testing.T) {
+	env := object.NewEnvironment()
+
+	program := parser.New(lexer.New("let f = fn(n) { f(n + 1) }; f(0)")).ParseProgram()
+	if _, err := EvalContext(context.Background(), program, env, Limits{MaxSteps: 100}); !errors.Is(err, ErrStepLimit) {
+		t.Fatalf("expected ErrStepLimit. got=%v", err)
+	}
+
+	state := env.State()
+	if state.Depth != 0 || state.Abort != nil || state.MaxSteps != 0 {
+		t.Errorf("state not restored. got=%+v", state)
+	}
+
+	testIntegerObject(t, Eval(parser.New(lexer.New("let g = fn(x) { x * 2 }; g(21)")).ParseProgram(), env), 42)
+}
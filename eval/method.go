@@ -0,0 +1,371 @@
+package eval
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/threeaccents/digolang/object"
+)
+
+// Method implements `receiver.name(args...)` for receivers of one object
+// type.
+type Method func(state *object.State, receiver object.Object, args ...object.Object) object.Object
+
+// methods is shared by every program, so methodsMu guards it against
+// registrations made while programs run.
+var (
+	methods   = map[object.ObjectType]map[string]Method{}
+	methodsMu sync.RWMutex
+)
+
+// RegisterMethod makes fn callable as a method named name on every value of
+// type t, replacing any method already registered under that name. It is
+// safe to call while programs are running, which see the method from then
+// on.
+func RegisterMethod(t object.ObjectType, name string, fn Method) {
+	methodsMu.Lock()
+	defer methodsMu.Unlock()
+
+	if methods[t] == nil {
+		methods[t] = map[string]Method{}
+	}
+	methods[t][name] = fn
+}
+
+func lookupMethod(receiver object.Object, name string) (Method, bool) {
+	methodsMu.RLock()
+	defer methodsMu.RUnlock()
+
+	m, ok := methods[receiver.Type()][name]
+	return m, ok
+}
+
+func evalSelectorExpression(left object.Object, name string) object.Object {
+	if m, ok := left.(*object.Module); ok {
+		if val, ok := m.Exports[name]; ok {
+			return val
+		}
+		return newError("module %s does not export %s", displayPath(m.Path), name)
+	}
+
+	if err, ok := left.(*object.Error); ok {
+		if val, ok := errorField(err, name); ok {
+			return val
+		}
+	}
+
+	if hash, ok := left.(*object.Hash); ok {
+		key := &object.String{Value: name}
+		if pair, ok := hash.Pairs[key.HashKey()]; ok {
+			return pair.Value
+		}
+	}
+
+	if _, ok := lookupMethod(left, name); ok {
+		return &object.Selector{
+			Expression: left,
+			Selector:   &object.String{Value: name},
+		}
+	}
+
+	if left.Type() == object.HASH_OBJ {
+		return NULL
+	}
+
+	return newError("undefined method %s for %s", name, left.Type())
+}
+
+func applyMethod(state *object.State, sel *object.Selector, args []object.Object) object.Object {
+	name := sel.Selector.(*object.String).Value
+
+	m, ok := lookupMethod(sel.Expression, name)
+	if !ok {
+		return newError("undefined method %s for %s", name, sel.Expression.Type())
+	}
+
+	return m(state, sel.Expression, args...)
+}
+
+func init() {
+	RegisterMethod(object.STRING_OBJ, "upper", stringMethod("upper", 0, func(s string, args []object.Object) object.Object {
+		return &object.String{Value: strings.ToUpper(s)}
+	}))
+	RegisterMethod(object.STRING_OBJ, "lower", stringMethod("lower", 0, func(s string, args []object.Object) object.Object {
+		return &object.String{Value: strings.ToLower(s)}
+	}))
+	RegisterMethod(object.STRING_OBJ, "trim", stringMethod("trim", 0, func(s string, args []object.Object) object.Object {
+		return &object.String{Value: strings.TrimSpace(s)}
+	}))
+	RegisterMethod(object.STRING_OBJ, "split", stringSplit)
+	RegisterMethod(object.STRING_OBJ, "contains", stringMethod("contains", 1, func(s string, args []object.Object) object.Object {
+		return nativeBoolToBooleanObject(strings.Contains(s, args[0].(*object.String).Value))
+	}))
+	RegisterMethod(object.STRING_OBJ, "startsWith", stringMethod("startsWith", 1, func(s string, args []object.Object) object.Object {
+		return nativeBoolToBooleanObject(strings.HasPrefix(s, args[0].(*object.String).Value))
+	}))
+	RegisterMethod(object.STRING_OBJ, "endsWith", stringMethod("endsWith", 1, func(s string, args []object.Object) object.Object {
+		return nativeBoolToBooleanObject(strings.HasSuffix(s, args[0].(*object.String).Value))
+	}))
+	RegisterMethod(object.STRING_OBJ, "replace", stringReplace)
+
+	RegisterMethod(object.ARRAY_OBJ, "map", arrayMap)
+	RegisterMethod(object.ARRAY_OBJ, "filter", arrayFilter)
+	RegisterMethod(object.ARRAY_OBJ, "reduce", arrayReduce)
+	RegisterMethod(object.ARRAY_OBJ, "join", arrayJoin)
+	RegisterMethod(object.ARRAY_OBJ, "contains", arrayContains)
+	RegisterMethod(object.ARRAY_OBJ, "indexOf", arrayIndexOf)
+
+	RegisterMethod(object.HASH_OBJ, "keys", hashKeys)
+	RegisterMethod(object.HASH_OBJ, "values", hashValues)
+	RegisterMethod(object.HASH_OBJ, "has", hashHas)
+}
+
+// stringMethod adapts fn, which takes n string arguments, into a Method on
+// strings that checks its arguments. The results of fn are no larger than
+// the receiver, so they are charged once built.
+func stringMethod(name string, n int, fn func(s string, args []object.Object) object.Object) Method {
+	return func(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+		if err := checkStringArgs(name, n, args); err != nil {
+			return err
+		}
+
+		result := fn(receiver.(*object.String).Value, args)
+		if err := allocate(state, result); err != nil {
+			return err
+		}
+		return result
+	}
+}
+
+func checkStringArgs(name string, n int, args []object.Object) *object.Error {
+	if len(args) != n {
+		return newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	for _, arg := range args {
+		if arg.Type() != object.STRING_OBJ {
+			return newError("argument to `%s` must be STRING, got %s", name, arg.Type())
+		}
+	}
+	return nil
+}
+
+func stringSplit(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkStringArgs("split", 1, args); err != nil {
+		return err
+	}
+
+	s := receiver.(*object.String).Value
+	sep := args[0].(*object.String).Value
+
+	parts := int64(utf8.RuneCountInString(s))
+	if sep != "" {
+		parts = int64(strings.Count(s, sep)) + 1
+	}
+	if err := charge(state, parts+int64(len(s))); err != nil {
+		return err
+	}
+
+	var elements []object.Object
+	for _, part := range strings.Split(s, sep) {
+		elements = append(elements, &object.String{Value: part})
+	}
+	return &object.Array{Elements: elements}
+}
+
+func stringReplace(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if err := checkStringArgs("replace", 2, args); err != nil {
+		return err
+	}
+
+	s := receiver.(*object.String).Value
+	from, to := args[0].(*object.String).Value, args[1].(*object.String).Value
+
+	// an empty string matches before every character and at the end
+	matches := int64(utf8.RuneCountInString(s)) + 1
+	if from != "" {
+		matches = int64(strings.Count(s, from))
+	}
+	if err := charge(state, int64(len(s))+matches*int64(len(to))); err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.Replace(s, from, to, -1)}
+}
+
+func arrayMap(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	elements := receiver.(*object.Array).Elements
+	if err := charge(state, int64(len(elements))); err != nil {
+		return err
+	}
+	result := make([]object.Object, 0, len(elements))
+
+	for _, el := range elements {
+		v := applyFunction(state, args[0], []object.Object{el}, nil)
+		if isError(v) {
+			return v
+		}
+		result = append(result, v)
+	}
+
+	return &object.Array{Elements: result}
+}
+
+func arrayFilter(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	result := []object.Object{}
+
+	for _, el := range receiver.(*object.Array).Elements {
+		v := applyFunction(state, args[0], []object.Object{el}, nil)
+		if isError(v) {
+			return v
+		}
+		if isTruthy(v) {
+			result = append(result, el)
+		}
+	}
+
+	// the result is no larger than the receiver, which was already charged
+	if err := charge(state, int64(len(result))); err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: result}
+}
+
+func arrayReduce(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+
+	acc := args[1]
+
+	for _, el := range receiver.(*object.Array).Elements {
+		acc = applyFunction(state, args[0], []object.Object{acc, el}, nil)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+func arrayJoin(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	sep, ok := args[0].(*object.String)
+	if !ok {
+		return newError("argument to `join` must be STRING, got %s", args[0].Type())
+	}
+
+	elements := receiver.(*object.Array).Elements
+
+	var parts []string
+	var size int64
+	for i, el := range elements {
+		if err := poll(state, i); err != nil {
+			return err
+		}
+
+		var part string
+		if s, ok := el.(*object.String); ok {
+			part = s.Value
+		} else {
+			part = el.Inspect()
+		}
+		parts = append(parts, part)
+		size += int64(len(part))
+	}
+	if len(parts) > 1 {
+		size += int64(len(sep.Value)) * int64(len(parts)-1)
+	}
+
+	if err := charge(state, size); err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+func arrayContains(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	return nativeBoolToBooleanObject(indexOf(receiver.(*object.Array), args[0]) >= 0)
+}
+
+func arrayIndexOf(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	return &object.Integer{Value: int64(indexOf(receiver.(*object.Array), args[0]))}
+}
+
+func indexOf(arr *object.Array, obj object.Object) int {
+	for i, el := range arr.Elements {
+		if evalInfixExpression("==", el, obj) == TRUE {
+			return i
+		}
+	}
+	return -1
+}
+
+func hashKeys(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+
+	hash := receiver.(*object.Hash)
+	if err := charge(state, int64(len(hash.Pairs))); err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, pair := range hash.Ordered() {
+		elements = append(elements, pair.Key)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func hashValues(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 0 {
+		return newError("wrong number of arguments. got=%d, want=0", len(args))
+	}
+
+	hash := receiver.(*object.Hash)
+	if err := charge(state, int64(len(hash.Pairs))); err != nil {
+		return err
+	}
+
+	elements := []object.Object{}
+	for _, pair := range hash.Ordered() {
+		elements = append(elements, pair.Value)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func hashHas(state *object.State, receiver object.Object, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	hashable, ok := args[0].(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", args[0].Type())
+	}
+
+	_, ok = receiver.(*object.Hash).Pairs[hashable.HashKey()]
+	return nativeBoolToBooleanObject(ok)
+}
@@ -0,0 +1,221 @@
+package eval
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
+)
+
+// Loader reads, evaluates and caches the modules imported by a program.
+// Every file is evaluated at most once per loader, into its own environment.
+type Loader struct {
+	// SearchPath lists the directories searched for imports that are not
+	// found next to the importing file.
+	SearchPath []string
+
+	modules map[string]*object.Module
+	// loading is the chain of files currently being evaluated, innermost
+	// last, used to detect import cycles.
+	loading []string
+}
+
+func NewLoader(searchPath ...string) *Loader {
+	return &Loader{
+		SearchPath: searchPath,
+		modules:    make(map[string]*object.Module),
+	}
+}
+
+// DefaultSearchPath returns the directories listed in the DIGOPATH
+// environment variable.
+func DefaultSearchPath() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv("DIGOPATH")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Import implements object.Importer. Modules are evaluated as part of the
+// evaluation of from, so they count against its limits.
+func (l *Loader) Import(from *object.Environment, path string) (*object.Module, error) {
+	file, err := l.resolve(from.File(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	if m, ok := l.modules[file]; ok {
+		return m, nil
+	}
+
+	for i, f := range l.loading {
+		if f == file {
+			var chain []string
+			for _, f := range append(l.loading[i:], file) {
+				chain = append(chain, displayPath(f))
+			}
+			return nil, fmt.Errorf("import cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	l.loading = append(l.loading, file)
+	defer func() { l.loading = l.loading[:len(l.loading)-1] }()
+
+	m, err := l.load(file, from)
+	if err != nil {
+		return nil, err
+	}
+
+	l.modules[file] = m
+
+	return m, nil
+}
+
+// resolve finds the file an import of path from the file from refers to.
+// Paths starting with ./ or ../ are only looked up next to the importing
+// file; other relative paths fall back to the search path.
+func (l *Loader) resolve(from, path string) (string, error) {
+	if filepath.Ext(path) == "" {
+		path += ".digo"
+	}
+
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = append(candidates, path)
+	} else {
+		dir := "."
+		if from != "" {
+			dir = filepath.Dir(from)
+		}
+		candidates = append(candidates, filepath.Join(dir, path))
+
+		if !strings.HasPrefix(path, "./") && !strings.HasPrefix(path, "../") {
+			for _, dir := range l.SearchPath {
+				candidates = append(candidates, filepath.Join(dir, path))
+			}
+		}
+	}
+
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			return filepath.Abs(c)
+		}
+	}
+
+	return "", fmt.Errorf("cannot find module %s", path)
+}
+
+func (l *Loader) load(file string, from *object.Environment) (*object.Module, error) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.NewFile(displayPath(file), string(src)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		var msgs []string
+		for _, d := range errs {
+			msgs = append(msgs, d.Error())
+		}
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+	// warnings about the module are for whoever works on it
+	resolver.Resolve(program)
+
+	env := object.NewModuleEnvironment(file, l)
+	env.ShareState(from)
+	if result := Eval(program, env); isError(result) {
+		return nil, fmt.Errorf("%s: %s", displayPath(file), result.(*object.Error).Message)
+	}
+
+	m := &object.Module{
+		Path:    file,
+		Exports: make(map[string]object.Object),
+	}
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		name := export.Statement.Name.Value
+		m.Exports[name], _ = env.Get(name)
+		m.Names = append(m.Names, name)
+	}
+
+	return m, nil
+}
+
+// displayPath shortens file to a path relative to the working directory when
+// it is inside it.
+func displayPath(file string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return file
+	}
+
+	rel, err := filepath.Rel(wd, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+
+	return rel
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	values, err := Import(node, env)
+	if err != nil {
+		return err
+	}
+
+	for i, name := range node.BoundNames() {
+		env.Set(name, values[i])
+	}
+
+	return nil
+}
+
+// Import imports the module of node for a program running in env and
+// returns the values it binds, in the order of its BoundNames.
+func Import(node *ast.ImportStatement, env *object.Environment) ([]object.Object, *object.Error) {
+	importer := env.Importer()
+	if importer == nil {
+		return nil, newError("imports are not available here")
+	}
+
+	m, err := importer.Import(env, node.Path.Value)
+	if err != nil {
+		if state := env.State(); state.Abort != nil {
+			return nil, abortError(state)
+		}
+		return nil, newError("%s", err)
+	}
+
+	if len(node.Names) == 0 {
+		if node.BoundNames() == nil {
+			return nil, newError("cannot name module %s, use `as`", node.Path.Value)
+		}
+		return []object.Object{m}, nil
+	}
+
+	values := make([]object.Object, len(node.Names))
+	for i, n := range node.Names {
+		val, ok := m.Exports[n.Value]
+		if !ok {
+			return nil, newError("module %s does not export %s", node.Path.Value, n.Value)
+		}
+		values[i] = val
+	}
+
+	return values, nil
+}
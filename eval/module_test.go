@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+)
+
+// writeModules creates the given files below a new temporary directory and
+// returns the directory.
+func writeModules(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "digo-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func testEvalFile(loader *Loader, path, input string) object.Object {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+
+	return Eval(program, object.NewModuleEnvironment(path, loader))
+}
+
+func TestImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.digo": `
+			let square = fn(x) { x * x };
+			export let pi = 3;
+			export let area = fn(r) { pi * square(r) };
+		`,
+		"util/strings.digo": `
+			import "../math.digo" as m;
+			export let shout = fn(s) { s.upper() + "!" };
+			export let piTwice = m.pi * 2;
+		`,
+		"lib/greet.digo": `export let hello = fn(name) { "hello " + name };`,
+		"counter.digo": `
+			let n = 0;
+			export let count = fn() { n += 1; n };
+		`,
+		"a.digo":    `import "b.digo" as b; export let x = 1;`,
+		"b.digo":    `import "a.digo" as a; export let y = 2;`,
+		"bad.digo":  `let = 1;`,
+		"fail.digo": `export let x = 1 / 0;`,
+	})
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "math.digo" as m; m.area(2)`, 12},
+		{`import "math" as m; m.pi`, 3},
+		{`import "math.digo"; math.pi`, 3},
+		{`import { area, pi } from "./math.digo"; area(1) + pi`, 6},
+		{`import "util/strings.digo" as s; s.shout("hi")`, "HI!"},
+		{`import { piTwice } from "util/strings"; piTwice`, 6},
+		{`import "greet" as g; g.hello("you")`, "hello you"},
+		{`import "counter" as a; import "counter" as b; a.count(); b.count()`, 2},
+		{`import "math" as m; m.square(2)`, "module math.digo does not export square"},
+		{`import { square } from "math"`, "module math does not export square"},
+		{`import "./greet"`, "cannot find module ./greet.digo"},
+		{`import "missing.digo" as m`, "cannot find module missing.digo"},
+		{`import "a.digo" as a`, "a.digo: b.digo: import cycle: a.digo -> b.digo -> a.digo"},
+		{`import "bad.digo" as bad`, "bad.digo:1:5: expected next token to be IDENT, got = instead"},
+		{`import "fail.digo" as f`, "fail.digo: division by zero"},
+		{`import "math" as m; m.pi = 4`, "cannot assign to field pi of MODULE"},
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	for _, tt := range tests {
+		loader := NewLoader(filepath.Join(dir, "lib"))
+		evaluated := testEvalFile(loader, filepath.Join(dir, "main.digo"), tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("String has wrong value for %s. got=%q, want=%q", tt.input, obj.Value, expected)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message for %s. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			default:
+				t.Errorf("unexpected object for %s. got=%T (%+v)", tt.input, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestImportWithoutLoader(t *testing.T) {
+	evaluated := testEval(t, `import "math" as m`)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+	}
+	if !strings.Contains(errObj.Message, "imports are not available") {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"github.com/threeaccents/digolang/object"
+)
+
+// The functions below perform the operations of the language on values.
+// Backends other than Eval, such as the bytecode VM, use them so that every
+// backend behaves the same.
+
+// NewError returns a runtime error with the given message.
+func NewError(format string, a ...interface{}) *object.Error {
+	return newError(format, a...)
+}
+
+// IsError reports whether obj is an error that has not been handled.
+func IsError(obj object.Object) bool {
+	return isError(obj)
+}
+
+// IsFatal reports whether obj is an error no try expression may catch.
+func IsFatal(obj object.Object) bool {
+	return isFatal(obj)
+}
+
+// LookupBuiltin returns the builtin function called name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// Infix applies a binary operator. `&&` and `||` are applied to both
+// operands; callers short-circuit them with ShortCircuits.
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// ShortCircuits reports whether left alone decides a `&&` or `||`.
+func ShortCircuits(operator string, left object.Object) bool {
+	return shortCircuits(operator, left)
+}
+
+// Prefix applies a unary operator.
+func Prefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// Index evaluates `left[index]`.
+func Index(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// Slice evaluates `left[low:high]`. A missing bound is nil.
+func Slice(left, low, high object.Object) object.Object {
+	return evalSlice(left, low, high)
+}
+
+// Select evaluates `left.name`.
+func Select(left object.Object, name string) object.Object {
+	return evalSelectorExpression(left, name)
+}
+
+// SetIndex evaluates `left[index] op val`, where op is `=` or a compound
+// assignment operator.
+func SetIndex(operator string, left, index, val object.Object) object.Object {
+	return evalIndexAssignment(operator, left, index, val)
+}
+
+// SetField evaluates `left.name op val`.
+func SetField(operator string, left object.Object, name string, val object.Object) object.Object {
+	return evalFieldAssignment(operator, left, name, val)
+}
+
+// Iterate returns a function yielding the values a for-in loop over obj
+// visits.
+func Iterate(obj object.Object) (func() (object.Object, bool), *object.Error) {
+	return iterate(obj)
+}
+
+// Spread returns the values `...obj` expands to, charging them to state.
+func Spread(state *object.State, obj object.Object) ([]object.Object, *object.Error) {
+	return spread(state, obj)
+}
+
+// Call calls fn, which may be any callable value, in the evaluation state
+// belongs to.
+func Call(state *object.State, fn object.Object, args []object.Object, kwargs []KeywordArg) object.Object {
+	return applyFunction(state, fn, args, kwargs)
+}
+
+// Throw returns the error `throw val` raises.
+func Throw(val object.Object) *object.Error {
+	return throwValue(val)
+}
+
+// Step counts one step of an evaluation, returning the error stopping it if
+// it exceeded its limits.
+func Step(state *object.State) *object.Error {
+	return step(state)
+}
+
+// Allocate counts obj, a newly created value, against the allocation limit
+// of an evaluation.
+func Allocate(state *object.State, obj object.Object) *object.Error {
+	return allocate(state, obj)
+}
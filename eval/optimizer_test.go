@@ -0,0 +1,89 @@
+package eval_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/optimizer"
+	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
+)
+
+// The evaluator tests run every program once more after optimizing it,
+// which must not change its result.
+func init() {
+	eval.Backends = append(eval.Backends, compareOptimized)
+}
+
+func compareOptimized(t *testing.T, input string, limits eval.Limits, want object.Object) {
+	t.Helper()
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	optimizer.Optimize(program)
+	resolver.Resolve(program)
+
+	got, err := eval.EvalContext(context.Background(), program, object.NewEnvironment(), limits)
+	if err != nil {
+		t.Errorf("optimized program stopped: %s\n%s", err, input)
+		return
+	}
+
+	if describe(got) != describe(want) {
+		t.Errorf("optimized program gives a different result.\nprogram:   %s\noptimized: %s\nwant: %s\ngot:  %s",
+			input, program.String(), describe(want), describe(got))
+	}
+}
+
+// The source of an optimized program, as `digo run -dump-optimized` prints
+// it, parses back to a program giving the same result, which formats to the
+// same source again.
+func TestFormattedPrograms(t *testing.T) {
+	inputs := []string{
+		"let x = 2; let f = fn(n) { if (n > x) { n * 10 } else { -n } }; [f(1), f(3)]",
+		"if (true) { let x = 2; len([x]) }; let y = 10; let f = fn() { y }; f()",
+		"let f = fn(a, b = 2, ...rest) { a + b + len(rest) }; [f(1), f(1, b = 5), f(...[1, 2, 3, 4])]",
+		"let g = fn(x: int, ...xs: int) -> int { x }; let h: fn(int) -> int = fn(n) { n }; g(h(4))",
+		`let name = "wor\"ld"; "hello ${name}! ${if (true) { "yes" } else { "no" }} \${name}"`,
+		"let sum = 0; for (i in 0..<10) { if (i % 2 == 0) { continue }; sum += i }; sum",
+		"let i = 0; while (true) { i += 1; if (i > 5) { break } }; i",
+		"let h = {\"a\": [1, 2, 3], 2: {3: 4}}; [h[\"a\"][1:], h[2][3], h.a[-1]]",
+		"let a = [1, 2, 3]; a[0] = 5; a[1] += 1; let h = {}; h.x = 1; [a, h]",
+		"let r = try { throw error(\"bad\") } catch (e) { e.message } finally { 1 }; r",
+		"(fn(x) { x * 2 })(21)",
+		"(if (true) { [1, 2] } else { [] })[1]",
+		"let x = 0 - 9223372036854775807 - 1; [x, -x, 0 - 2.5, !true, ~1, 1 << 3]",
+		"let f = fn() { }; let g = fn() { return 1 }; f(); g()",
+		"{1: 2}",
+		"[1, 2].map(fn(x) { x + 1 }).join(\",\")",
+	}
+
+	for _, input := range inputs {
+		program := parser.New(lexer.New(input)).ParseProgram()
+		resolver.Resolve(program)
+		want := eval.Eval(program, object.NewEnvironment())
+
+		program = parser.New(lexer.New(input)).ParseProgram()
+		source := ast.Format(optimizer.Optimize(program))
+
+		p := parser.New(lexer.New(source))
+		formatted := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("formatted program does not parse: %v\nprogram: %s\nformatted:\n%s", p.Errors(), input, source)
+			continue
+		}
+		if again := ast.Format(formatted); again != source {
+			t.Errorf("formatting the formatted program changes it.\nprogram: %s\nfirst:\n%s\nsecond:\n%s", input, source, again)
+		}
+
+		resolver.Resolve(formatted)
+		got := eval.Eval(formatted, object.NewEnvironment())
+		if describe(got) != describe(want) {
+			t.Errorf("formatted program gives a different result.\nprogram: %s\nformatted:\n%s\nwant: %s\ngot:  %s",
+				input, source, describe(want), describe(got))
+		}
+	}
+}
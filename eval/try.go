@@ -0,0 +1,122 @@
+package eval
+
+import (
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/object"
+)
+
+// Error kinds. Errors raised by the interpreter are runtime errors; values
+// thrown by scripts and errors built by error() default to thrownError.
+const (
+	runtimeError = "RuntimeError"
+	thrownError  = "Error"
+	limitError   = "LimitError"
+)
+
+// evalTryExpression evaluates the try block and, if it raised an error, the
+// catch clause with the error bound to its parameter. The finally clause
+// always runs; its value is discarded unless it raises, returns or leaves a
+// loop itself.
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(node.Block, env)
+	if isFatal(result) {
+		return result
+	}
+
+	if node.Catch != nil && isError(result) {
+		err := result.(*object.Error)
+		err.Handled = true
+
+		catchEnv := object.NewScopeEnvironment(env, locals(node.Catch))
+		if node.Param != nil {
+			bind(catchEnv, node.Param, err)
+		}
+
+		result = Eval(node.Catch, catchEnv)
+		if isFatal(result) {
+			return result
+		}
+	}
+
+	if node.Finally != nil {
+		final := Eval(node.Finally, env)
+		if final == BREAK || final == CONTINUE || isError(final) ||
+			final != nil && final.Type() == object.RETURN_VALUE_OBJ {
+			return final
+		}
+	}
+
+	return result
+}
+
+func isFatal(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	return ok && err.Fatal
+}
+
+// evalThrowStatement raises the thrown value. Errors are raised again with
+// their stack intact; any other value is wrapped in a new error.
+func evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if unwinds(val) {
+		return val
+	}
+
+	return throwValue(val)
+}
+
+func throwValue(val object.Object) *object.Error {
+	if err, ok := val.(*object.Error); ok {
+		thrown := *err
+		thrown.Stack = append([]object.Frame(nil), err.Stack...)
+		thrown.Handled = false
+		thrown.Fatal = false
+		return &thrown
+	}
+
+	msg := val.Inspect()
+	if s, ok := val.(*object.String); ok {
+		msg = s.Value
+	}
+
+	return &object.Error{Message: msg, Kind: thrownError, Value: val}
+}
+
+// errorField returns the fields scripts can read from a caught error.
+func errorField(err *object.Error, name string) (object.Object, bool) {
+	switch name {
+	case "message":
+		return &object.String{Value: err.Message}, true
+	case "kind":
+		return &object.String{Value: err.Kind}, true
+	case "stack":
+		frames := make([]object.Object, len(err.Stack))
+		for i, f := range err.Stack {
+			frames[i] = &object.String{Value: f.String()}
+		}
+		return &object.Array{Elements: frames}, true
+	case "value":
+		if err.Value == nil {
+			return NULL, true
+		}
+		return err.Value, true
+	}
+
+	return nil, false
+}
+
+// frameName returns the name calls of fn are shown as in stack traces. It
+// reports false for builtins, which never appear in them.
+func frameName(fn object.Object) (string, bool) {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if fn.Name == "" {
+			return "<anonymous>", true
+		}
+		return fn.Name, true
+	case *object.Selector:
+		return fn.Selector.(*object.String).Value, true
+	}
+
+	return "", false
+}
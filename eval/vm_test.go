@@ -0,0 +1,54 @@
+package eval_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/threeaccents/digolang/compiler"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/vm"
+)
+
+// The evaluator tests run every program on the bytecode VM too, which has
+// to produce the same values and errors.
+func init() {
+	eval.Backends = append(eval.Backends, compareVM)
+}
+
+func compareVM(t *testing.T, input string, limits eval.Limits, want object.Object) {
+	t.Helper()
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Errorf("compiler error: %s\n%s", err, input)
+		return
+	}
+
+	got, err := vm.New(c.Bytecode(), object.NewEnvironment()).RunContext(context.Background(), limits)
+	if err != nil {
+		t.Errorf("vm stopped: %s\n%s", err, input)
+		return
+	}
+
+	if describe(got) != describe(want) {
+		t.Errorf("vm result differs from eval.\nprogram: %s\neval: %s\nvm:   %s",
+			input, describe(want), describe(got))
+	}
+}
+
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+
+	if err, ok := obj.(*object.Error); ok {
+		return err.Inspect() + "\n" + err.Traceback()
+	}
+
+	return string(obj.Type()) + " " + obj.Inspect()
+}
@@ -1,36 +1,87 @@
 package lexer
 
 import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/threeaccents/digolang/diag"
 	"github.com/threeaccents/digolang/token"
 )
 
+// Diagnostic codes reported by the lexer.
+const (
+	codeUnterminatedComment = "L0001"
+	codeUnterminatedString  = "L0002"
+	codeInvalidEscape       = "L0003"
+	codeMalformedNumber     = "L0004"
+)
+
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
-	char         byte
+	char         rune // the current rune, or 0 at the end of input
+
+	filename string
+	line     int
+	column   int
+
+	keepComments bool
+	errors       []diag.Diagnostic
+
+	// interpolations holds, for every interpolated string being lexed, the
+	// number of unclosed `{` inside its current `${ ... }` expression.
+	interpolations []int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile returns a lexer whose token positions report filename as their
+// source file.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 
 	l.readChar()
 
 	return l
 }
 
+// KeepComments makes the lexer attach comments to the token that follows
+// them instead of discarding them.
+func (l *Lexer) KeepComments() {
+	l.keepComments = true
+}
+
+// Errors returns the diagnostics for malformed input seen so far.
+func (l *Lexer) Errors() []diag.Diagnostic {
+	return l.errors
+}
+
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+	comments := l.eatTrivia()
+
+	pos := l.pos()
+	tok := l.readToken()
+	tok.Pos = pos
+	tok.End = l.pos()
 
-	l.eatWhitespace()
+	if l.keepComments {
+		tok.Comments = comments
+	}
+
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch l.char {
 	case '=':
 		if l.peekChar() == '=' {
-			ch := l.char
-			l.readChar()
-			tok.Literal = string(ch) + string(l.char)
-			tok.Type = token.EQ
+			tok = l.newTwoCharToken(token.EQ)
 		} else {
 			tok = newToken(token.ASSIGN, l.char)
 		}
@@ -38,10 +89,7 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.char)
 	case '!':
 		if l.peekChar() == '=' {
-			ch := l.char
-			l.readChar()
-			tok.Literal = string(ch) + string(l.char)
-			tok.Type = token.NOT_EQ
+			tok = l.newTwoCharToken(token.NOT_EQ)
 		} else {
 			tok = newToken(token.BANG, l.char)
 		}
@@ -56,27 +104,108 @@ func (l *Lexer) NextToken() token.Token {
 	case ',':
 		tok = newToken(token.COMMA, l.char)
 	case '+':
-		tok = newToken(token.PLUS, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.PLUS_ASSIGN)
+		} else {
+			tok = newToken(token.PLUS, l.char)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.char)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.MINUS_ASSIGN)
+		case '>':
+			tok = l.newTwoCharToken(token.ARROW)
+		default:
+			tok = newToken(token.MINUS, l.char)
+		}
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(token.LBRACE, l.char)
 	case '}':
+		if n := len(l.interpolations); n > 0 {
+			if l.interpolations[n-1] == 0 {
+				l.interpolations = l.interpolations[:n-1]
+				tok.Literal, tok.Type = l.readStringPart(true)
+				return tok
+			}
+			l.interpolations[n-1]--
+		}
 		tok = newToken(token.RBRACE, l.char)
 	case '/':
-		tok = newToken(token.SLASH, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.SLASH_ASSIGN)
+		} else {
+			tok = newToken(token.SLASH, l.char)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.char)
+		if l.peekChar() == '=' {
+			tok = l.newTwoCharToken(token.ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(token.ASTERISK, l.char)
+		}
 	case ':':
 		tok = newToken(token.COLON, l.char)
 	case '<':
-		tok = newToken(token.LT, l.char)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.LT_EQ)
+		case '<':
+			tok = l.newTwoCharToken(token.SHL)
+		default:
+			tok = newToken(token.LT, l.char)
+		}
 	case '.':
+		if l.peekChar() == '.' {
+			tok = l.newTwoCharToken(token.RANGE)
+			switch l.peekChar() {
+			case '<':
+				l.readChar()
+				tok = token.Token{Type: token.RANGE_EXCLUSIVE, Literal: "..<"}
+			case '.':
+				l.readChar()
+				tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+			}
+			break
+		}
+		if isDigit(l.peekChar()) {
+			tok.Literal, tok.Type = l.readNumber()
+			return tok
+		}
 		tok = newToken(token.PERIOD, l.char)
 	case '>':
-		tok = newToken(token.GT, l.char)
+		switch l.peekChar() {
+		case '=':
+			tok = l.newTwoCharToken(token.GT_EQ)
+		case '>':
+			tok = l.newTwoCharToken(token.SHR)
+		default:
+			tok = newToken(token.GT, l.char)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.char)
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.newTwoCharToken(token.AND)
+		} else {
+			tok = newToken(token.AMPERSAND, l.char)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.newTwoCharToken(token.OR)
+		} else {
+			tok = newToken(token.PIPE, l.char)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.char)
+	case '~':
+		tok = newToken(token.TILDE, l.char)
 	case '"':
-		tok.Literal = l.readString()
+		tok.Literal, tok.Type = l.readStringPart(false)
+		return tok
+	case '`':
+		tok.Literal = l.readRawString()
 		tok.Type = token.STRING
 		return tok
 	case 0:
@@ -89,12 +218,12 @@ func (l *Lexer) NextToken() token.Token {
 			return tok
 		}
 		if isDigit(l.char) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		}
 
-		tok = newToken(token.ILLEGAL, l.char)
+		tok.Literal = l.current()
+		tok.Type = token.ILLEGAL
 	}
 
 	l.readChar()
@@ -103,25 +232,59 @@ func (l *Lexer) NextToken() token.Token {
 }
 
 func (l *Lexer) readChar() {
-	defer func() {
-		l.position = l.readPosition
-		l.readPosition += 1
-	}()
+	if l.char == '\n' {
+		l.line++
+		l.column = 0
+	}
+	if l.readPosition <= len(l.input) {
+		l.column++
+	}
+
+	l.position = l.readPosition
 
 	if l.readPosition >= len(l.input) {
 		l.char = 0
+		l.readPosition++
 		return
 	}
 
-	l.char = l.input[l.readPosition]
+	// invalid UTF-8 decodes as utf8.RuneError one byte at a time
+	var width int
+	l.char, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.readPosition += width
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
 
-	return l.input[l.readPosition]
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return r
+}
+
+// current returns the source text of the current rune. Unlike
+// string(l.char) it keeps bytes that are not valid UTF-8 intact.
+func (l *Lexer) current() string {
+	if l.position >= len(l.input) {
+		return ""
+	}
+
+	return l.input[l.position:l.readPosition]
+}
+
+func (l *Lexer) pos() token.Position {
+	offset := l.position
+	if offset > len(l.input) {
+		offset = len(l.input)
+	}
+
+	return token.Position{
+		Filename: l.filename,
+		Offset:   offset,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 func (l *Lexer) eatWhitespace() {
@@ -130,50 +293,196 @@ func (l *Lexer) eatWhitespace() {
 	}
 }
 
+// eatTrivia skips whitespace and comments, returning the comments it passed.
+func (l *Lexer) eatTrivia() []token.Comment {
+	var comments []token.Comment
+
+	for {
+		l.eatWhitespace()
+
+		if l.char != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			return comments
+		}
+
+		pos := l.pos()
+		if l.peekChar() == '/' {
+			l.readLineComment()
+		} else {
+			l.readBlockComment()
+		}
+
+		comments = append(comments, token.Comment{
+			Text: l.input[pos.Offset:l.pos().Offset],
+			Pos:  pos,
+			End:  l.pos(),
+		})
+	}
+}
+
+func (l *Lexer) readLineComment() {
+	for l.char != '\n' && l.char != 0 {
+		l.readChar()
+	}
+}
+
+// readBlockComment reads a /* ... */ comment. Block comments nest, so
+// `/* a /* b */ c */` is a single comment.
+func (l *Lexer) readBlockComment() {
+	start := l.pos()
+	depth := 0
+
+	for {
+		switch {
+		case l.char == 0:
+			l.errorf(start, l.pos(), codeUnterminatedComment, "block comment not terminated")
+			return
+		case l.char == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.char == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return
+			}
+		}
+
+		l.readChar()
+	}
+}
+
 func (l *Lexer) readIdentifier() string {
 	position := l.position
 
-	for isLetter(l.char) {
+	for isLetter(l.char) || unicode.IsDigit(l.char) {
 		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readNumber() string {
-	position := l.position
+// readNumber reads an integer or floating-point literal. A `.` only starts
+// a fraction when a digit follows it.
+func (l *Lexer) readNumber() (string, token.TokenType) {
+	start := l.pos()
+
+	if l.char == '0' && strings.ContainsRune("xXoObB", l.peekChar()) {
+		l.readPrefixedInteger(start)
+		return l.input[start.Offset:l.position], token.INT
+	}
+
+	var tokType token.TokenType = token.INT
 
-	for isDigit(l.char) {
+	l.readDigits()
+
+	if l.char == '.' && isDigit(l.peekChar()) {
+		tokType = token.FLOAT
 		l.readChar()
+		l.readDigits()
 	}
 
-	return l.input[position:l.position]
+	if l.char == 'e' || l.char == 'E' {
+		tokType = token.FLOAT
+		l.readChar()
+		if l.char == '+' || l.char == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.char) {
+			l.errorf(start, l.pos(), codeMalformedNumber, "exponent has no digits")
+		}
+		l.readDigits()
+	}
+
+	lit := l.input[start.Offset:l.position]
+	switch {
+	case !separatesDigits(lit, isDigit):
+		l.errorf(start, l.pos(), codeMalformedNumber, "'_' must separate successive digits")
+	case tokType == token.INT && len(lit) > 1 && lit[0] == '0':
+		// C and older Go read these as octal; require 0o so 010 is not 8
+		l.errorf(start, l.pos(), codeMalformedNumber, "leading zero in decimal literal %s; use 0o for octal", lit)
+	}
+
+	return lit, tokType
 }
 
-func (l *Lexer) readString() string {
+// readPrefixedInteger reads a hexadecimal, octal or binary literal such as
+// 0xFF, 0o755 or 0b1010.
+func (l *Lexer) readPrefixedInteger(start token.Position) {
 	l.readChar()
 
-	position := l.position
+	var name string
+	var valid func(rune) bool
+	switch l.char {
+	case 'x', 'X':
+		name, valid = "hexadecimal", isHexDigit
+	case 'o', 'O':
+		name, valid = "octal", func(ch rune) bool { return '0' <= ch && ch <= '7' }
+	default:
+		name, valid = "binary", func(ch rune) bool { return ch == '0' || ch == '1' }
+	}
 
-	for l.char != '"' && l.char != 0 {
+	l.readChar()
+
+	digits := 0
+	for isLetter(l.char) || isDigit(l.char) {
+		if l.char != '_' {
+			if !valid(l.char) {
+				l.errorf(l.pos(), l.pos(), codeMalformedNumber, "invalid digit %q in %s literal", l.char, name)
+			}
+			digits++
+		}
 		l.readChar()
 	}
 
-	res := l.input[position:l.position]
+	lit := l.input[start.Offset:l.position]
+	switch {
+	case digits == 0:
+		l.errorf(start, l.pos(), codeMalformedNumber, "%s literal has no digits", name)
+	case !separatesDigits(lit[1:], func(ch rune) bool { return valid(ch) || ch == rune(lit[1]) }):
+		// the prefix letter counts as a digit, so 0x_FF is allowed
+		l.errorf(start, l.pos(), codeMalformedNumber, "'_' must separate successive digits")
+	}
+}
 
-	l.readChar()
+func (l *Lexer) readDigits() {
+	for isDigit(l.char) || l.char == '_' {
+		l.readChar()
+	}
+}
+
+// separatesDigits reports whether every `_` in lit sits between two digits.
+func separatesDigits(lit string, isDigit func(rune) bool) bool {
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(lit)-1 || !isDigit(rune(lit[i-1])) || !isDigit(rune(lit[i+1])) {
+			return false
+		}
+	}
 
-	return res
+	return true
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
+
+// newTwoCharToken consumes the current character and returns a token made
+// of it and the one following it.
+func (l *Lexer) newTwoCharToken(tokenType token.TokenType) token.Token {
+	ch := l.char
+	l.readChar()
+
+	return token.Token{Type: tokenType, Literal: string(ch) + string(l.char)}
+}
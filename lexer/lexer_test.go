@@ -15,7 +15,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -142,3 +142,413 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  add(x,\n\t\"hi\")"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  [3]int // offset, line, column
+		expectedEnd  [3]int
+	}{
+		{token.LET, [3]int{0, 1, 1}, [3]int{3, 1, 4}},
+		{token.IDENT, [3]int{4, 1, 5}, [3]int{5, 1, 6}},
+		{token.ASSIGN, [3]int{6, 1, 7}, [3]int{7, 1, 8}},
+		{token.INT, [3]int{8, 1, 9}, [3]int{9, 1, 10}},
+		{token.SEMICOLON, [3]int{9, 1, 10}, [3]int{10, 1, 11}},
+		{token.IDENT, [3]int{13, 2, 3}, [3]int{16, 2, 6}},
+		{token.LPAREN, [3]int{16, 2, 6}, [3]int{17, 2, 7}},
+		{token.IDENT, [3]int{17, 2, 7}, [3]int{18, 2, 8}},
+		{token.COMMA, [3]int{18, 2, 8}, [3]int{19, 2, 9}},
+		{token.STRING, [3]int{21, 3, 2}, [3]int{25, 3, 6}},
+		{token.RPAREN, [3]int{25, 3, 6}, [3]int{26, 3, 7}},
+		{token.EOF, [3]int{26, 3, 7}, [3]int{26, 3, 7}},
+	}
+
+	l := NewFile("test.digo", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Filename != "test.digo" {
+			t.Errorf("tests[%d] - filename wrong. got=%q", i, tok.Pos.Filename)
+		}
+
+		if pos := [3]int{tok.Pos.Offset, tok.Pos.Line, tok.Pos.Column}; pos != tt.expectedPos {
+			t.Errorf("tests[%d] - pos wrong. expected=%v, got=%v",
+				i, tt.expectedPos, pos)
+		}
+
+		if end := [3]int{tok.End.Offset, tok.End.Line, tok.End.Column}; end != tt.expectedEnd {
+			t.Errorf("tests[%d] - end wrong. expected=%v, got=%v",
+				i, tt.expectedEnd, end)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing
+/* block /* nested */ still comment */ x / 2;
+/* unterminated`
+
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLiteral  string
+		expectedComments []string
+	}{
+		{token.LET, "let", []string{"// leading comment"}},
+		{token.IDENT, "x", nil},
+		{token.ASSIGN, "=", nil},
+		{token.INT, "5", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.IDENT, "x", []string{"// trailing", "/* block /* nested */ still comment */"}},
+		{token.SLASH, "/", nil},
+		{token.INT, "2", nil},
+		{token.SEMICOLON, ";", nil},
+		{token.EOF, "", []string{"/* unterminated"}},
+	}
+
+	l := New(input)
+	l.KeepComments()
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		var comments []string
+		for _, c := range tok.Comments {
+			comments = append(comments, c.Text)
+		}
+
+		if len(comments) != len(tt.expectedComments) {
+			t.Fatalf("tests[%d] - comments wrong. expected=%q, got=%q",
+				i, tt.expectedComments, comments)
+		}
+
+		for j := range comments {
+			if comments[j] != tt.expectedComments[j] {
+				t.Errorf("tests[%d] - comment %d wrong. expected=%q, got=%q",
+					i, j, tt.expectedComments[j], comments[j])
+			}
+		}
+	}
+
+	if len(l.Errors()) != 1 {
+		t.Fatalf("expected 1 lexer error. got=%d", len(l.Errors()))
+	}
+
+	if msg := l.Errors()[0].Error(); msg != "4:1: block comment not terminated" {
+		t.Errorf("wrong error. got=%q", msg)
+	}
+}
+
+func TestCommentsDiscardedByDefault(t *testing.T) {
+	l := New("// nothing to see\nx")
+
+	tok := l.NextToken()
+	if tok.Type != token.IDENT || tok.Comments != nil {
+		t.Errorf("expected bare IDENT token. got=%+v", tok)
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedValue string
+		expectedError string
+	}{
+		{`"plain"`, "plain", ""},
+		{`"a\nb\tc"`, "a\nb\tc", ""},
+		{`"quote \" and backslash \\"`, `quote " and backslash \`, ""},
+		{`"\x41\u00e9\U0001F600"`, "Aé\U0001F600", ""},
+		{`"é"`, "é", ""},
+		{"`raw \\n\nstring`", "raw \\n\nstring", ""},
+		{"`crlf\r\nline`", "crlf\nline", ""},
+		{`"bad \q escape"`, "bad  escape", "1:6: unknown escape sequence \\q"},
+		{`"\x4"`, "", "1:2: escape sequence \\x requires 2 hex digits"},
+		{`"\uD800"`, "", "1:2: escape sequence \\uD800 is not a valid Unicode code point"},
+		{`"unterminated`, "unterminated", "1:1: string literal not terminated"},
+		{"\"broken\nline\"", "broken", "1:1: string literal not terminated"},
+		{"`never closed", "never closed", "1:1: raw string literal not terminated"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING {
+			t.Errorf("%q - tokentype wrong. expected=%q, got=%q",
+				tt.input, token.STRING, tok.Type)
+			continue
+		}
+
+		if tok.Literal != tt.expectedValue {
+			t.Errorf("%q - value wrong. expected=%q, got=%q",
+				tt.input, tt.expectedValue, tok.Literal)
+		}
+
+		var errMsg string
+		if errs := l.Errors(); len(errs) > 0 {
+			errMsg = errs[0].Error()
+		}
+
+		if errMsg != tt.expectedError {
+			t.Errorf("%q - error wrong. expected=%q, got=%q",
+				tt.input, tt.expectedError, errMsg)
+		}
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	values := []string{"", "hello", "a\"b", "tab\there", "line\nbreak", "back\\slash", "é ✓", "\x00\x7f", "cost: ${price}", "$5"}
+
+	for _, v := range values {
+		l := New(token.Quote(v))
+		tok := l.NextToken()
+
+		if tok.Type != token.STRING || tok.Literal != v {
+			t.Errorf("token.Quote(%q) does not lex back. got=%s %q", v, tok.Type, tok.Literal)
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("token.Quote(%q) produced lexer errors: %v", v, l.Errors())
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := `"a ${x + 1} b ${ {"k": "}"}["k"] } c" "$5 \${x}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.STRING_MID, " b "},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.STRING, "}"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_TAIL, " c"},
+		{token.STRING, "$5 ${x}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected lexer errors: %v", l.Errors())
+	}
+}
+
+func TestUnicodeSource(t *testing.T) {
+	input := "let größe = \"😀 naïve\";\nπ2 → x1;"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedPos     string
+	}{
+		{token.LET, "let", "1:1"},
+		{token.IDENT, "größe", "1:5"},
+		{token.ASSIGN, "=", "1:11"},
+		{token.STRING, "😀 naïve", "1:13"},
+		{token.SEMICOLON, ";", "1:22"},
+		{token.IDENT, "π2", "2:1"},
+		{token.ILLEGAL, "→", "2:4"},
+		{token.IDENT, "x1", "2:6"},
+		{token.SEMICOLON, ";", "2:8"},
+		{token.EOF, "", "2:9"},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("tests[%d] - position wrong. expected=%s, got=%s",
+				i, tt.expectedPos, tok.Pos)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"42", token.INT, "42", ""},
+		{"3.14", token.FLOAT, "3.14", ""},
+		{".5", token.FLOAT, ".5", ""},
+		{"1e-9", token.FLOAT, "1e-9", ""},
+		{"2.5E+3", token.FLOAT, "2.5E+3", ""},
+		{"1.", token.INT, "1", ""},
+		{"1e", token.FLOAT, "1e", "1:1: exponent has no digits"},
+		{"0xFF", token.INT, "0xFF", ""},
+		{"0o755", token.INT, "0o755", ""},
+		{"0B1010", token.INT, "0B1010", ""},
+		{"1_000_000", token.INT, "1_000_000", ""},
+		{"0x_ff_ff", token.INT, "0x_ff_ff", ""},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1", ""},
+		{"0b102", token.INT, "0b102", "1:5: invalid digit '2' in binary literal"},
+		{"0o8", token.INT, "0o8", "1:3: invalid digit '8' in octal literal"},
+		{"0x", token.INT, "0x", "1:1: hexadecimal literal has no digits"},
+		{"1__000", token.INT, "1__000", "1:1: '_' must separate successive digits"},
+		{"100_", token.INT, "100_", "1:1: '_' must separate successive digits"},
+		{"0x_", token.INT, "0x_", "1:1: hexadecimal literal has no digits"},
+		{"1_.5", token.FLOAT, "1_.5", "1:1: '_' must separate successive digits"},
+		{"1..5", token.INT, "1", ""},
+		{"0", token.INT, "0", ""},
+		{"010", token.INT, "010", "1:1: leading zero in decimal literal 010; use 0o for octal"},
+		{"0_1", token.INT, "0_1", "1:1: leading zero in decimal literal 0_1; use 0o for octal"},
+		{"0.5", token.FLOAT, "0.5", ""},
+		{"00.5", token.FLOAT, "00.5", ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q - tokentype wrong. expected=%q, got=%q",
+				tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%q - literal wrong. expected=%q, got=%q",
+				tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		var errMsg string
+		if errs := l.Errors(); len(errs) > 0 {
+			errMsg = errs[0].Error()
+		}
+
+		if errMsg != tt.expectedError {
+			t.Errorf("%q - error wrong. expected=%q, got=%q",
+				tt.input, tt.expectedError, errMsg)
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	input := `<= >= < > % && || & | ^ ~ << >> == != ! = += -= *= /= ->`
+
+	expected := []token.TokenType{
+		token.LT_EQ, token.GT_EQ, token.LT, token.GT, token.PERCENT,
+		token.AND, token.OR, token.AMPERSAND, token.PIPE, token.CARET,
+		token.TILDE, token.SHL, token.SHR, token.EQ, token.NOT_EQ, token.BANG,
+		token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN,
+		token.SLASH_ASSIGN, token.ARROW, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+
+		if tok.Type != token.EOF && tok.Literal != string(tt) {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt, tok.Literal)
+		}
+	}
+}
+
+func TestRangeOperators(t *testing.T) {
+	input := `0..n 1..<10 a[1:2] x.. .5 f(...xs)`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "0"},
+		{token.RANGE, ".."},
+		{token.IDENT, "n"},
+		{token.INT, "1"},
+		{token.RANGE_EXCLUSIVE, "..<"},
+		{token.INT, "10"},
+		{token.IDENT, "a"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COLON, ":"},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.IDENT, "x"},
+		{token.RANGE, ".."},
+		{token.FLOAT, ".5"},
+		{token.IDENT, "f"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "xs"},
+		{token.RPAREN, ")"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
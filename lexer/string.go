@@ -0,0 +1,169 @@
+package lexer
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/token"
+)
+
+// readStringPart reads a double quoted string literal, or the part of one
+// up to the next `${` interpolation, and returns its value with escape
+// sequences decoded. resumed is true when the part starts at the `}` closing
+// an interpolation. Interpreted strings may not span lines.
+func (l *Lexer) readStringPart(resumed bool) (string, token.TokenType) {
+	start := l.pos()
+
+	var out strings.Builder
+
+	// skip the opening `"` or `}`
+	l.readChar()
+
+	for {
+		switch l.char {
+		case '"':
+			l.readChar()
+			if resumed {
+				return out.String(), token.STRING_TAIL
+			}
+			return out.String(), token.STRING
+		case '$':
+			if l.peekChar() != '{' {
+				out.WriteString(l.current())
+				l.readChar()
+				continue
+			}
+			l.readChar()
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			if resumed {
+				return out.String(), token.STRING_MID
+			}
+			return out.String(), token.STRING_HEAD
+		case 0, '\n':
+			l.errorf(start, l.pos(), codeUnterminatedString, "string literal not terminated")
+			if resumed {
+				return out.String(), token.STRING_TAIL
+			}
+			return out.String(), token.STRING
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteString(l.current())
+			l.readChar()
+		}
+	}
+}
+
+// readRawString reads a backtick delimited string. Raw strings have no
+// escape sequences and may span multiple lines.
+func (l *Lexer) readRawString() string {
+	start := l.pos()
+
+	var out strings.Builder
+
+	l.readChar()
+
+	for l.char != '`' {
+		if l.char == 0 {
+			l.errorf(start, l.pos(), codeUnterminatedString, "raw string literal not terminated")
+			return out.String()
+		}
+
+		// carriage returns are dropped so files with CRLF endings produce the
+		// same value
+		if l.char != '\r' {
+			out.WriteString(l.current())
+		}
+
+		l.readChar()
+	}
+
+	l.readChar()
+
+	return out.String()
+}
+
+var simpleEscapes = map[rune]byte{
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+}
+
+// readEscape decodes the escape sequence starting at the current backslash
+// and writes its value to out.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.pos()
+
+	l.readChar()
+
+	if ch, ok := simpleEscapes[l.char]; ok {
+		out.WriteByte(ch)
+		l.readChar()
+		return
+	}
+
+	var digits int
+	switch l.char {
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	case 0, '\n':
+		// reported as an unterminated string by the caller
+		return
+	default:
+		l.readChar()
+		l.errorf(start, l.pos(), codeInvalidEscape, "unknown escape sequence %s",
+			l.input[start.Offset:l.position])
+		return
+	}
+
+	kind := l.char
+	l.readChar()
+
+	hexStart := l.position
+	for i := 0; i < digits; i++ {
+		if !isHexDigit(l.char) {
+			l.errorf(start, l.pos(), codeInvalidEscape, "escape sequence \\%c requires %d hex digits",
+				kind, digits)
+			return
+		}
+		l.readChar()
+	}
+
+	value, _ := strconv.ParseUint(l.input[hexStart:l.position], 16, 32)
+
+	if kind == 'x' {
+		out.WriteByte(byte(value))
+		return
+	}
+
+	r := rune(value)
+	if !utf8.ValidRune(r) {
+		l.errorf(start, l.pos(), codeInvalidEscape, "escape sequence %s is not a valid Unicode code point",
+			l.input[start.Offset:l.position])
+		return
+	}
+
+	out.WriteRune(r)
+}
+
+func (l *Lexer) errorf(start, end token.Position, code string, format string, a ...interface{}) {
+	l.errors = append(l.errors, diag.Errorf(diag.Span{Start: start, End: end}, code, format, a...))
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
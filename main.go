@@ -2,61 +2,39 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/user"
-	"strings"
+	"path/filepath"
+	"sort"
 
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/compiler"
+	"github.com/threeaccents/digolang/diag"
 	"github.com/threeaccents/digolang/eval"
 	"github.com/threeaccents/digolang/lexer"
 	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/optimizer"
 	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
+	"github.com/threeaccents/digolang/types"
+	"github.com/threeaccents/digolang/vm"
 
 	"github.com/threeaccents/digolang/repl"
 )
 
 func main() {
-	if len(os.Args) == 3 {
-		shouldRun := os.Args[1] == "run"
-		if shouldRun {
-			fileName := os.Args[2]
-			if !isDigoFile(fileName) {
-				fmt.Println("invalid file. File must be of type .digo")
-				os.Exit(1)
-			}
-
-			f, err := os.Open(fileName)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-
-			b := new(bytes.Buffer)
-
-			if _, err := io.Copy(b, f); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-
-			fmt.Println("tokenizing file...")
-			l := lexer.New(b.String())
-			fmt.Println("parsing tokens...")
-			p := parser.New(l)
-			program := p.ParseProgram()
-			if len(p.Errors()) != 0 {
-				printParserErrors(os.Stderr, p.Errors())
-				os.Exit(1)
-			}
-
-			fmt.Println("evaluating program...")
-
-			evaluated := eval.Eval(program, object.NewEnvironment())
-			if evaluated != nil {
-				io.WriteString(os.Stdout, evaluated.Inspect())
-				io.WriteString(os.Stdout, "\n")
-			}
-
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "run":
+			run(os.Args[2:])
+			return
+		case "check":
+			check(os.Args[2:])
 			return
 		}
 	}
@@ -70,18 +48,171 @@ func main() {
 }
 
 func isDigoFile(name string) bool {
-	nameSlice := strings.Split(name, ".")
-	if len(nameSlice) == 1 {
-		return false
+	return filepath.Ext(name) == ".digo"
+}
+
+func run(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "report diagnostics as JSON")
+	maxDepth := fs.Int("max-depth", eval.DefaultMaxDepth, "maximum depth of nested function calls")
+	timeout := fs.Duration("timeout", 0, "stop the program after this long (0 for no limit)")
+	maxSteps := fs.Int64("max-steps", 0, "maximum number of evaluation steps (0 for no limit)")
+	maxAlloc := fs.Int64("max-alloc", 0, "maximum total size of created strings, arrays and hashes (0 for no limit)")
+	useEval := fs.Bool("eval", false, "run the program with the tree-walking evaluator instead of the bytecode VM")
+	optimize := fs.Bool("O", false, "fold constants and prune dead branches before running the program")
+	dumpOptimized := fs.Bool("dump-optimized", false, "print the optimized program instead of running it")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("usage: digo run [flags] <file.digo>")
+		os.Exit(1)
+	}
+
+	fileName := fs.Arg(0)
+	b := readSource(fileName)
+
+	// the dump is the only output, so that it can be saved and run
+	progress := io.Writer(os.Stdout)
+	if *dumpOptimized {
+		progress = ioutil.Discard
+	}
+
+	fmt.Fprintln(progress, "tokenizing file...")
+	l := lexer.NewFile(fileName, b.String())
+	fmt.Fprintln(progress, "parsing tokens...")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printer := diag.NewPrinter(os.Stderr)
+		printer.JSON = *jsonOut
+		printer.AddSource(fileName, b.String())
+		printer.Print(p.Errors())
+		os.Exit(1)
+	}
+
+	if warnings := resolver.Resolve(program); len(warnings) != 0 {
+		printer := diag.NewPrinter(os.Stderr)
+		printer.JSON = *jsonOut
+		printer.AddSource(fileName, b.String())
+		printer.Print(warnings)
+	}
+
+	if *optimize || *dumpOptimized {
+		optimizer.Optimize(program)
+		if *dumpOptimized {
+			fmt.Print(ast.Format(program))
+			return
+		}
+		// bind the variables of the rewritten program
+		resolver.Resolve(program)
+	}
+
+	fmt.Println("evaluating program...")
+
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
-	return nameSlice[1] == "digo"
+	loader := eval.NewLoader(eval.DefaultSearchPath()...)
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	limits := eval.Limits{MaxSteps: *maxSteps, MaxAlloc: *maxAlloc, MaxDepth: *maxDepth}
+	env := object.NewModuleEnvironment(path, loader)
+
+	var evaluated object.Object
+	if *useEval {
+		evaluated, err = eval.EvalContext(ctx, program, env, limits)
+	} else {
+		evaluated, err = runVM(ctx, program, env, limits)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err, ok := evaluated.(*object.Error); ok && !err.Handled {
+		io.WriteString(os.Stderr, err.Traceback())
+		os.Exit(1)
+	}
+	if evaluated != nil {
+		io.WriteString(os.Stdout, evaluated.Inspect())
+		io.WriteString(os.Stdout, "\n")
+	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "Woops! error executing program.\n")
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// check reports the problems found in a program without running it: syntax
+// errors, or else type errors along with the warnings of the resolver.
+func check(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	jsonOut := fs.Bool("json", false, "report diagnostics as JSON")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Println("usage: digo check [flags] <file.digo>")
+		os.Exit(1)
+	}
+
+	fileName := fs.Arg(0)
+	b := readSource(fileName)
+
+	p := parser.New(lexer.NewFile(fileName, b.String()))
+	program := p.ParseProgram()
+
+	diags := p.Errors()
+	if len(diags) == 0 {
+		diags = append(resolver.Resolve(program), types.Check(program)...)
+		sort.SliceStable(diags, func(i, j int) bool {
+			return diags[i].Span.Start.Offset < diags[j].Span.Start.Offset
+		})
 	}
+
+	if len(diags) != 0 {
+		printer := diag.NewPrinter(os.Stderr)
+		printer.JSON = *jsonOut
+		printer.AddSource(fileName, b.String())
+		printer.Print(diags)
+	}
+
+	if diag.HasErrors(diags) {
+		os.Exit(1)
+	}
+}
+
+// readSource reads the .digo file called fileName, exiting if it cannot.
+func readSource(fileName string) *bytes.Buffer {
+	if !isDigoFile(fileName) {
+		fmt.Println("invalid file. File must be of type .digo")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	b := new(bytes.Buffer)
+
+	if _, err := io.Copy(b, f); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return b
+}
+
+func runVM(ctx context.Context, program *ast.Program, env *object.Environment, limits eval.Limits) (object.Object, error) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	return vm.New(comp.Bytecode(), env).RunContext(ctx, limits)
 }
@@ -15,7 +15,7 @@ func (ao *Array) Inspect() string {
 
 	var elements []string
 	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspectElement(e))
 	}
 
 	out.WriteString("[")
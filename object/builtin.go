@@ -1,6 +1,9 @@
 package object
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction implements a builtin function. state belongs to the
+// evaluation making the call; the values the function creates count
+// against its limits.
+type BuiltinFunction func(state *State, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
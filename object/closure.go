@@ -0,0 +1,146 @@
+package object
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/code"
+	"github.com/threeaccents/digolang/token"
+)
+
+const (
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
+)
+
+// CompiledFunction is a function literal compiled to bytecode. It is a
+// constant of the program; evaluating the literal creates a Closure of it.
+type CompiledFunction struct {
+	Instructions code.Instructions
+	// NumLocals is the number of local slots of a call, starting with one
+	// per parameter and one for the rest parameter, if any.
+	NumLocals     int
+	NumParameters int
+	Rest          bool
+	// Entries holds the offset a call with i positional arguments and no
+	// keyword arguments starts running at, skipping the defaults of the
+	// parameters that were passed an argument.
+	Entries []int
+	// Cells lists the local slots captured by closures. A call stores a new
+	// cell in each before the function starts running.
+	Cells []int
+	// Captures describes where a closure of the function takes each of its
+	// free variables from in the frame creating it.
+	Captures []Capture
+	// Chains holds the candidates of each name resolved at runtime.
+	Chains [][]Binding
+
+	// Names of the parameters, locals and free variables, for keyword
+	// arguments and error messages.
+	Parameters []string
+	Locals     []string
+	Free       []string
+
+	Name  string
+	Arity string
+	// Positions maps instruction offsets to the source positions errors
+	// raised by them are reported at.
+	Positions []Position
+
+	// Literal is the function literal the function was compiled from, used
+	// to display it.
+	Literal *ast.FunctionLiteral
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	if cf.Literal == nil {
+		return "<program>"
+	}
+	return inspectLiteral(cf.Literal)
+}
+
+// Pos returns the position of the instruction at offset ip.
+func (cf *CompiledFunction) Pos(ip int) token.Position {
+	i := sort.Search(len(cf.Positions), func(i int) bool {
+		return cf.Positions[i].Offset > ip
+	})
+	if i == 0 {
+		return token.Position{}
+	}
+	return cf.Positions[i-1].Pos
+}
+
+// Position records that the instructions from Offset on belong to the
+// expression at Pos.
+type Position struct {
+	Offset int
+	Pos    token.Position
+}
+
+// Capture is a free variable of a closure: a captured local of the function
+// creating the closure, or one of its own free variables.
+type Capture struct {
+	Local bool
+	Index int
+}
+
+// Binding is a candidate place a name may be bound in.
+type Binding struct {
+	Scope BindingScope
+	Index int
+}
+
+type BindingScope int
+
+const (
+	GlobalBinding BindingScope = iota
+	LocalBinding
+	CellBinding
+	FreeBinding
+	// BuiltinBinding is the builtin in the constant at Index, which the
+	// variables before it in a chain shadow.
+	BuiltinBinding
+)
+
+// Closure is a compiled function together with the variables it captured.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+	// Runtime runs the closure when it is called from outside compiled
+	// code, such as by a builtin method.
+	Runtime Runtime
+}
+
+// Closures are functions as far as scripts can tell.
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return c.Fn.Inspect() }
+
+// Runtime runs closures on behalf of code that only holds the closure.
+type Runtime interface {
+	CallClosure(cl *Closure, args []Object, kwNames []string, kwValues []Object) Object
+}
+
+// Cell holds a variable captured by a closure, shared by the function that
+// declared it and every closure capturing it. A nil Value means the
+// variable is not bound yet.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+func (c *Cell) Inspect() string  { return "cell" }
+
+func inspectLiteral(fl *ast.FunctionLiteral) string {
+	var out bytes.Buffer
+
+	out.WriteString("fn")
+	out.WriteString("(")
+	out.WriteString(ast.FormatParameters(fl.Parameters, fl.Defaults, fl.Rest))
+	out.WriteString(") {\n")
+	out.WriteString(fl.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
@@ -1,9 +1,43 @@
 package object
 
+import "context"
+
 type Environment struct {
 	store map[string]Object
 
+	// slots hold the variables the resolver assigned an index to, named by
+	// names. A nil slot is a variable whose let statement has not run yet.
+	slots []Object
+	names []string
+
 	outer *Environment
+
+	// file and importer are set on the top level environment of a module.
+	file     string
+	importer Importer
+
+	state *State
+}
+
+// State is the bookkeeping of one evaluation. It is shared by a top level
+// environment, every environment nested inside it and the environments of
+// the modules it imports.
+type State struct {
+	// Depth is the number of function calls in progress and MaxDepth the
+	// number allowed, with zero meaning the evaluator's default.
+	Depth    int
+	MaxDepth int
+
+	// Context, if set, stops the evaluation once it is done.
+	Context context.Context
+	// Steps counts the nodes evaluated so far and Allocated the total size of
+	// the strings, arrays and hashes created. A zero maximum means no limit.
+	Steps     int64
+	MaxSteps  int64
+	Allocated int64
+	MaxAlloc  int64
+	// Abort is the reason the evaluation was stopped, once it was.
+	Abort error
 }
 
 func NewEnvironment() *Environment {
@@ -12,26 +46,159 @@ func NewEnvironment() *Environment {
 	return &Environment{
 		store: s,
 		outer: nil,
+		state: &State{},
 	}
 }
 
+// NewModuleEnvironment returns the top level environment for the source file
+// at path. Import statements evaluated in it, or in any environment nested
+// inside it, resolve modules through importer.
+func NewModuleEnvironment(path string, importer Importer) *Environment {
+	env := NewEnvironment()
+
+	env.file = path
+	env.importer = importer
+
+	return env
+}
+
 func NewInnerEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 
 	env.outer = outer
+	env.state = outer.state
 
 	return env
 }
 
+// NewScopeEnvironment returns an environment nested inside outer whose
+// variables called names are kept in slots.
+func NewScopeEnvironment(outer *Environment, names []string) *Environment {
+	return &Environment{
+		slots: make([]Object, len(names)),
+		names: names,
+		outer: outer,
+		state: outer.state,
+	}
+}
+
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.store[name]; ok {
+			return obj, true
+		}
+		if i := env.slot(name); i >= 0 && env.slots[i] != nil {
+			return env.slots[i], true
+		}
 	}
-	return obj, ok
+
+	return nil, false
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if i := e.slot(name); i >= 0 {
+		e.slots[i] = val
+		return val
+	}
+
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Assign updates an existing binding in the innermost scope that declares
+// name. It reports false if name is not declared in any enclosing scope.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+		if i := env.slot(name); i >= 0 && env.slots[i] != nil {
+			env.slots[i] = val
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetSlot returns the variable in slot of the environment depth levels out
+// from e, or nil if it is not bound.
+func (e *Environment) GetSlot(depth, slot int) Object {
+	env := e.ancestor(depth)
+	if env == nil || slot >= len(env.slots) {
+		return nil
+	}
+	return env.slots[slot]
+}
+
+// SetSlot binds the variable in slot of e.
+func (e *Environment) SetSlot(slot int, val Object) {
+	e.slots[slot] = val
+}
+
+// AssignSlot updates the variable in slot of the environment depth levels
+// out from e. It reports false if the variable is not bound.
+func (e *Environment) AssignSlot(depth, slot int, val Object) bool {
+	env := e.ancestor(depth)
+	if env == nil || slot >= len(env.slots) || env.slots[slot] == nil {
+		return false
+	}
+	env.slots[slot] = val
+	return true
+}
+
+func (e *Environment) ancestor(depth int) *Environment {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+	return env
+}
+
+// slot returns the index of the slot of e called name, or -1.
+func (e *Environment) slot(name string) int {
+	for i, n := range e.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// File returns the path of the module the environment belongs to, or "" if
+// it does not belong to a file.
+func (e *Environment) File() string {
+	for env := e; env != nil; env = env.outer {
+		if env.file != "" {
+			return env.file
+		}
+	}
+
+	return ""
+}
+
+// Importer returns the importer of the module the environment belongs to, or
+// nil if imports are not available.
+func (e *Environment) Importer() Importer {
+	for env := e; env != nil; env = env.outer {
+		if env.importer != nil {
+			return env.importer
+		}
+	}
+
+	return nil
+}
+
+// State returns the state of the evaluation the environment belongs to.
+func (e *Environment) State() *State {
+	return e.state
+}
+
+// ShareState makes e part of the evaluation from belongs to.
+func (e *Environment) ShareState(from *Environment) {
+	e.state = from.state
+}
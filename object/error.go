@@ -1,7 +1,31 @@
 package object
 
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/threeaccents/digolang/token"
+)
+
+// Error is a runtime error. While it is unhandled it unwinds evaluation up
+// to the nearest enclosing try expression; once a catch clause intercepted
+// it, it is an ordinary value.
 type Error struct {
 	Message string
+	// Kind classifies the error, e.g. RuntimeError for errors raised by the
+	// interpreter itself.
+	Kind string
+	// Pos is the position of the expression that raised the error.
+	Pos token.Position
+	// Stack lists the calls the error unwound through, innermost first.
+	Stack []Frame
+	// Value is the value passed to throw when it was not an error.
+	Value Object
+
+	Handled bool
+	// Fatal is set for errors that stop the evaluation regardless of any try
+	// expressions, such as exceeding a limit.
+	Fatal bool
 }
 
 func (e *Error) Type() ObjectType {
@@ -11,3 +35,60 @@ func (e *Error) Type() ObjectType {
 func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
+
+// Traceback formats the error the way it is reported when no catch clause
+// handles it: the chain of calls leading to it, most recent call last,
+// followed by its kind and message.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+
+	// the call recorded in each frame was made from inside the function of
+	// the frame after it
+	var lines []string
+	caller := "<program>"
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		// calls made by builtin methods have no position of their own
+		if e.Stack[i].Pos.IsValid() {
+			lines = append(lines, "  "+e.Stack[i].Pos.String()+", in "+caller+"\n")
+		}
+		caller = e.Stack[i].Function
+	}
+	if e.Pos.IsValid() {
+		lines = append(lines, "  "+e.Pos.String()+", in "+caller+"\n")
+	}
+
+	// deep recursion repeats the same line many times over
+	for i := 0; i < len(lines); {
+		n := 1
+		for i+n < len(lines) && lines[i+n] == lines[i] {
+			n++
+		}
+
+		out.WriteString(lines[i])
+		if n > 1 {
+			fmt.Fprintf(&out, "  [previous line repeated %d more times]\n", n-1)
+		}
+		i += n
+	}
+
+	kind := e.Kind
+	if kind == "" {
+		kind = "Error"
+	}
+	out.WriteString(kind + ": " + e.Message + "\n")
+
+	return out.String()
+}
+
+// Frame is a function call that was active when an error was raised.
+type Frame struct {
+	Function string
+	// Pos is the position of the call.
+	Pos token.Position
+}
+
+func (f Frame) String() string {
+	return f.Function + " at " + f.Pos.String()
+}
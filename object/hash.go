@@ -22,6 +22,35 @@ type HashPair struct {
 
 type Hash struct {
 	Pairs map[HashKey]HashPair
+
+	// order holds the keys of Pairs in insertion order.
+	order []HashKey
+}
+
+// Set adds or replaces the pair stored under key. New keys are ordered after
+// the existing ones.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if h.Pairs == nil {
+		h.Pairs = make(map[HashKey]HashPair)
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.order = append(h.order, key)
+	}
+
+	h.Pairs[key] = pair
+}
+
+// Ordered returns the pairs of the hash in insertion order.
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+
+	for _, key := range h.order {
+		if pair, ok := h.Pairs[key]; ok {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
@@ -29,9 +58,9 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Ordered() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(), pair.Value.Inspect()))
+			inspectElement(pair.Key), inspectElement(pair.Value)))
 	}
 
 	out.WriteString("{")
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +61,26 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	half1 := &Float{Value: 0.5}
+	half2 := &Float{Value: 0.5}
+	one := &Float{Value: 1}
+	negZero := &Float{Value: math.Copysign(0, -1)}
+
+	if half1.HashKey() != half2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if half1.HashKey() == one.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+
+	if one.HashKey() != (&Integer{Value: 1}).HashKey() {
+		t.Errorf("1.0 does not have the same hash key as 1")
+	}
+
+	if negZero.HashKey() != (&Integer{Value: 0}).HashKey() {
+		t.Errorf("-0.0 does not have the same hash key as 0")
+	}
+}
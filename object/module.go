@@ -0,0 +1,19 @@
+package object
+
+// Module is an evaluated source file. Only the names it exports are visible
+// to importers.
+type Module struct {
+	Path    string
+	Exports map[string]Object
+	// Names lists the exports in the order they were declared.
+	Names []string
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module " + m.Path }
+
+// Importer loads modules for import statements. from is the environment
+// the statement is evaluated in.
+type Importer interface {
+	Import(from *Environment, path string) (*Module, error)
+}
@@ -4,19 +4,33 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
+	RANGE_OBJ        = "RANGE"
 	SELECTOR_OBJ     = "SELECTOR"
 	HASH_OBJ         = "HASH"
+	MODULE_OBJ       = "MODULE"
 )
 
 type Object interface {
 	Type() ObjectType
 	Inspect() string
 }
+
+// inspectElement returns the representation of obj when it is nested inside
+// an array or hash.
+func inspectElement(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return s.Quoted()
+	}
+	return obj.Inspect()
+}
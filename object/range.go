@@ -0,0 +1,77 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is the lazy sequence of integers produced by `start..end` (which
+// includes end) and `start..<end` (which does not).
+type Range struct {
+	Start     int64
+	End       int64
+	Inclusive bool
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Inclusive {
+		return fmt.Sprintf("%d..%d", r.Start, r.End)
+	}
+	return fmt.Sprintf("%d..<%d", r.Start, r.End)
+}
+
+// Len returns the number of integers in the range, or math.MaxInt64 if
+// there are more.
+func (r *Range) Len() int64 {
+	if r.empty() {
+		return 0
+	}
+
+	// the distance between the ends is computed in uint64, where it fits
+	n := uint64(r.last()) - uint64(r.Start) + 1
+	if n == 0 || n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// At returns the i-th integer of the range. i must be in [0, Len()).
+func (r *Range) At(i int64) int64 {
+	return r.Start + i
+}
+
+// Index returns the integer at index i of the range, where a negative i
+// counts back from the end, and reports whether the range has one. Unlike
+// Len it works for ranges of any size.
+func (r *Range) Index(i int64) (int64, bool) {
+	if r.empty() {
+		return 0, false
+	}
+
+	span := uint64(r.last()) - uint64(r.Start)
+	if i >= 0 {
+		if uint64(i) > span {
+			return 0, false
+		}
+		return r.Start + i, true
+	}
+
+	back := uint64(-(i + 1))
+	if back > span {
+		return 0, false
+	}
+	return r.last() - int64(back), true
+}
+
+func (r *Range) empty() bool {
+	return r.End < r.Start || r.End == r.Start && !r.Inclusive
+}
+
+// last returns the last integer of a range that is not empty.
+func (r *Range) last() int64 {
+	if r.Inclusive {
+		return r.End
+	}
+	return r.End - 1
+}
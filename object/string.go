@@ -1,6 +1,10 @@
 package object
 
-import "hash/fnv"
+import (
+	"hash/fnv"
+
+	"github.com/threeaccents/digolang/token"
+)
 
 type String struct {
 	Value string
@@ -20,6 +24,12 @@ func (s *String) Inspect() string {
 	return s.Value
 }
 
+// Quoted returns the value as a string literal, which is how strings are
+// shown when nested inside other values.
+func (s *String) Quoted() string {
+	return token.Quote(s.Value)
+}
+
 func (s *String) Type() ObjectType {
 	return STRING_OBJ
 }
@@ -0,0 +1,408 @@
+// Package optimizer simplifies programs before they run without changing
+// what they do: it folds operators applied to literals, drops the branches
+// of if expressions a literal condition rules out and replaces the uses of
+// variables bound once to a literal by the literal.
+package optimizer
+
+import (
+	"math"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/token"
+)
+
+// maxFoldedString is the length of the longest string folding creates, so
+// that a program does not grow much larger than its source.
+const maxFoldedString = 1024
+
+// scope mirrors an environment of the evaluator: the top level of the
+// program, a function, a loop body or a catch clause.
+type scope struct {
+	outer *scope
+
+	// constants holds the literals of the variables bound once and never
+	// assigned to, from their let statement on.
+	constants map[string]ast.Expression
+	// declared counts the declarations of each name in the scope.
+	declared map[string]int
+	// assigned holds the names assignments in or below the scope assign to.
+	assigned map[string]bool
+}
+
+type optimizer struct {
+	scope *scope
+}
+
+// Optimize rewrites program in place and returns it.
+func Optimize(program *ast.Program) *ast.Program {
+	o := &optimizer{}
+
+	o.enterScope(program.Statements)
+	program.Statements = o.statements(program.Statements, true, true)
+	o.leaveScope()
+
+	return program
+}
+
+// enterScope begins a scope declaring the variables stmts declare and
+// names, the parameters of a function or the variable of a loop or catch
+// clause.
+func (o *optimizer) enterScope(stmts []ast.Statement, names ...*ast.Identifier) {
+	s := &scope{
+		outer:     o.scope,
+		constants: map[string]ast.Expression{},
+		declared:  map[string]int{},
+		assigned:  map[string]bool{},
+	}
+
+	for _, name := range names {
+		if name != nil {
+			s.declared[name.Value]++
+		}
+	}
+	for _, name := range ast.Declarations(stmts) {
+		s.declared[name]++
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			if assign, ok := n.(*ast.AssignStatement); ok {
+				if ident, ok := assign.Target.(*ast.Identifier); ok {
+					s.assigned[ident.Value] = true
+				}
+			}
+			return true
+		})
+	}
+
+	o.scope = s
+}
+
+func (o *optimizer) leaveScope() {
+	o.scope = o.scope.outer
+}
+
+// constant returns the literal the variable called name is bound to, or nil
+// if it may not be bound to one where it is used.
+func (o *optimizer) constant(name string) ast.Expression {
+	for s := o.scope; s != nil; s = s.outer {
+		if lit, ok := s.constants[name]; ok {
+			return lit
+		}
+		if s.declared[name] > 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+// statements optimizes a list of statements. direct is set when they are
+// those of the block a scope begins with, which run unconditionally, and
+// program when they are the top level of the program.
+func (o *optimizer) statements(stmts []ast.Statement, direct, program bool) []ast.Statement {
+	var out []ast.Statement
+
+	for i, stmt := range stmts {
+		last := i == len(stmts)-1
+
+		switch s := stmt.(type) {
+		case *ast.ExpressionStatement:
+			s.Expression = o.expression(s.Expression)
+			out = append(out, o.prune(s, last, program)...)
+		case *ast.LetStatement:
+			o.let(s, direct)
+			out = append(out, s)
+		case *ast.ExportStatement:
+			o.let(s.Statement, direct)
+			out = append(out, s)
+		default:
+			o.statement(stmt)
+			out = append(out, stmt)
+		}
+	}
+
+	return out
+}
+
+// let optimizes a let statement, and records the variable it binds if it
+// is a constant.
+func (o *optimizer) let(s *ast.LetStatement, direct bool) {
+	if s == nil || s.Expression == nil {
+		return
+	}
+
+	s.Expression = o.expression(s.Expression)
+
+	name := s.Name.Value
+	if direct && isLiteral(s.Expression) && o.scope.declared[name] == 1 && !o.scope.assigned[name] {
+		o.scope.constants[name] = s.Expression
+	}
+}
+
+func (o *optimizer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.ReturnStatement:
+		s.ReturnValue = o.expression(s.ReturnValue)
+	case *ast.ThrowStatement:
+		s.Value = o.expression(s.Value)
+	case *ast.AssignStatement:
+		s.Value = o.expression(s.Value)
+		switch target := s.Target.(type) {
+		case *ast.IndexExpression:
+			target.Left = o.expression(target.Left)
+			target.Index = o.expression(target.Index)
+		case *ast.SelectorExpression:
+			target.Left = o.expression(target.Left)
+		}
+	case *ast.WhileStatement:
+		s.Condition = o.expression(s.Condition)
+		o.loopBody(s.Body)
+	case *ast.ForStatement:
+		s.Iterable = o.expression(s.Iterable)
+		o.loopBody(s.Body, s.Variable)
+	}
+}
+
+func (o *optimizer) loopBody(body *ast.BlockStatement, names ...*ast.Identifier) {
+	if body == nil {
+		return
+	}
+
+	o.enterScope(body.Statements, names...)
+	body.Statements = o.statements(body.Statements, true, false)
+	o.leaveScope()
+}
+
+// prune replaces an if expression statement whose condition is a literal
+// by the statements of the branch that runs. The statements of a block stop
+// at the first error, caught or not, while those of a program only stop at
+// errors nothing caught, so the branch is only spliced into blocks.
+func (o *optimizer) prune(s *ast.ExpressionStatement, last, program bool) []ast.Statement {
+	ie, ok := s.Expression.(*ast.IfExpression)
+	if !ok {
+		return []ast.Statement{s}
+	}
+
+	cond, ok := ie.Condition.(*ast.BooleanLiteral)
+	if !ok || !cond.Value {
+		if ok && ie.Alternative == nil && !last {
+			// the expression does nothing and its value is dropped
+			return nil
+		}
+		return []ast.Statement{s}
+	}
+
+	if program || ie.Consequence == nil || len(ie.Consequence.Statements) == 0 && last {
+		return []ast.Statement{s}
+	}
+
+	return ie.Consequence.Statements
+}
+
+func (o *optimizer) block(b *ast.BlockStatement) {
+	if b != nil {
+		b.Statements = o.statements(b.Statements, false, false)
+	}
+}
+
+func (o *optimizer) expression(expr ast.Expression) ast.Expression {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		if e == nil {
+			return e
+		}
+		if lit := o.constant(e.Value); lit != nil {
+			return relocate(lit, e)
+		}
+	case *ast.PrefixExpression:
+		e.Right = o.expression(e.Right)
+		if right := value(e.Right); right != nil {
+			if lit := literal(e, eval.Prefix(e.Operator, right)); lit != nil {
+				return lit
+			}
+		}
+	case *ast.InfixExpression:
+		e.Left = o.expression(e.Left)
+		e.Right = o.expression(e.Right)
+		left, right := value(e.Left), value(e.Right)
+		if left != nil && right != nil {
+			if lit := literal(e, eval.Infix(e.Operator, left, right)); lit != nil {
+				return lit
+			}
+		}
+	case *ast.IfExpression:
+		return o.ifExpression(e)
+	case *ast.TryExpression:
+		o.block(e.Block)
+		if e.Catch != nil {
+			o.enterScope(e.Catch.Statements, e.Param)
+			e.Catch.Statements = o.statements(e.Catch.Statements, true, false)
+			o.leaveScope()
+		}
+		o.block(e.Finally)
+	case *ast.FunctionLiteral:
+		o.function(e)
+	case *ast.CallExpression:
+		e.Function = o.expression(e.Function)
+		o.expressions(e.Arguments)
+	case *ast.ArrayLiteral:
+		o.expressions(e.Elements)
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(e.Pairs))
+		for i, key := range e.Keys {
+			val := e.Pairs[key]
+			e.Keys[i] = o.expression(key)
+			pairs[e.Keys[i]] = o.expression(val)
+		}
+		e.Pairs = pairs
+	case *ast.IndexExpression:
+		e.Left = o.expression(e.Left)
+		e.Index = o.expression(e.Index)
+	case *ast.SliceExpression:
+		e.Left = o.expression(e.Left)
+		if e.Low != nil {
+			e.Low = o.expression(e.Low)
+		}
+		if e.High != nil {
+			e.High = o.expression(e.High)
+		}
+	case *ast.SelectorExpression:
+		e.Left = o.expression(e.Left)
+	case *ast.SpreadExpression:
+		e.Value = o.expression(e.Value)
+	case *ast.KeywordArgument:
+		e.Value = o.expression(e.Value)
+	case *ast.InterpolatedString:
+		o.expressions(e.Expressions)
+	}
+
+	return expr
+}
+
+func (o *optimizer) expressions(exprs []ast.Expression) {
+	for i, e := range exprs {
+		exprs[i] = o.expression(e)
+	}
+}
+
+// ifExpression optimizes an if expression. When its condition is a literal
+// the branch that does not run is dropped, and a branch holding a single
+// expression replaces the if expression.
+func (o *optimizer) ifExpression(e *ast.IfExpression) ast.Expression {
+	e.Condition = o.expression(e.Condition)
+
+	cond, ok := e.Condition.(*ast.BooleanLiteral)
+	if !ok {
+		o.block(e.Consequence)
+		o.block(e.Alternative)
+		return e
+	}
+
+	if !cond.Value {
+		if e.Alternative == nil {
+			return e
+		}
+		e.Condition = &ast.BooleanLiteral{
+			Token: token.Token{Type: token.TRUE, Literal: "true", Pos: cond.Token.Pos, End: cond.Token.End},
+			Value: true,
+		}
+		e.Consequence = e.Alternative
+	}
+	e.Alternative = nil
+
+	o.block(e.Consequence)
+
+	if b := e.Consequence; b != nil && len(b.Statements) == 1 {
+		if es, ok := b.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+			return es.Expression
+		}
+	}
+
+	return e
+}
+
+// function optimizes a function literal. Defaults are evaluated in the
+// scope of the call, where the parameters are declared.
+func (o *optimizer) function(fn *ast.FunctionLiteral) {
+	if fn.Body == nil {
+		return
+	}
+
+	names := append([]*ast.Identifier{}, fn.Parameters...)
+	names = append(names, fn.Rest)
+	o.enterScope(fn.Body.Statements, names...)
+
+	for i, def := range fn.Defaults {
+		if def != nil {
+			fn.Defaults[i] = o.expression(def)
+		}
+	}
+	fn.Body.Statements = o.statements(fn.Body.Statements, true, false)
+
+	o.leaveScope()
+}
+
+func isLiteral(expr ast.Expression) bool {
+	return value(expr) != nil
+}
+
+// value returns the value of a literal, or nil if expr is not one.
+func value(expr ast.Expression) object.Object {
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: e.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: e.Value}
+	case *ast.StringLiteral:
+		if e == nil {
+			return nil
+		}
+		return &object.String{Value: e.Value}
+	case *ast.BooleanLiteral:
+		if e.Value {
+			return eval.TRUE
+		}
+		return eval.FALSE
+	}
+	return nil
+}
+
+// literal returns the literal standing for obj, the value of node, or nil
+// if obj has none or is an error, which is left to be raised when the
+// program runs.
+func literal(node ast.Expression, obj object.Object) ast.Expression {
+	tok := token.Token{Pos: node.Pos(), End: node.End()}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, obj.Inspect()
+		return &ast.IntegerLiteral{Token: tok, Value: obj.Value}
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return nil
+		}
+		tok.Type, tok.Literal = token.FLOAT, obj.Inspect()
+		return &ast.FloatLiteral{Token: tok, Value: obj.Value}
+	case *object.String:
+		if len(obj.Value) > maxFoldedString {
+			return nil
+		}
+		tok.Type, tok.Literal = token.STRING, obj.Value
+		return &ast.StringLiteral{Token: tok, Value: obj.Value}
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if obj.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.BooleanLiteral{Token: tok, Value: obj.Value}
+	}
+
+	return nil
+}
+
+// relocate returns a copy of lit at the position of the identifier it
+// replaces, where errors involving it are reported.
+func relocate(lit ast.Expression, at *ast.Identifier) ast.Expression {
+	return literal(at, value(lit))
+}
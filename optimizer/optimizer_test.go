@@ -0,0 +1,96 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"-(4 - 10)", "6"},
+		{`"a" + "b"`, `"ab"`},
+		{"!true", "false"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"2.0 * 2", "4.0"},
+		{"{1 + 1: 2 * 2}", "{2:4}"},
+		// errors are left to be raised when the program runs
+		{"1 / 0", "(1 / 0)"},
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (1 > 2) { 1 } else { 2 }", "2"},
+		{"if (true) { puts(1); puts(2) }", "iftrue puts(1)puts(2)"},
+		{"let x = 2; let y = x * 3; y + 1", "let x = 2;let y = 6;7"},
+		{"let a = 1; let b = fn() { a + 1 }", "let a = 1;let b = fn() 2;"},
+		{"let f = fn(n = 2 + 2) { n }", "let f = fn(n = 4) n;"},
+		{"let f = fn(n) { if (false) { n }; let k = 10; n * k }", "let f = fn(n) let k = 10;(n * 10);"},
+		// variables that may be bound to something else are kept
+		{"let x = 2; x = 3; x", "let x = 2;x = 3;x"},
+		{"let x = 2; let x = 3; x", "let x = 2;let x = 3;x"},
+		{"let f = fn(x) { let x = 1; x }", "let f = fn(x) let x = 1;x;"},
+		{"let f = fn() { if (true) { let a = 1; a } }", "let f = fn() let a = 1;a;"},
+		{"let f = fn() { x }; let x = 1", "let f = fn() x;let x = 1;"},
+		{"let x = 1; for (x in [2]) { x }", "let x = 1;for (x in [2]) x"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+
+		if got := Optimize(program).String(); got != tt.expected {
+			t.Errorf("%q: wrong program.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// The evaluator tests check optimizing them changes nothing; these are
+// programs written to catch optimizations that would.
+func TestResultsUnchanged(t *testing.T) {
+	inputs := []string{
+		"let f = fn() { let x = 1; let r = 0; while (true) { let g = fn() { x }; let x = 2; r = g(); break }; r }; f()",
+		"let f = fn() { let g = fn() { y }; let y = 5; g() }; f()",
+		"let x = 1; let f = fn() { x }; x = 2; f()",
+		"let f = fn() { if (true) { try { throw 1 } catch (e) { e }; 5 } }; f()",
+		"if (true) { try { throw 1 } catch (e) { e } }; 5",
+		"let f = fn() { if (false) { 1 } }; f()",
+		"let f = fn() { let a = 1; if (true) {}; }; f()",
+		"let x = 10; let f = fn(x = x + 1) { x }; [f(), f(1)]",
+		`let s = "a" * 3; s`,
+		"let a = 1; let h = {a: a + 1}; h[1]",
+		"let i = 0; while (1 < 2) { i += 1; if (i > 3) { break } }; i",
+		"let f = fn(n) { return if (n > 0) { n } else { -n } }; f(-3)",
+		"1 + true",
+	}
+
+	for _, input := range inputs {
+		want := run(input, false)
+		got := run(input, true)
+
+		if got != want {
+			t.Errorf("%q: optimizing changed the result.\nwant=%s\ngot =%s", input, want, got)
+		}
+	}
+}
+
+func run(input string, optimize bool) string {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	if optimize {
+		Optimize(program)
+	}
+	resolver.Resolve(program)
+
+	result := eval.Eval(program, object.NewEnvironment())
+	if result == nil {
+		return "<nil>"
+	}
+	if err, ok := result.(*object.Error); ok {
+		return err.Inspect() + "\n" + err.Traceback()
+	}
+	return string(result.Type()) + " " + result.Inspect()
+}
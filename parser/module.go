@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/token"
+)
+
+// parseImportStatement parses `import "path" as name;` and
+// `import { a, b } from "path";`. The alias of the first form may be
+// omitted, in which case the module is bound to the base name of its path.
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	p.checkTopLevel()
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if !p.parseImportNames(stmt) {
+			return nil
+		}
+		if !p.expectContextual("from") {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	stmt.Last = p.curToken
+
+	if len(stmt.Names) == 0 && p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "as" {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		stmt.Last = p.curToken
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Last = p.curToken
+	}
+
+	return stmt
+}
+
+// parseImportNames parses the `{ a, b }` list of a selective import.
+func (p *Parser) parseImportNames(stmt *ast.ImportStatement) bool {
+	open := p.curToken
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.RBRACE) {
+			break
+		}
+	}
+
+	return p.expectClosing(token.RBRACE, open)
+}
+
+// expectContextual is expectPeek for an identifier such as `from` that is
+// only a keyword in one position.
+func (p *Parser) expectContextual(word string) bool {
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == word {
+		p.nextToken()
+		return true
+	}
+
+	p.report(diag.Errorf(diag.TokenSpan(p.peekToken), codeUnexpectedToken,
+		"expected next token to be %s, got %s instead", word, p.peekToken.Type))
+	return false
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	p.checkTopLevel()
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	let, ok := p.parseLetStatement().(*ast.LetStatement)
+	if !ok {
+		return nil
+	}
+	stmt.Statement = let
+
+	return stmt
+}
+
+// checkTopLevel reports the current import or export token if it is nested
+// inside a block.
+func (p *Parser) checkTopLevel() {
+	if p.blocks > 0 {
+		p.report(diag.Errorf(diag.TokenSpan(p.curToken), codeNotTopLevel,
+			"%s is only allowed at the top level", p.curToken.Literal))
+	}
+}
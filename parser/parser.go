@@ -3,8 +3,10 @@ package parser
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
 	"github.com/threeaccents/digolang/lexer"
 	"github.com/threeaccents/digolang/token"
 )
@@ -12,26 +14,57 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // * or /
+	RANGE       // .. or ..<
+	SUM         // + - | ^
+	PRODUCT     // * / % << >> &
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
 	INDEX       // array[x]
 )
 
+// Diagnostic codes reported by the parser.
+const (
+	codeUnexpectedToken   = "P0001"
+	codeNoPrefixParseFn   = "P0002"
+	codeInvalidInteger    = "P0003"
+	codeInvalidBoolean    = "P0004"
+	codeInvalidFloat      = "P0005"
+	codeInvalidAssignment = "P0006"
+	codeOutsideLoop       = "P0007"
+	codeInvalidParameters = "P0008"
+	codeInvalidArguments  = "P0009"
+	codeNotTopLevel       = "P0010"
+	codeInvalidType       = "P0011"
+)
+
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.RANGE:           RANGE,
+	token.RANGE_EXCLUSIVE: RANGE,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.PIPE:            SUM,
+	token.CARET:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.SHL:             PRODUCT,
+	token.SHR:             PRODUCT,
+	token.AMPERSAND:       PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.PERIOD:          INDEX,
 }
 
 type (
@@ -42,31 +75,55 @@ type (
 type Parser struct {
 	l *lexer.Lexer
 
-	errors []string
+	errors []diag.Diagnostic
 
 	curToken  token.Token
 	peekToken token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// resume is set when error recovery stopped on a token that starts the
+	// next statement, so the statement loops must not step past it.
+	resume bool
+	// synced is the number of errors already recovered from, so enclosing
+	// statements do not resynchronize a second time.
+	synced int
+	// lexErrors is the number of lexer diagnostics copied into errors.
+	lexErrors int
+	// loops is the number of loops enclosing the current token within the
+	// current function.
+	loops int
+	// blocks is the number of blocks enclosing the current token.
+	blocks int
+	// functions is the number of function literals enclosing the current
+	// token, and tries the number of try expressions enclosing it within the
+	// innermost one.
+	functions int
+	tries     int
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []diag.Diagnostic{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefix(token.FALSE, p.parseBooleanLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
+	p.registerPrefix(token.ELLIPSIS, p.parseSpreadExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
@@ -80,8 +137,21 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.RANGE, p.parseInfixExpression)
+	p.registerInfix(token.RANGE_EXCLUSIVE, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.PERIOD, p.parseSelectorExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -92,6 +162,11 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	for _, d := range p.l.Errors()[p.lexErrors:] {
+		p.report(d)
+	}
+	p.lexErrors = len(p.l.Errors())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -103,24 +178,49 @@ func (p *Parser) ParseProgram() *ast.Program {
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
-		p.nextToken()
+		p.nextStatement()
 	}
 
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+	errs := len(p.errors)
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
+	case token.WHILE:
+		stmt = p.parseWhileStatement()
+	case token.FOR:
+		stmt = p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		stmt = p.parseLoopControlStatement()
+	case token.IMPORT:
+		stmt = p.parseImportStatement()
+	case token.EXPORT:
+		stmt = p.parseExportStatement()
+	case token.THROW:
+		stmt = p.parseThrowStatement()
 	default:
-		return p.parseExpressionStatement()
+		stmt = p.parseExpressionStatement()
 	}
+
+	if len(p.errors) > errs && len(p.errors) > p.synced {
+		p.recoverStatement(start)
+		if stmt == nil {
+			stmt = &ast.BadStatement{From: start, To: p.curToken}
+		}
+	}
+
+	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	// defer untrace(trace("parseExpressionStatement"))
 	stmt := &ast.ExpressionStatement{
 		Token: p.curToken,
@@ -128,6 +228,10 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if isAssignment(p.peekToken.Type) {
+		return p.parseAssignStatement(stmt.Expression)
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -135,7 +239,45 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	return stmt
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func isAssignment(t token.TokenType) bool {
+	switch t {
+	case token.ASSIGN, token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN:
+		return true
+	}
+
+	return false
+}
+
+// parseAssignStatement parses the rest of an assignment whose target has
+// already been parsed. The peek token is the assignment operator.
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	p.nextToken()
+
+	stmt := &ast.AssignStatement{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression, *ast.SelectorExpression:
+	default:
+		p.report(diag.Errorf(diag.SpanOf(target), codeInvalidAssignment,
+			"cannot assign to %s", target.String()))
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{
 		Token: p.curToken,
 	}
@@ -153,6 +295,12 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		Value: p.curToken.Literal,
 	}
 
+	if p.peekTokenIs(token.COLON) {
+		if stmt.Type = p.parseAnnotation(); stmt.Type == nil {
+			return stmt
+		}
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 		stmt.Expression = nil
@@ -160,13 +308,18 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 
 	if !p.expectPeek(token.ASSIGN) {
-		return nil
+		stmt.Expression = &ast.BadExpression{Token: p.peekToken}
+		return stmt
 	}
 
 	p.nextToken()
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Expression.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -183,6 +336,12 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
+	// a call returned from a function can reuse the caller's frame, unless
+	// a finally clause still has to run after it
+	if _, ok := stmt.ReturnValue.(*ast.CallExpression); ok && p.functions > 0 && p.tries == 0 {
+		stmt.TailCall = true
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -196,7 +355,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		return &ast.BadExpression{Token: p.curToken}
 	}
 	leftExp := prefix()
 
@@ -229,7 +388,10 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 		Elements: []ast.Expression{},
 	}
 
-	al.Elements = p.parseExpressionList(token.RBRACKET)
+	al.Elements = p.parseExpressionList(token.RBRACKET, p.parseElement)
+	if p.curTokenIs(token.RBRACKET) {
+		al.Rbracket = p.curToken
+	}
 
 	return al
 }
@@ -241,55 +403,119 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	}
 
 	for !p.peekTokenIs(token.RBRACE) {
+		errs := len(p.errors)
+
 		p.nextToken()
 
 		key := p.parseExpression(LOWEST)
 
-		if !p.expectPeek(token.COLON) {
-			return nil
+		var value ast.Expression
+		if p.expectPeek(token.COLON) {
+			p.nextToken()
+			value = p.parseExpression(LOWEST)
+		} else {
+			value = &ast.BadExpression{Token: p.peekToken}
 		}
 
-		p.nextToken()
-
-		value := p.parseExpression(LOWEST)
-
 		hl.Pairs[key] = value
+		hl.Keys = append(hl.Keys, key)
+
+		if len(p.errors) > errs && !p.skipTo(token.COMMA, token.RBRACE) {
+			return hl
+		}
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+			if !p.skipTo(token.COMMA, token.RBRACE) {
+				return hl
+			}
+			if p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+			}
 		}
 	}
 
-	if !p.expectPeek(token.RBRACE) {
-		return nil
+	if !p.expectClosing(token.RBRACE, hl.Token) {
+		return hl
 	}
 
+	hl.Rbrace = p.curToken
+
 	return hl
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
-	var list []ast.Expression
+// parseElement parses one element of an array literal.
+func (p *Parser) parseElement() ast.Expression {
+	return p.parseExpression(LOWEST)
+}
 
-	if p.peekTokenIs(token.RBRACKET) {
-		return list
+// parseArgument parses one argument of a call, which may be a keyword
+// argument `name = value`.
+func (p *Parser) parseArgument() ast.Expression {
+	arg := p.parseExpression(LOWEST)
+
+	name, ok := arg.(*ast.Identifier)
+	if !ok || !p.peekTokenIs(token.ASSIGN) {
+		return arg
 	}
 
+	p.nextToken()
 	p.nextToken()
 
-	list = append(list, p.parseExpression(LOWEST))
+	return &ast.KeywordArgument{
+		Name:  name,
+		Value: p.parseExpression(LOWEST),
+	}
+}
 
-	for p.peekTokenIs(token.COMMA) {
+func (p *Parser) parseExpressionList(end token.TokenType, parseElement func() ast.Expression) []ast.Expression {
+	var list []ast.Expression
+
+	open := p.curToken
+
+	if p.peekTokenIs(end) {
 		p.nextToken()
+		return list
+	}
+
+	for {
+		errs := len(p.errors)
+
 		p.nextToken()
 
-		list = append(list, p.parseExpression(LOWEST))
-	}
+		list = append(list, parseElement())
+
+		if len(p.errors) > errs {
+			// an empty element, e.g. `[1, , 3]`, leaves us on the separator
+			if p.curTokenIs(token.COMMA) {
+				p.synced = len(p.errors)
+				continue
+			}
+			if p.curTokenIs(end) {
+				p.synced = len(p.errors)
+				return list
+			}
+			if !p.skipTo(token.COMMA, end) {
+				return list
+			}
+		}
 
-	if !p.expectPeek(end) {
-		return nil
-	}
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			continue
+		}
+
+		if !p.expectClosing(end, open) {
+			// a missing separator; carry on with the next element if there is one
+			if p.skipTo(token.COMMA, end) {
+				p.nextToken()
+				if p.curTokenIs(token.COMMA) {
+					continue
+				}
+			}
+		}
 
-	return list
+		return list
+	}
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
@@ -298,14 +524,28 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return &ast.BadExpression{Token: fl.Token}
 	}
 
-	// set parameters
-	fl.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(fl) {
+		// the error was reported and recovery gave up before the `)`
+		fl.Body = &ast.BlockStatement{Token: p.curToken}
+		return fl
+	}
+	fl.ReturnType = p.parseResultType()
+
+	// break and continue cannot cross a function boundary
+	loops, tries := p.loops, p.tries
+	p.loops, p.tries = 0, 0
+	p.functions++
+	defer func() {
+		p.loops, p.tries = loops, tries
+		p.functions--
+	}()
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		fl.Body = &ast.BlockStatement{Token: p.curToken}
+		return fl
 	}
 
 	fl.Body = p.parseBlockStatement()
@@ -313,40 +553,102 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return fl
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	var idens []*ast.Identifier
+// parseFunctionParameters parses a parameter list such as
+// `(x, y = 10, ...rest)` into fl. It reports false if error recovery gave up
+// before reaching the closing `)`.
+func (p *Parser) parseFunctionParameters(fl *ast.FunctionLiteral) bool {
+	open := p.curToken
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return idens
+		return true
 	}
 
-	p.nextToken()
+	var defaults []ast.Expression
+	var withDefault ast.Expression
+	var types []ast.TypeExpression
+	typed := false
+	// closed is cleared when recovery gave up before the closing `)`
+	closed := true
+
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if p.expectPeek(token.IDENT) {
+				fl.Rest = &ast.Identifier{
+					Token: p.curToken,
+					Value: p.curToken.Literal,
+				}
+				if p.peekTokenIs(token.COLON) {
+					fl.RestType = p.parseAnnotation()
+				}
+			}
+			if p.peekTokenIs(token.COMMA) {
+				p.report(diag.Errorf(diag.TokenSpan(p.peekToken), codeInvalidParameters,
+					"rest parameter must be the last parameter"))
+				closed = p.skipTo(token.RPAREN)
+			}
+			break
+		}
 
-	iden := &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
-	}
+		if p.expectPeek(token.IDENT) {
+			iden := &ast.Identifier{
+				Token: p.curToken,
+				Value: p.curToken.Literal,
+			}
+
+			var typ ast.TypeExpression
+			if p.peekTokenIs(token.COLON) {
+				typed = true
+				if typ = p.parseAnnotation(); typ == nil && !p.skipTo(token.COMMA, token.RPAREN) {
+					closed = false
+					break
+				}
+			}
+
+			var def ast.Expression
+			if p.peekTokenIs(token.ASSIGN) {
+				p.nextToken()
+				p.nextToken()
+				def = p.parseExpression(LOWEST)
+				withDefault = def
+			} else if withDefault != nil {
+				d := diag.Errorf(diag.SpanOf(iden), codeInvalidParameters,
+					"parameter %s without a default follows a parameter with one", iden.Value)
+				d.Related = append(d.Related, diag.Related{
+					Span:    diag.SpanOf(withDefault),
+					Message: "default given here",
+				})
+				p.report(d)
+			}
+
+			fl.Parameters = append(fl.Parameters, iden)
+			defaults = append(defaults, def)
+			types = append(types, typ)
+		} else if !p.skipTo(token.COMMA, token.RPAREN) {
+			closed = false
+			break
+		}
 
-	idens = append(idens, iden)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
 
-	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
-		p.nextToken()
-
-		iden := &ast.Identifier{
-			Token: p.curToken,
-			Value: p.curToken.Literal,
-		}
+	}
 
-		idens = append(idens, iden)
+	if withDefault != nil {
+		fl.Defaults = defaults
+	}
+	if typed {
+		fl.ParameterTypes = types
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if closed {
+		p.expectClosing(token.RPAREN, open)
 	}
 
-	return idens
+	return closed
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
@@ -356,14 +658,45 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	}
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{
+		Token:   p.curToken,
+		Strings: []string{p.curToken.Literal},
+	}
+
+	for {
+		p.nextToken()
+		str.Expressions = append(str.Expressions, p.parseExpression(LOWEST))
+
+		switch {
+		case p.peekTokenIs(token.STRING_MID):
+			p.nextToken()
+			str.Strings = append(str.Strings, p.curToken.Literal)
+		case p.peekTokenIs(token.STRING_TAIL):
+			p.nextToken()
+			str.Strings = append(str.Strings, p.curToken.Literal)
+			str.Tail = p.curToken
+			return str
+		default:
+			d := diag.Errorf(diag.TokenSpan(p.peekToken), codeUnexpectedToken,
+				"expected } after interpolated expression, got %s instead", p.peekToken.Type)
+			d.Related = append(d.Related, diag.Related{
+				Span:    diag.TokenSpan(str.Token),
+				Message: "in this interpolated string",
+			})
+			p.report(d)
+			return &ast.BadExpression{Token: p.peekToken}
+		}
+	}
+}
+
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	// defer untrace(trace("parseIntegerLiteral"))
 
-	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	value, err := parseInt(p.curToken.Literal)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.numberError(err, "integer")
+		return &ast.BadExpression{Token: p.curToken}
 	}
 
 	return &ast.IntegerLiteral{
@@ -372,14 +705,81 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	}
 }
 
+// parseInt parses an integer literal, whose base is given by its prefix. A
+// literal without a prefix is decimal even if it starts with a zero; the
+// lexer reports those.
+func parseInt(lit string) (int64, error) {
+	digits := strings.Replace(lit, "_", "", -1)
+
+	base := 10
+	if len(digits) > 1 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+	if base != 10 {
+		digits = digits[2:]
+	}
+
+	return strconv.ParseInt(digits, base, 64)
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.numberError(err, "float")
+		return &ast.BadExpression{Token: p.curToken}
+	}
+
+	return &ast.FloatLiteral{
+		Token: p.curToken,
+		Value: value,
+	}
+}
+
+// numberError reports why the current number literal failed to parse,
+// unless the lexer already reported it as malformed.
+func (p *Parser) numberError(err error, kind string) {
+	tok := p.curToken
+
+	for i := len(p.errors) - 1; i >= 0; i-- {
+		start := p.errors[i].Span.Start.Offset
+		if start < tok.Pos.Offset {
+			break
+		}
+		if start < tok.End.Offset {
+			return
+		}
+	}
+
+	code := codeInvalidInteger
+	if kind == "float" {
+		code = codeInvalidFloat
+	}
+
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		p.report(diag.Errorf(diag.TokenSpan(tok), code,
+			"%s literal %s is out of range", kind, tok.Literal))
+		return
+	}
+
+	p.report(diag.Errorf(diag.TokenSpan(tok), code,
+		"could not parse %q as %s", tok.Literal, kind))
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	// defer untrace(trace("parseBooleanLiteral"))
 
 	value, err := strconv.ParseBool(p.curToken.Literal)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as boolean", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.report(diag.Errorf(diag.TokenSpan(p.curToken), codeInvalidBoolean,
+			"could not parse %q as boolean", p.curToken.Literal))
+		return &ast.BadExpression{Token: p.curToken}
 	}
 
 	return &ast.BooleanLiteral{
@@ -391,13 +791,13 @@ func (p *Parser) parseBooleanLiteral() ast.Expression {
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	// defer untrace(trace("parseGroupExpression"))
 
+	open := p.curToken
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
+	p.expectClosing(token.RPAREN, open)
 
 	return exp
 }
@@ -410,18 +810,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		ie.Condition = &ast.BadExpression{Token: p.peekToken}
+		ie.Consequence = &ast.BlockStatement{Token: p.peekToken}
+		return ie
 	}
+	open := p.curToken
 	p.nextToken()
 
 	ie.Condition = p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
-	}
-
-	if !p.expectPeek(token.LBRACE) {
-		return nil
+	if !p.expectClosing(token.RPAREN, open) || !p.expectPeek(token.LBRACE) {
+		ie.Consequence = &ast.BlockStatement{Token: p.peekToken}
+		return ie
 	}
 
 	ie.Consequence = p.parseBlockStatement()
@@ -430,7 +830,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 		p.nextToken()
 
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return ie
 		}
 
 		ie.Alternative = p.parseBlockStatement()
@@ -439,6 +839,97 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return ie
 }
 
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	open := p.curToken
+	p.nextToken()
+
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectClosing(token.RPAREN, open) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{
+		Token: p.curToken,
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	open := p.curToken
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{
+		Token: p.curToken,
+		Value: p.curToken.Literal,
+	}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectClosing(token.RPAREN, open) || !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loops++
+	defer func() { p.loops-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	var stmt ast.Statement
+	if p.curTokenIs(token.BREAK) {
+		stmt = &ast.BreakStatement{Token: p.curToken}
+	} else {
+		stmt = &ast.ContinueStatement{Token: p.curToken}
+	}
+
+	if p.loops == 0 {
+		p.report(diag.Errorf(diag.TokenSpan(p.curToken), codeOutsideLoop,
+			"%s is not in a loop", p.curToken.Literal))
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{
 		Token:      p.curToken,
@@ -446,14 +937,24 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	}
 	p.nextToken()
 
+	p.blocks++
+	defer func() { p.blocks-- }()
+
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
-		p.nextToken()
+		p.nextStatement()
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+		p.unclosedError(p.curToken, token.RBRACE, block.Token)
+		return block
 	}
 
+	block.Rbrace = p.curToken
+
 	return block
 }
 
@@ -472,6 +973,18 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	return pe
 }
 
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	se := &ast.SpreadExpression{
+		Token: p.curToken,
+	}
+
+	p.nextToken()
+
+	se.Value = p.parseExpression(LOWEST)
+
+	return se
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	// defer untrace(trace("parseInfixExpression"))
 
@@ -496,50 +1009,97 @@ func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
 		Function: left,
 	}
 
-	ce.Arguments = p.parseFunctionArguments()
+	ce.Arguments = p.parseExpressionList(token.RPAREN, p.parseArgument)
+	if p.curTokenIs(token.RPAREN) {
+		ce.Rparen = p.curToken
+	}
+
+	var keyword ast.Expression
+	for _, arg := range ce.Arguments {
+		if _, ok := arg.(*ast.KeywordArgument); ok {
+			keyword = arg
+			continue
+		}
+		if keyword != nil {
+			d := diag.Errorf(diag.SpanOf(arg), codeInvalidArguments,
+				"positional argument follows keyword argument")
+			d.Related = append(d.Related, diag.Related{
+				Span:    diag.SpanOf(keyword),
+				Message: "keyword argument given here",
+			})
+			p.report(d)
+			break
+		}
+	}
 
 	return ce
 }
 
+// parseIndexExpression parses `left[index]` and the slice forms
+// `left[low:high]`, where either bound may be omitted.
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	ie := &ast.IndexExpression{
-		Token: p.curToken,
-		Left:  left,
+	open := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
 	}
 
-	p.nextToken()
+	if !p.peekTokenIs(token.COLON) {
+		ie := &ast.IndexExpression{
+			Token: open,
+			Left:  left,
+			Index: index,
+		}
+
+		if !p.expectClosing(token.RBRACKET, open) {
+			return ie
+		}
 
-	ie.Index = p.parseExpression(LOWEST)
+		ie.Rbracket = p.curToken
 
-	if !p.expectPeek(token.RBRACKET) {
-		return nil
+		return ie
 	}
 
-	return ie
-}
+	se := &ast.SliceExpression{
+		Token: open,
+		Left:  left,
+		Low:   index,
+	}
 
-func (p *Parser) parseFunctionArguments() []ast.Expression {
-	var args []ast.Expression
+	p.nextToken()
 
-	if p.peekTokenIs(token.RPAREN) {
+	if !p.peekTokenIs(token.RBRACKET) {
 		p.nextToken()
-		return args
+		se.High = p.parseExpression(LOWEST)
 	}
 
-	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	if !p.expectClosing(token.RBRACKET, open) {
+		return se
+	}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+	se.Rbracket = p.curToken
+
+	return se
+}
+
+func (p *Parser) parseSelectorExpression(left ast.Expression) ast.Expression {
+	se := &ast.SelectorExpression{
+		Token: p.curToken,
+		Left:  left,
 	}
 
-	if !p.expectPeek(token.RPAREN) {
-		return nil
+	if !p.expectPeek(token.IDENT) {
+		return se
+	}
+
+	se.Selector = &ast.Identifier{
+		Token: p.curToken,
+		Value: p.curToken.Literal,
 	}
 
-	return args
+	return se
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -560,14 +1120,35 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	return false
 }
 
-func (p *Parser) Errors() []string {
+// expectClosing is expectPeek for the token closing a delimited construct.
+// On failure the diagnostic also points back at the opening token.
+func (p *Parser) expectClosing(t token.TokenType, open token.Token) bool {
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+
+	p.unclosedError(p.peekToken, t, open)
+	return false
+}
+
+func (p *Parser) Errors() []diag.Diagnostic {
 	return p.errors
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.report(diag.Errorf(diag.TokenSpan(p.peekToken), codeUnexpectedToken,
+		"expected next token to be %s, got %s instead", t, p.peekToken.Type))
+}
+
+func (p *Parser) unclosedError(got token.Token, t token.TokenType, open token.Token) {
+	d := diag.Errorf(diag.TokenSpan(got), codeUnexpectedToken,
+		"expected next token to be %s, got %s instead", t, got.Type)
+	d.Related = append(d.Related, diag.Related{
+		Span:    diag.TokenSpan(open),
+		Message: fmt.Sprintf("to match this %s", open.Literal),
+	})
+	p.report(d)
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -587,8 +1168,8 @@ func (p *Parser) curPrecedence() int {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.report(diag.Errorf(diag.TokenSpan(p.curToken), codeNoPrefixParseFn,
+		"no prefix parse function for %s found", t))
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
 
 	"github.com/threeaccents/digolang/lexer"
 )
@@ -45,9 +46,9 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
 			continue
 		}
-		testFunc, ok := tests[literal.String()]
+		testFunc, ok := tests[literal.Value]
 		if !ok {
-			t.Errorf("No test function for key %q found", literal.String())
+			t.Errorf("No test function for key %q found", literal.Value)
 			continue
 		}
 
@@ -85,7 +86,7 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
 		}
 
-		expectedValue := expected[literal.String()]
+		expectedValue := expected[literal.Value]
 
 		testIntegerLiteral(t, value, expectedValue)
 	}
@@ -253,6 +254,58 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"3 + 4 * 5 == 3 * 1 + 4 * 5",
 			"((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a < b && b <= c == true",
+			"((a < b) && ((b <= c) == true))",
+		},
+		{
+			"a + b % c >= d",
+			"((a + (b % c)) >= d)",
+		},
+		{
+			"a | b & c ^ d",
+			"((a | (b & c)) ^ d)",
+		},
+		{
+			"1 << n - 1",
+			"((1 << n) - 1)",
+		},
+		{
+			"~a >> 2 & mask",
+			"(((~a) >> 2) & mask)",
+		},
+		{
+			"0..n - 1",
+			"(0 .. (n - 1))",
+		},
+		{
+			"i < 0..<n",
+			"(i < (0 ..< n))",
+		},
+		{
+			"a[1:n + 1]",
+			"(a[1:(n + 1)])",
+		},
+		{
+			"a[:2][-1]",
+			"((a[:2])[(-1)])",
+		},
+		{
+			"a[i:]",
+			"(a[i:])",
+		},
+		{
+			"a[:]",
+			"(a[:])",
+		},
 	}
 
 	for _, tt := range tests {
@@ -382,6 +435,88 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedParams   []string
+		expectedDefaults []string
+		expectedRest     string
+	}{
+		{"fn(x, y = 10) {}", []string{"x", "y"}, []string{"", "10"}, ""},
+		{"fn(a = 1, b = a * 2) {}", []string{"a", "b"}, []string{"1", "(a * 2)"}, ""},
+		{"fn(head, ...tail) {}", []string{"head"}, nil, "tail"},
+		{"fn(...args) {}", nil, nil, "args"},
+		{"fn(x, y = [], ...more) {}", []string{"x", "y"}, []string{"", "[]"}, "more"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("length parameters wrong. want %d, got=%d",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+
+		if len(function.Defaults) != len(tt.expectedDefaults) {
+			t.Fatalf("length defaults wrong. want %d, got=%d",
+				len(tt.expectedDefaults), len(function.Defaults))
+		}
+		for i, def := range tt.expectedDefaults {
+			var got string
+			if function.Defaults[i] != nil {
+				got = function.Defaults[i].String()
+			}
+			if got != def {
+				t.Errorf("default %d wrong. want %q, got=%q", i, def, got)
+			}
+		}
+
+		var rest string
+		if function.Rest != nil {
+			rest = function.Rest.Value
+		}
+		if rest != tt.expectedRest {
+			t.Errorf("rest parameter wrong. want %q, got=%q", tt.expectedRest, rest)
+		}
+	}
+}
+
+func TestCallArgumentParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f(...xs)", "f(...xs)"},
+		{"f(1, ...a[1:], 2)", "f(1, ...(a[1:]), 2)"},
+		{"a.b.c(1)", "((a.b).c)(1)"},
+		{"-h.x * 2", "((-(h.x)) * 2)"},
+		{"xs.map(f)[0].y", "(((xs.map)(f)[0]).y)"},
+		{"f(1, y = 2, z = a + b)", "f(1, y = 2, z = (a + b))"},
+		{"[0, ...xs]", "[0, ...xs]"},
+		{"fn(x, y = 1, ...r) { x }", "fn(x, y = 1, ...r) x"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -721,6 +856,37 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "2.5;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 2.5 {
+		t.Errorf("literal.Value not %s. got=%g", "2.5", literal.Value)
+	}
+	if literal.TokenLiteral() != "2.5" {
+		t.Errorf("literal.TokenLiteral not %s. got=%s", "2.5",
+			literal.TokenLiteral())
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"r0dr!g0";`
 
@@ -752,6 +918,57 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input               string
+		expectedStrings     []string
+		expectedExpressions []string
+		expectedString      string
+	}{
+		{`"${x}"`, []string{"", ""}, []string{"x"}, `"${x}"`},
+		{`"a ${x + 1} b"`, []string{"a ", " b"}, []string{"(x + 1)"}, `"a ${(x + 1)} b"`},
+		{`"${a}${ f("${b}\n") }!"`, []string{"", "", "!"}, []string{"a", `f("${b}\n")`}, `"${a}${f("${b}\n")}!"`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Expression)
+		}
+
+		if len(str.Strings) != len(tt.expectedStrings) {
+			t.Fatalf("wrong number of strings. expected=%d, got=%d",
+				len(tt.expectedStrings), len(str.Strings))
+		}
+		for i, s := range tt.expectedStrings {
+			if str.Strings[i] != s {
+				t.Errorf("strings[%d] wrong. expected=%q, got=%q", i, s, str.Strings[i])
+			}
+		}
+
+		if len(str.Expressions) != len(tt.expectedExpressions) {
+			t.Fatalf("wrong number of expressions. expected=%d, got=%d",
+				len(tt.expectedExpressions), len(str.Expressions))
+		}
+		for i, e := range tt.expectedExpressions {
+			if str.Expressions[i].String() != e {
+				t.Errorf("expressions[%d] wrong. expected=%q, got=%q",
+					i, e, str.Expressions[i].String())
+			}
+		}
+
+		if str.String() != tt.expectedString {
+			t.Errorf("str.String() wrong. expected=%q, got=%q", tt.expectedString, str.String())
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input              string
@@ -786,6 +1003,240 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedTarget   string
+		expectedOperator string
+		expectedValue    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"total += a * b", "total", "+=", "(a * b)"},
+		{"n -= 1", "n", "-=", "1"},
+		{"arr[i + 1] *= 2;", "(arr[(i + 1)])", "*=", "2"},
+		{`h["k"] /= 4`, `(h["k"])`, "/=", "4"},
+		{"h.count += 1", "(h.count)", "+=", "1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("stmt not *ast.AssignStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Target.String() != tt.expectedTarget {
+			t.Errorf("stmt.Target wrong. expected=%q, got=%q", tt.expectedTarget, stmt.Target.String())
+		}
+		if stmt.Operator != tt.expectedOperator {
+			t.Errorf("stmt.Operator wrong. expected=%q, got=%q", tt.expectedOperator, stmt.Operator)
+		}
+		if stmt.Value.String() != tt.expectedValue {
+			t.Errorf("stmt.Value wrong. expected=%q, got=%q", tt.expectedValue, stmt.Value.String())
+		}
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x += 1; }", "while ((x < 10)) x += 1;"},
+		{"for (item in items) { println(item) }", "for (item in items) println(item)"},
+		{"for (k in {1: 2}) { if (k == 1) { continue; } break; }", "for (k in {1:2}) if(k == 1) continue;break;"},
+		{"while (true) { let f = fn() { 1 }; break }", "while (true) let f = fn() 1;break;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestModuleStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "lib/math.digo" as m;`, `import "lib/math.digo" as m;`},
+		{`import "strings"`, `import "strings";`},
+		{`import { min, max, } from "./util"`, `import { min, max } from "./util";`},
+		{"export let answer = 6 * 7;", "export let answer = (6 * 7);"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f() } catch (e) { e.message }", "try f() catch (e) (e.message)"},
+		{"try { f() } catch { 0 }", "try f() catch 0"},
+		{"try { f() } finally { close() }", "try f() finally close()"},
+		{"let x = try { 1 } catch (e) { 2 } finally { 3 };", "let x = try 1 catch (e) 2 finally 3;"},
+		{"throw error(\"bad\");", "throw error(\"bad\");"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+				len(program.Statements))
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestFunctionLiteralName(t *testing.T) {
+	l := lexer.New("let add = fn(a, b) { a + b }; export let id = fn(x) { x }; fn() { 1 }")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	literals := []*ast.FunctionLiteral{
+		program.Statements[0].(*ast.LetStatement).Expression.(*ast.FunctionLiteral),
+		program.Statements[1].(*ast.ExportStatement).Statement.Expression.(*ast.FunctionLiteral),
+		program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral),
+	}
+
+	for i, expected := range []string{"add", "id", ""} {
+		if literals[i].Name != expected {
+			t.Errorf("literals[%d].Name wrong. expected=%q, got=%q", i, expected, literals[i].Name)
+		}
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1", "let x: int = 1;"},
+		{"let xs: [string];", "let xs: [string];"},
+		{"let h: {string: [float]} = {}", "let h: {string: [float]} = {};"},
+		{"fn(a: string, b: [int]) -> bool { true }", "fn(a: string, b: [int]) -> bool true"},
+		{"fn(a, b: int = 2, ...rest: any) { a }", "fn(a, b: int = 2, ...rest: any) a"},
+		{"fn() -> fn(int, ...string) -> [int] { f }", "fn() -> fn(int, ...string) -> [int] f"},
+		{"let f: fn() = fn() { 1 }", "let f: fn() = fn() 1;"},
+		{"fn(x) { x - -1 }", "fn(x) (x - (-1))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"fn(n) { return f(n - 1) }", true},
+		{"fn(n) { if (n > 0) { return f(n - 1) } }", true},
+		{"fn(n) { return 1 + f(n) }", false},
+		{"fn(n) { try { return f(n) } finally { g() } }", false},
+		{"fn(n) { try { fn() { return f(n) } } catch { 1 } }", true},
+		{"return f(1)", false},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		ret := findReturn(program)
+		if ret == nil {
+			t.Fatalf("no return statement in %q", tt.input)
+		}
+		if ret.TailCall != tt.expected {
+			t.Errorf("TailCall wrong for %q. expected=%t, got=%t", tt.input, tt.expected, ret.TailCall)
+		}
+	}
+}
+
+// findReturn returns the first return statement in n, looking through the
+// nodes used by TestTailCallMarking.
+func findReturn(n ast.Node) *ast.ReturnStatement {
+	var children []ast.Node
+
+	switch n := n.(type) {
+	case *ast.ReturnStatement:
+		return n
+	case *ast.Program:
+		for _, s := range n.Statements {
+			children = append(children, s)
+		}
+	case *ast.BlockStatement:
+		for _, s := range n.Statements {
+			children = append(children, s)
+		}
+	case *ast.ExpressionStatement:
+		children = append(children, n.Expression)
+	case *ast.FunctionLiteral:
+		children = append(children, n.Body)
+	case *ast.IfExpression:
+		children = append(children, n.Consequence)
+	case *ast.TryExpression:
+		children = append(children, n.Block)
+	}
+
+	for _, child := range children {
+		if ret := findReturn(child); ret != nil {
+			return ret
+		}
+	}
+
+	return nil
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input         string
@@ -822,6 +1273,369 @@ func TestReturnStatements(t *testing.T) {
 	}
 }
 
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fn(x, y) {
+  x + y;
+};
+add(1, [2, 3][0])`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Expression.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	index := call.Arguments[1].(*ast.IndexExpression)
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{let, "1:1", "3:2"},
+		{fn, "1:11", "3:2"},
+		{fn.Body, "1:20", "3:2"},
+		{body.Expression, "2:3", "2:8"},
+		{call, "4:1", "4:18"},
+		{index, "4:8", "4:17"},
+		{program, "1:1", "4:18"},
+	}
+
+	for i, tt := range tests {
+		if start := tt.node.Pos().String(); start != tt.expectedStart {
+			t.Errorf("tests[%d] - %T start wrong. expected=%s, got=%s",
+				i, tt.node, tt.expectedStart, start)
+		}
+		if end := tt.node.End().String(); end != tt.expectedEnd {
+			t.Errorf("tests[%d] - %T end wrong. expected=%s, got=%s",
+				i, tt.node, tt.expectedEnd, end)
+		}
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedCode    string
+		expectedMessage string
+		expectedPos     string
+		expectedRelated string
+	}{
+		{
+			"let = 5;",
+			"P0001",
+			"expected next token to be IDENT, got = instead",
+			"1:5",
+			"",
+		},
+		{
+			"add(1,\n 2",
+			"P0001",
+			"expected next token to be ), got EOF instead",
+			"2:3",
+			"1:4",
+		},
+		{
+			"if (x) { x",
+			"P0001",
+			"expected next token to be }, got EOF instead",
+			"1:11",
+			"1:8",
+		},
+		{
+			"let x = );",
+			"P0002",
+			"no prefix parse function for ) found",
+			"1:9",
+			"",
+		},
+		{
+			`let s = "a ${x y} b";`,
+			"P0001",
+			"expected } after interpolated expression, got IDENT instead",
+			"1:16",
+			"1:9",
+		},
+		{
+			"let big = 9223372036854775808;",
+			"P0003",
+			"integer literal 9223372036854775808 is out of range",
+			"1:11",
+			"",
+		},
+		{
+			"let huge = 1e999;",
+			"P0005",
+			"float literal 1e999 is out of range",
+			"1:12",
+			"",
+		},
+		{
+			"let mask = 0b12;",
+			"L0004",
+			"invalid digit '2' in binary literal",
+			"1:15",
+			"",
+		},
+		{
+			"let n = 010;",
+			"L0004",
+			"leading zero in decimal literal 010; use 0o for octal",
+			"1:9",
+			"",
+		},
+		{
+			"let n = 08;",
+			"L0004",
+			"leading zero in decimal literal 08; use 0o for octal",
+			"1:9",
+			"",
+		},
+		{
+			"f() = 1;",
+			"P0006",
+			"cannot assign to f()",
+			"1:1",
+			"",
+		},
+		{
+			"let x = 1; break;",
+			"P0007",
+			"break is not in a loop",
+			"1:12",
+			"",
+		},
+		{
+			"while (true) { let f = fn() { continue; }; }",
+			"P0007",
+			"continue is not in a loop",
+			"1:31",
+			"",
+		},
+		{
+			"for (1 in xs) { }",
+			"P0001",
+			"expected next token to be IDENT, got INT instead",
+			"1:6",
+			"",
+		},
+		{
+			"fn(x = 1, y) {}",
+			"P0008",
+			"parameter y without a default follows a parameter with one",
+			"1:11",
+			"1:8",
+		},
+		{
+			"fn(...rest, x) {}",
+			"P0008",
+			"rest parameter must be the last parameter",
+			"1:11",
+			"",
+		},
+		{
+			"f(x = 1, 2)",
+			"P0009",
+			"positional argument follows keyword argument",
+			"1:10",
+			"1:3",
+		},
+		{
+			"fn() { import \"lib\" }",
+			"P0010",
+			"import is only allowed at the top level",
+			"1:8",
+			"",
+		},
+		{
+			"let x: = 1;",
+			"P0011",
+			"expected a type, got = instead",
+			"1:8",
+			"",
+		},
+		{
+			"let f = fn(a: [int) { a };",
+			"P0001",
+			"expected next token to be ], got ) instead",
+			"1:19",
+			"1:15",
+		},
+		{
+			"import { a } \"lib\"",
+			"P0001",
+			"expected next token to be from, got STRING instead",
+			"1:14",
+			"",
+		},
+		{
+			"try { f() } g()",
+			"P0001",
+			"expected next token to be catch or finally, got IDENT instead",
+			"1:13",
+			"",
+		},
+		{
+			"let x = 1; // fine\n/* oops",
+			"L0001",
+			"block comment not terminated",
+			"2:1",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+
+		d := errors[0]
+		if d.Severity != diag.Error {
+			t.Errorf("wrong severity. got=%s", d.Severity)
+		}
+		if d.Code != tt.expectedCode {
+			t.Errorf("wrong code. expected=%q, got=%q", tt.expectedCode, d.Code)
+		}
+		if d.Message != tt.expectedMessage {
+			t.Errorf("wrong message. expected=%q, got=%q", tt.expectedMessage, d.Message)
+		}
+		if pos := d.Span.Start.String(); pos != tt.expectedPos {
+			t.Errorf("wrong position. expected=%s, got=%s", tt.expectedPos, pos)
+		}
+
+		if tt.expectedRelated == "" {
+			continue
+		}
+		if len(d.Related) != 1 {
+			t.Errorf("expected 1 related span. got=%d", len(d.Related))
+			continue
+		}
+		if pos := d.Related[0].Span.Start.String(); pos != tt.expectedRelated {
+			t.Errorf("wrong related position. expected=%s, got=%s", tt.expectedRelated, pos)
+		}
+	}
+}
+
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements string
+	}{
+		{
+			"let = 5; let y = 2; y",
+			[]string{"1:5: expected next token to be IDENT, got = instead"},
+			"<bad statement>let y = 2;y",
+		},
+		{
+			"let x = \nlet y = 2;",
+			[]string{"2:1: no prefix parse function for LET found"},
+			"let x = <bad expression>;let y = 2;",
+		},
+		{
+			"if (x) { let y = } let z = 1",
+			[]string{"1:18: no prefix parse function for } found"},
+			"ifx let y = <bad expression>;let z = 1;",
+		},
+		{
+			"let a = [1, , 3]; let b = [4, 5 6, 7]",
+			[]string{
+				"1:13: no prefix parse function for , found",
+				"1:33: expected next token to be ], got INT instead",
+			},
+			"let a = [1, <bad expression>, 3];let b = [4, 5, 7];",
+		},
+		{
+			"let h = {3 4}; h",
+			[]string{"1:12: expected next token to be :, got INT instead"},
+			"let h = {3:<bad expression>};h",
+		},
+		{
+			"let f = fn(a, 1, c) { a }; f",
+			[]string{"1:15: expected next token to be IDENT, got INT instead"},
+			"let f = fn(a, c) a;f",
+		},
+		{
+			"add(1, 2\nlet q = 3",
+			[]string{"2:1: expected next token to be ), got LET instead"},
+			"add(1, 2)let q = 3;",
+		},
+		{
+			"let a = [1, 2 3\nfor (x in a) { println(x) }",
+			[]string{"1:15: expected next token to be ], got INT instead"},
+			"let a = [1, 2];for (x in a) println(x)",
+		},
+		{
+			"let a = [1, 2 3\nlet b = 2",
+			[]string{"1:15: expected next token to be ], got INT instead"},
+			"let a = [1, 2];let b = 2;",
+		},
+		{
+			"let h = {1: 2 3\nwhile (true) { break }",
+			[]string{"1:15: expected next token to be ,, got INT instead"},
+			"let h = {1:2};while (true) break;",
+		},
+		{
+			"let f = fn(a, 1\nreturn f",
+			[]string{"1:15: expected next token to be IDENT, got INT instead"},
+			"let f = fn(a) ;return f;",
+		},
+		{
+			"let x = 1 + * 2\nfoo(); let = 3",
+			[]string{
+				"1:13: no prefix parse function for * found",
+				"2:12: expected next token to be IDENT, got = instead",
+			},
+			"let x = (1 + <bad expression>);foo()<bad statement>",
+		},
+		{
+			"let s = \"a${1 2}b\"; s",
+			[]string{"1:15: expected } after interpolated expression, got INT instead"},
+			"let s = <bad expression>;s",
+		},
+		{
+			"let s = \"a${x\nlet y = 1",
+			[]string{"2:1: expected } after interpolated expression, got LET instead"},
+			"let s = <bad expression>;let y = 1;",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		var errors []string
+		for _, d := range p.Errors() {
+			errors = append(errors, d.Error())
+		}
+
+		if fmt.Sprint(errors) != fmt.Sprint(tt.expectedErrors) {
+			t.Errorf("wrong errors for %q.\nexpected=%q\ngot=%q",
+				tt.input, tt.expectedErrors, errors)
+		}
+
+		if program.String() != tt.expectedStatements {
+			t.Errorf("wrong program for %q. expected=%q, got=%q",
+				tt.input, tt.expectedStatements, program.String())
+		}
+	}
+}
+
 func testInfixExpression(t *testing.T, exp ast.Expression, left interface{},
 	operator string, right interface{}) bool {
 
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/token"
+)
+
+// report records a diagnostic unless one was already reported at the same
+// position, which is almost always a follow-on error of the first one.
+func (p *Parser) report(d diag.Diagnostic) {
+	if n := len(p.errors); n > 0 && p.errors[n-1].Span.Start == d.Span.Start {
+		return
+	}
+
+	p.errors = append(p.errors, d)
+}
+
+// nextStatement moves past the statement that was just parsed.
+func (p *Parser) nextStatement() {
+	if p.resume {
+		p.resume = false
+		return
+	}
+
+	p.nextToken()
+}
+
+// recoverStatement is called after a statement starting at start reported an
+// error. It resynchronizes the parser at the next statement boundary.
+func (p *Parser) recoverStatement(start token.Token) {
+	p.synced = len(p.errors)
+
+	if p.curToken.Pos != start.Pos && isStatementStart(p.curToken.Type) {
+		// the offending token already begins the next statement
+		p.resume = true
+		return
+	}
+
+	p.synchronize()
+}
+
+// synchronize skips tokens until curToken is the last token of the broken
+// statement: a `;`, or the token before a statement keyword, `}`, EOF or a
+// new line.
+func (p *Parser) synchronize() {
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
+		if isStatementStart(p.peekToken.Type) || p.peekTokenIs(token.EOF) {
+			return
+		}
+
+		if p.peekToken.Pos.Line > p.curToken.Pos.Line {
+			return
+		}
+
+		p.nextToken()
+	}
+}
+
+// skipTo advances inside a delimited list until the peek token is one of
+// types, stepping over nested brackets. It gives up and returns false at EOF
+// and, outside nested brackets, at a new line, at the start of a statement or
+// at a closing bracket that belongs to an outer construct. The caller has
+// already reported the error, so it should not report the list as unclosed
+// when skipTo gives up.
+func (p *Parser) skipTo(types ...token.TokenType) bool {
+	depth := 0
+
+	for {
+		if depth == 0 {
+			for _, t := range types {
+				if p.peekTokenIs(t) {
+					p.synced = len(p.errors)
+					return true
+				}
+			}
+
+			if isStatementStart(p.peekToken.Type) || p.peekToken.Pos.Line > p.curToken.Pos.Line {
+				return false
+			}
+		}
+
+		switch p.peekToken.Type {
+		case token.EOF:
+			return false
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		}
+
+		p.nextToken()
+	}
+}
+
+func isStatementStart(t token.TokenType) bool {
+	switch t {
+	case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE, token.IMPORT, token.EXPORT, token.THROW,
+		token.RBRACE:
+		return true
+	}
+
+	return false
+}
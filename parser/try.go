@@ -0,0 +1,71 @@
+package parser
+
+import (
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/token"
+)
+
+func (p *Parser) parseTryExpression() ast.Expression {
+	te := &ast.TryExpression{Token: p.curToken}
+
+	p.tries++
+	defer func() { p.tries-- }()
+
+	if !p.expectPeek(token.LBRACE) {
+		te.Block = &ast.BlockStatement{Token: p.peekToken}
+		return te
+	}
+	te.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			open := p.curToken
+			if !p.expectPeek(token.IDENT) {
+				return te
+			}
+			te.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectClosing(token.RPAREN, open) {
+				return te
+			}
+		}
+
+		if !p.expectPeek(token.LBRACE) {
+			return te
+		}
+		te.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return te
+		}
+		te.Finally = p.parseBlockStatement()
+	}
+
+	if te.Catch == nil && te.Finally == nil {
+		p.report(diag.Errorf(diag.TokenSpan(p.peekToken), codeUnexpectedToken,
+			"expected next token to be catch or finally, got %s instead", p.peekToken.Type))
+	}
+
+	return te
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/token"
+)
+
+// parseType parses a type annotation starting at the current token. It
+// returns nil after reporting an error if there is no valid type there.
+func (p *Parser) parseType() ast.TypeExpression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayType()
+	case token.LBRACE:
+		return p.parseHashType()
+	case token.FUNCTION:
+		return p.parseFunctionType()
+	}
+
+	p.report(diag.Errorf(diag.TokenSpan(p.curToken), codeInvalidType,
+		"expected a type, got %s instead", p.curToken.Type))
+	return nil
+}
+
+func (p *Parser) parseArrayType() ast.TypeExpression {
+	at := &ast.ArrayType{Token: p.curToken}
+
+	p.nextToken()
+	if at.Element = p.parseType(); at.Element == nil {
+		return nil
+	}
+
+	if !p.expectClosing(token.RBRACKET, at.Token) {
+		return nil
+	}
+	at.Rbracket = p.curToken
+
+	return at
+}
+
+func (p *Parser) parseHashType() ast.TypeExpression {
+	ht := &ast.HashType{Token: p.curToken}
+
+	p.nextToken()
+	if ht.Key = p.parseType(); ht.Key == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+	if ht.Value = p.parseType(); ht.Value == nil {
+		return nil
+	}
+
+	if !p.expectClosing(token.RBRACE, ht.Token) {
+		return nil
+	}
+	ht.Rbrace = p.curToken
+
+	return ht
+}
+
+// parseFunctionType parses `fn(T, ...U) -> R`.
+func (p *Parser) parseFunctionType() ast.TypeExpression {
+	ft := &ast.FunctionType{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	open := p.curToken
+
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if ft.Rest = p.parseType(); ft.Rest == nil {
+				return nil
+			}
+			break
+		}
+
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		ft.Parameters = append(ft.Parameters, param)
+
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectClosing(token.RPAREN, open) {
+		return nil
+	}
+	ft.Rparen = p.curToken
+
+	if p.peekTokenIs(token.ARROW) {
+		if ft.Result = p.parseResultType(); ft.Result == nil {
+			return nil
+		}
+	}
+
+	return ft
+}
+
+// parseResultType parses the `-> T` annotation following the parameters of
+// a function, if there is one.
+func (p *Parser) parseResultType() ast.TypeExpression {
+	if !p.peekTokenIs(token.ARROW) {
+		return nil
+	}
+
+	p.nextToken()
+	p.nextToken()
+
+	return p.parseType()
+}
+
+// parseAnnotation parses the `: T` annotation following a variable or
+// parameter name, if there is one.
+func (p *Parser) parseAnnotation() ast.TypeExpression {
+	if !p.peekTokenIs(token.COLON) {
+		return nil
+	}
+
+	p.nextToken()
+	p.nextToken()
+
+	return p.parseType()
+}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/threeaccents/digolang/compiler"
+	"github.com/threeaccents/digolang/diag"
 	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/vm"
 
 	"github.com/threeaccents/digolang/eval"
 
 	"github.com/threeaccents/digolang/parser"
+	"github.com/threeaccents/digolang/resolver"
 
 	"github.com/threeaccents/digolang/lexer"
 )
@@ -19,7 +23,14 @@ const prompt = ">>"
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
-	env := object.NewEnvironment()
+	// imports typed at the prompt resolve relative to the working directory
+	env := object.NewModuleEnvironment("", eval.NewLoader(eval.DefaultSearchPath()...))
+
+	// each line is compiled on its own, with the globals and constants of
+	// the lines before it
+	globals := compiler.NewGlobalTable()
+	var constants []object.Object
+	values := make([]object.Object, vm.GlobalsSize)
 
 	for {
 		fmt.Fprintf(out, prompt)
@@ -39,11 +50,34 @@ func Start(in io.Reader, out io.Writer) {
 
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printer := diag.NewPrinter(out)
+			printer.AddSource("", line)
+			printer.Print(p.Errors())
+			continue
+		}
+
+		if warnings := resolver.Resolve(program); len(warnings) != 0 {
+			printer := diag.NewPrinter(out)
+			printer.AddSource("", line)
+			printer.Print(warnings)
+		}
+
+		comp := compiler.NewWithState(globals, constants)
+		if err := comp.Compile(program); err != nil {
+			fmt.Fprintln(out, err)
 			continue
 		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
 
-		evaluated := eval.Eval(program, env)
+		machine := vm.NewWithGlobals(bytecode, env, values)
+		evaluated := machine.Run()
+		values = machine.Globals()
+
+		if err, ok := evaluated.(*object.Error); ok && !err.Handled {
+			io.WriteString(out, err.Traceback())
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -52,11 +86,3 @@ func Start(in io.Reader, out io.Writer) {
 
 	fmt.Println("Goodbye =]")
 }
-
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "Woops! error executing program.\n")
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
-	}
-}
@@ -0,0 +1,370 @@
+// Package resolver finds the variable each identifier of a program refers
+// to before it runs, so the evaluator can keep the variables of functions,
+// loop bodies and catch clauses in slots rather than look them up by name.
+//
+// Like the environments of the evaluator, a scope begins at the top level of
+// a program, a function, a loop body or a catch clause, while the blocks of
+// if and try expressions belong to the scope around them. A variable is
+// visible from its let statement on; until then a name refers to a variable
+// of an outer scope.
+package resolver
+
+import (
+	"strings"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
+)
+
+const (
+	codeUseBeforeDefinition = "R0001"
+	codeUnusedVariable      = "R0002"
+)
+
+type scope struct {
+	outer *scope
+	// function is set on the first scope of a function.
+	function bool
+	// program is set on the top level scope, whose variables are looked up
+	// by name and never reported as unused.
+	program bool
+
+	names []string
+	slots map[string]int
+	// declared holds the variables declared so far, and pending those the
+	// scope declares later on.
+	declared map[string]*variable
+	pending  map[string]bool
+	// dynamic holds the names used inside the scope that were not found,
+	// which may refer to its variables once they are declared.
+	dynamic map[string]bool
+
+	variables []*variable
+}
+
+type variable struct {
+	ident *ast.Identifier
+	slot  int
+	used  bool
+	// report is set for variables declared by let statements, which are
+	// reported if they are never used.
+	report bool
+}
+
+type resolver struct {
+	scope *scope
+	diags []diag.Diagnostic
+}
+
+// Resolve sets the bindings of the identifiers of program and the slots of
+// the blocks that begin a scope. It returns warnings about names used
+// before they are defined and variables never used.
+func Resolve(program *ast.Program) []diag.Diagnostic {
+	r := &resolver{}
+
+	r.beginScope(program.Statements)
+	r.scope.program = true
+	r.statements(program.Statements)
+	r.endScope(nil)
+
+	return r.diags
+}
+
+func (r *resolver) beginScope(stmts []ast.Statement) {
+	s := &scope{
+		outer:    r.scope,
+		slots:    map[string]int{},
+		declared: map[string]*variable{},
+		pending:  map[string]bool{},
+		dynamic:  map[string]bool{},
+	}
+	for _, name := range ast.Declarations(stmts) {
+		s.pending[name] = true
+	}
+
+	r.scope = s
+}
+
+// endScope leaves the current scope, which block, if not nil, runs in.
+func (r *resolver) endScope(block *ast.BlockStatement) {
+	s := r.scope
+
+	if block != nil {
+		block.Locals = s.names
+	}
+
+	for _, v := range s.variables {
+		name := v.ident.Value
+		if v.report && !v.used && !s.dynamic[name] && !strings.HasPrefix(name, "_") {
+			r.diags = append(r.diags, diag.Warningf(diag.SpanOf(v.ident), codeUnusedVariable,
+				"unused variable: %s", name))
+		}
+	}
+
+	r.scope = s.outer
+}
+
+// declare declares the variable ident names in the current scope.
+func (r *resolver) declare(ident *ast.Identifier, report bool) {
+	if ident == nil {
+		return
+	}
+
+	s := r.scope
+	name := ident.Value
+	delete(s.pending, name)
+
+	if s.program {
+		ident.Binding = nil
+		s.declared[name] = &variable{ident: ident}
+		return
+	}
+
+	slot, ok := s.slots[name]
+	if !ok {
+		slot = len(s.names)
+		s.slots[name] = slot
+		s.names = append(s.names, name)
+	}
+	ident.Binding = &ast.Binding{Slot: slot}
+
+	v := &variable{ident: ident, slot: slot, report: report}
+	s.declared[name] = v
+	s.variables = append(s.variables, v)
+}
+
+// use resolves ident, a use of a variable.
+func (r *resolver) use(ident *ast.Identifier) {
+	name := ident.Value
+	ident.Binding = nil
+
+	// pending is set once a scope declares name further on. Inside the
+	// function the use is in, that variable is not bound yet when the use
+	// runs, but the function may be called once it is, so past the function
+	// the name is left to be looked up when it is used.
+	pending := false
+	// early is set once a scope of the function declares name further on,
+	// which makes the use fail unless an outer scope has the variable.
+	early := false
+	inFunction := true
+	var passed []*scope
+	depth := 0
+
+	for s := r.scope; s != nil; s = s.outer {
+		if v, ok := s.declared[name]; ok {
+			v.used = true
+			if !pending && !s.program {
+				ident.Binding = &ast.Binding{Depth: depth, Slot: v.slot}
+			}
+			if pending {
+				markDynamic(passed, name)
+			}
+			return
+		}
+
+		if s.pending[name] {
+			if inFunction {
+				early = true
+			} else {
+				pending = true
+			}
+		}
+		passed = append(passed, s)
+
+		if s.function {
+			inFunction = false
+		}
+		depth++
+	}
+
+	markDynamic(passed, name)
+
+	if early {
+		r.diags = append(r.diags, diag.Warningf(diag.SpanOf(ident), codeUseBeforeDefinition,
+			"%s is used before its definition", name))
+	}
+}
+
+// markDynamic records that name, used inside scopes, is looked up by name
+// and so may refer to a variable they declare later on.
+func markDynamic(scopes []*scope, name string) {
+	for _, s := range scopes {
+		s.dynamic[name] = true
+	}
+}
+
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, s := range stmts {
+		r.statement(s)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		// a function may call itself through the variable it is assigned to
+		if _, ok := s.Expression.(*ast.FunctionLiteral); ok {
+			r.declare(s.Name, true)
+			r.expression(s.Expression)
+			return
+		}
+		r.expression(s.Expression)
+		r.declare(s.Name, true)
+	case *ast.ExportStatement:
+		r.statement(s.Statement)
+	case *ast.ImportStatement:
+		// imports bind by name, so their uses are looked up when they run
+		for _, name := range s.BoundNames() {
+			delete(r.scope.pending, name)
+		}
+	case *ast.ReturnStatement:
+		r.expression(s.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(s.Expression)
+	case *ast.AssignStatement:
+		r.expression(s.Value)
+		r.assign(s.Target)
+	case *ast.ThrowStatement:
+		r.expression(s.Value)
+	case *ast.BlockStatement:
+		r.block(s)
+	case *ast.WhileStatement:
+		r.expression(s.Condition)
+		if s.Body != nil {
+			r.beginScope(s.Body.Statements)
+			r.block(s.Body)
+			r.endScope(s.Body)
+		}
+	case *ast.ForStatement:
+		r.expression(s.Iterable)
+		if s.Body != nil {
+			r.beginScope(s.Body.Statements)
+			r.declare(s.Variable, false)
+			r.block(s.Body)
+			r.endScope(s.Body)
+		}
+	}
+}
+
+// assign resolves the target of an assignment, which does not count as a
+// use of a variable it assigns to.
+func (r *resolver) assign(target ast.Expression) {
+	ident, ok := target.(*ast.Identifier)
+	if !ok {
+		r.expression(target)
+		return
+	}
+
+	used := r.isUsed(ident.Value)
+	r.use(ident)
+	if !used {
+		r.unuse(ident.Value)
+	}
+}
+
+func (r *resolver) isUsed(name string) bool {
+	for s := r.scope; s != nil; s = s.outer {
+		if v, ok := s.declared[name]; ok {
+			return v.used
+		}
+	}
+	return false
+}
+
+func (r *resolver) unuse(name string) {
+	for s := r.scope; s != nil; s = s.outer {
+		if v, ok := s.declared[name]; ok {
+			v.used = false
+			return
+		}
+	}
+}
+
+func (r *resolver) block(b *ast.BlockStatement) {
+	if b != nil {
+		r.statements(b.Statements)
+	}
+}
+
+func (r *resolver) expression(expr ast.Expression) {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		if e != nil {
+			r.use(e)
+		}
+	case *ast.PrefixExpression:
+		r.expression(e.Right)
+	case *ast.InfixExpression:
+		r.expression(e.Left)
+		r.expression(e.Right)
+	case *ast.IfExpression:
+		r.expression(e.Condition)
+		r.block(e.Consequence)
+		r.block(e.Alternative)
+	case *ast.TryExpression:
+		r.block(e.Block)
+		if e.Catch != nil {
+			r.beginScope(e.Catch.Statements)
+			r.declare(e.Param, false)
+			r.block(e.Catch)
+			r.endScope(e.Catch)
+		}
+		r.block(e.Finally)
+	case *ast.FunctionLiteral:
+		r.function(e)
+	case *ast.CallExpression:
+		r.expression(e.Function)
+		for _, arg := range e.Arguments {
+			r.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			r.expression(el)
+		}
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			r.expression(key)
+			r.expression(e.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		r.expression(e.Left)
+		r.expression(e.Index)
+	case *ast.SliceExpression:
+		r.expression(e.Left)
+		r.expression(e.Low)
+		r.expression(e.High)
+	case *ast.SelectorExpression:
+		r.expression(e.Left)
+	case *ast.SpreadExpression:
+		r.expression(e.Value)
+	case *ast.KeywordArgument:
+		r.expression(e.Value)
+	case *ast.InterpolatedString:
+		for _, part := range e.Expressions {
+			r.expression(part)
+		}
+	}
+}
+
+// function resolves a function literal. Its parameters are declared before
+// their defaults, which may refer to the parameters before them.
+func (r *resolver) function(fn *ast.FunctionLiteral) {
+	if fn.Body == nil {
+		return
+	}
+
+	r.beginScope(fn.Body.Statements)
+	r.scope.function = true
+
+	for _, param := range fn.Parameters {
+		r.declare(param, false)
+	}
+	r.declare(fn.Rest, false)
+
+	for _, def := range fn.Defaults {
+		r.expression(def)
+	}
+
+	r.block(fn.Body)
+	r.endScope(fn.Body)
+}
@@ -0,0 +1,122 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/parser"
+)
+
+func resolve(t *testing.T, input string) (*ast.Program, []diag.Diagnostic) {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return program, Resolve(program)
+}
+
+// uses returns the bindings of the identifiers called name, in order.
+func uses(program *ast.Program, name string) []*ast.Binding {
+	var bindings []*ast.Binding
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok && ident.Value == name {
+			bindings = append(bindings, ident.Binding)
+		}
+		return true
+	})
+	return bindings
+}
+
+func TestBindings(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		expected []*ast.Binding
+	}{
+		{"let x = 1; x", "x", []*ast.Binding{nil, nil}},
+		{"fn(a, b) { b }", "b", []*ast.Binding{{Slot: 1}, {Slot: 1}}},
+		{"fn(a) { fn() { a } }", "a", []*ast.Binding{{Slot: 0}, {Depth: 1, Slot: 0}}},
+		{"fn() { let x = 1; while (true) { x } }", "x", []*ast.Binding{{Slot: 0}, {Depth: 1, Slot: 0}}},
+		{"fn() { for (i in [1]) { let y = i; y } }", "y", []*ast.Binding{{Slot: 1}, {Slot: 1}}},
+		{"fn() { try { 1 } catch (e) { e } }", "e", []*ast.Binding{{Slot: 0}, {Slot: 0}}},
+		{"fn() { if (true) { let x = 1 }; x }", "x", []*ast.Binding{{Slot: 0}, {Slot: 0}}},
+		// the closure may run once the later x is bound
+		{"fn() { let x = 1; while (true) { let g = fn() { x }; let x = 2 } }", "x",
+			[]*ast.Binding{{Slot: 0}, nil, {Slot: 1}}},
+		{"fn() { let f = fn() { f() } }", "f", []*ast.Binding{{Slot: 0}, {Depth: 1, Slot: 0}}},
+	}
+
+	for _, tt := range tests {
+		program, _ := resolve(t, tt.input)
+
+		got := uses(program, tt.name)
+		if len(got) != len(tt.expected) {
+			t.Errorf("%q: wrong number of identifiers. want=%d, got=%d", tt.input, len(tt.expected), len(got))
+			continue
+		}
+
+		for i, want := range tt.expected {
+			switch {
+			case want == nil && got[i] != nil:
+				t.Errorf("%q: identifier %d has binding %+v, want none", tt.input, i, *got[i])
+			case want != nil && got[i] == nil:
+				t.Errorf("%q: identifier %d has no binding, want %+v", tt.input, i, *want)
+			case want != nil && *got[i] != *want:
+				t.Errorf("%q: identifier %d has binding %+v, want %+v", tt.input, i, *got[i], *want)
+			}
+		}
+	}
+}
+
+func TestLocals(t *testing.T) {
+	program, _ := resolve(t, "fn(a, ...rest) { let b = a; if (true) { let c = b }; c }")
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if got := strings.Join(fn.Body.Locals, ","); got != "a,rest,b,c" {
+		t.Errorf("wrong locals. got=%q", got)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; x", nil},
+		{"puts(x); let x = 1", []string{"1:6: x is used before its definition"}},
+		{"let x = 1; fn() { puts(x); let x = 2; x }", nil},
+		{"fn() { puts(y); let y = 2; y }", []string{"1:13: y is used before its definition"}},
+		{"let f = fn() { g() }; let g = fn() { 1 }", nil},
+		{"fn() { let unused = 1 }", []string{"1:12: unused variable: unused"}},
+		{"fn() { let _ = 1 }", nil},
+		{"fn() { let n = 0; n = 1 }", []string{"1:12: unused variable: n"}},
+		{"fn() { let n = 0; n += 1; n }", nil},
+		{"fn(a) { for (i in [1]) { 1 }; try { 1 } catch (e) { 2 } }", nil},
+		{"fn() { let f = fn() { g() }; let g = fn() { 1 }; f }", nil},
+		{`import "lib/math"; math`, nil},
+		{`puts(m); import { m } from "lib"`, []string{"1:6: m is used before its definition"}},
+	}
+
+	for _, tt := range tests {
+		_, diags := resolve(t, tt.input)
+
+		var got []string
+		for _, d := range diags {
+			if d.Severity != diag.Warning {
+				t.Errorf("%q: diagnostic %q is not a warning", tt.input, d.Error())
+			}
+			got = append(got, d.Error())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong diagnostics.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package token
+
+import "fmt"
+
+// Position describes a location in a source file. Line and Column are
+// 1-based, Offset is the 0-based byte offset into the input.
+type Position struct {
+	Filename string `json:"file,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// IsValid reports whether the position has been set by the lexer.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
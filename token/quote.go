@@ -0,0 +1,11 @@
+package token
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Quote returns s as a double quoted string literal that lexes back to s.
+func Quote(s string) string {
+	return strings.Replace(strconv.Quote(s), "${", `\${`, -1)
+}
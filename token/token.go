@@ -8,9 +8,21 @@ const (
 	IDENT  = "IDENT"  // add, foobar, x, y, ...
 	INT    = "INT"    // 1343456
 	STRING = "STRING" // "hello world"
+	FLOAT  = "FLOAT"  // 3.14, 1e-9, .5
+
+	// Interpolated strings such as "a ${x} b ${y} c" are split around their
+	// expressions into a head `"a ${`, middles `} b ${` and a tail `} c"`.
+	STRING_HEAD = "STRING_HEAD"
+	STRING_MID  = "STRING_MID"
+	STRING_TAIL = "STRING_TAIL"
 
 	// Operators
-	ASSIGN   = "="
+	ASSIGN          = "="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	PLUS     = "+"
 	BANG     = "!"
 	GT       = ">"
@@ -18,8 +30,27 @@ const (
 	MINUS    = "-"
 	SLASH    = "/"
 	ASTERISK = "*"
+	PERCENT  = "%"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	LT_EQ    = "<="
+	GT_EQ    = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	TILDE     = "~"
+	SHL       = "<<"
+	SHR       = ">>"
+
+	RANGE           = ".."
+	RANGE_EXCLUSIVE = "..<"
+	ELLIPSIS        = "..."
+
+	ARROW = "->" // the result type of a function, `fn() -> int`
 
 	// Delimiters
 	Q
@@ -44,16 +75,38 @@ const (
 	TRUE     = "true"
 	FALSE    = "false"
 	ELSE     = "else"
+	WHILE    = "while"
+	FOR      = "for"
+	IN       = "in"
+	BREAK    = "break"
+	CONTINUE = "continue"
+	IMPORT   = "import"
+	EXPORT   = "export"
+	TRY      = "try"
+	CATCH    = "catch"
+	FINALLY  = "finally"
+	THROW    = "throw"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
-	"else":   ELSE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"else":     ELSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
 }
 
 type TokenType string
@@ -61,6 +114,22 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+
+	// Pos is the position of the first character of the token and End the
+	// position immediately after its last character.
+	Pos Position
+	End Position
+
+	// Comments holds the comments preceding the token when the lexer was asked
+	// to keep them.
+	Comments []Comment
+}
+
+// Comment is a `// line` or `/* block */` comment, including its delimiters.
+type Comment struct {
+	Text string
+	Pos  Position
+	End  Position
 }
 
 func LookupIndentifier(ident string) TokenType {
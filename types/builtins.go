@@ -0,0 +1,44 @@
+package types
+
+// builtins holds the types of the builtin functions.
+var builtins = map[string]*Function{
+	"len":     {Parameters: []Type{Any}, Required: 1, Result: Int},
+	"byteLen": {Parameters: []Type{String}, Required: 1, Result: Int},
+	"byteAt":  {Parameters: []Type{String, Int}, Required: 2, Result: Any},
+	"int":     {Parameters: []Type{Any}, Required: 1, Result: Int},
+	"float":   {Parameters: []Type{Any}, Required: 1, Result: Float},
+	"array":   {Parameters: []Type{Any}, Required: 1, Result: &Array{Element: Any}},
+	"error":   {Parameters: []Type{String, String}, Required: 1, Result: Error},
+	"isNull":  {Parameters: []Type{Any}, Required: 1, Result: Bool},
+	"println": {Rest: Any, Result: Null},
+	"first":   {Parameters: []Type{&Array{Element: Any}}, Required: 1, Result: Any},
+	"last":    {Parameters: []Type{&Array{Element: Any}}, Required: 1, Result: Any},
+	"rest":    {Parameters: []Type{&Array{Element: Any}}, Required: 1, Result: Any},
+	"push":    {Parameters: []Type{&Array{Element: Any}, Any}, Required: 2, Result: &Array{Element: Any}},
+}
+
+// builtinResult returns the type of the result of a call of the builtin
+// called name with arguments of the given types, for the builtins whose
+// result depends on them.
+func builtinResult(name string, args []Type) (Type, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, false
+	}
+
+	switch name {
+	case "first", "last":
+		return arr.Element, true
+	case "rest":
+		return arr, true
+	case "push":
+		if len(args) == 2 {
+			return &Array{Element: join(arr.Element, args[1])}, true
+		}
+	}
+	return nil, false
+}
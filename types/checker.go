@@ -0,0 +1,832 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/diag"
+)
+
+// Diagnostic codes reported by the checker.
+const (
+	codeUnknownType      = "T0001"
+	codeInvalidOperation = "T0002"
+	codeMismatchedType   = "T0003"
+	codeWrongArguments   = "T0004"
+	codeNonBoolCondition = "T0005"
+)
+
+type variable struct {
+	typ Type
+	// annotated is set for variables with a type annotation, which may only
+	// be assigned values of that type.
+	annotated bool
+	decl      *ast.Identifier
+}
+
+// A scope begins where the evaluator creates an environment: at the top
+// level of a program, a function, a loop body or a catch clause.
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+}
+
+// function is a function literal being checked.
+type function struct {
+	outer *function
+	// result is the annotated result type, or nil if it is inferred.
+	result Type
+	// returns holds the types of the values its return statements return.
+	returns []Type
+}
+
+type checker struct {
+	scope *scope
+	fn    *function
+	diags []diag.Diagnostic
+
+	// widened holds the unannotated variables assigned a value of another
+	// type than the one they were declared with, which are given type any
+	// from their declaration on.
+	widened map[*ast.Identifier]bool
+	changed bool
+}
+
+// Check infers the types of the expressions of program and returns errors
+// for the operations and annotations they violate.
+func Check(program *ast.Program) []diag.Diagnostic {
+	c := &checker{widened: map[*ast.Identifier]bool{}}
+
+	// an assignment may change the type of a variable used before it, in a
+	// loop or a function, so check again until no variable changes type
+	for {
+		c.diags = nil
+		c.changed = false
+
+		c.beginScope()
+		c.statements(program.Statements)
+		c.endScope()
+
+		if !c.changed {
+			return c.diags
+		}
+	}
+}
+
+func (c *checker) errorf(node ast.Node, code string, format string, a ...interface{}) {
+	c.diags = append(c.diags, diag.Errorf(diag.SpanOf(node), code, format, a...))
+}
+
+// expect reports an error unless a value of type t, computed by node, may be
+// used where a value of type want is expected.
+func (c *checker) expect(node ast.Node, t, want Type, context string) {
+	if !AssignableTo(t, want) {
+		c.errorf(node, codeMismatchedType, "cannot use %s as %s in %s", t, want, context)
+	}
+}
+
+func (c *checker) beginScope() {
+	c.scope = &scope{outer: c.scope, vars: map[string]*variable{}}
+}
+
+func (c *checker) endScope() {
+	c.scope = c.scope.outer
+}
+
+func (c *checker) declare(ident *ast.Identifier, t Type, annotated bool) *variable {
+	if !annotated && c.widened[ident] {
+		t = Any
+	}
+
+	v := &variable{typ: t, annotated: annotated, decl: ident}
+	c.scope.vars[ident.Value] = v
+	return v
+}
+
+func (c *checker) lookup(name string) *variable {
+	for s := c.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// widen gives v, which was assigned a value of type t, a type it may hold.
+func (c *checker) widen(v *variable, t Type) {
+	if v.annotated || AssignableTo(t, v.typ) {
+		return
+	}
+
+	v.typ = Any
+	if !c.widened[v.decl] {
+		c.widened[v.decl] = true
+		c.changed = true
+	}
+}
+
+// typeOf returns the type an annotation denotes.
+func (c *checker) typeOf(te ast.TypeExpression) Type {
+	switch te := te.(type) {
+	case *ast.NamedType:
+		if t, ok := basics[te.Name]; ok {
+			return t
+		}
+		c.errorf(te, codeUnknownType, "unknown type: %s", te.Name)
+	case *ast.ArrayType:
+		return &Array{Element: c.typeOf(te.Element)}
+	case *ast.HashType:
+		return &Hash{Key: c.typeOf(te.Key), Value: c.typeOf(te.Value)}
+	case *ast.FunctionType:
+		f := &Function{Result: Any}
+		for _, p := range te.Parameters {
+			f.Parameters = append(f.Parameters, c.typeOf(p))
+		}
+		f.Required = len(f.Parameters)
+		if te.Rest != nil {
+			f.Rest = c.typeOf(te.Rest)
+		}
+		if te.Result != nil {
+			f.Result = c.typeOf(te.Result)
+		}
+		return f
+	}
+	return Any
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for _, s := range stmts {
+		c.statement(s)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(s)
+	case *ast.ExportStatement:
+		c.let(s.Statement)
+	case *ast.ImportStatement:
+		if s.Alias != nil {
+			c.declare(s.Alias, Any, false)
+		}
+		for _, name := range s.Names {
+			c.declare(name, Any, false)
+		}
+	case *ast.ReturnStatement:
+		c.returnStatement(s)
+	case *ast.ExpressionStatement:
+		c.expression(s.Expression)
+	case *ast.AssignStatement:
+		c.assign(s)
+	case *ast.ThrowStatement:
+		c.expression(s.Value)
+	case *ast.BlockStatement:
+		c.block(s)
+	case *ast.WhileStatement:
+		c.condition(s.Condition, "while")
+		c.beginScope()
+		c.block(s.Body)
+		c.endScope()
+	case *ast.ForStatement:
+		element := c.element(s.Iterable, c.expression(s.Iterable))
+		c.beginScope()
+		if s.Variable != nil {
+			c.declare(s.Variable, element, false)
+		}
+		c.block(s.Body)
+		c.endScope()
+	}
+}
+
+func (c *checker) let(s *ast.LetStatement) {
+	if s == nil || s.Name == nil {
+		return
+	}
+
+	var want Type
+	if s.Type != nil {
+		want = c.typeOf(s.Type)
+	}
+	context := "declaration of " + s.Name.Value
+
+	// a function may call itself through the variable it is assigned to
+	if fl, ok := s.Expression.(*ast.FunctionLiteral); ok {
+		sig := c.signature(fl)
+		v := c.declare(s.Name, sig, false)
+		if want != nil {
+			v.typ, v.annotated = want, true
+		}
+
+		t := c.function(fl, sig)
+		if want != nil {
+			c.expect(fl, t, want, context)
+		} else if v.typ == sig {
+			v.typ = t
+		}
+		return
+	}
+
+	if want == nil {
+		t := Type(Null)
+		if s.Expression != nil {
+			t = c.expression(s.Expression)
+		}
+		c.declare(s.Name, t, false)
+		return
+	}
+
+	if s.Expression != nil {
+		c.value(s.Expression, want, context)
+	}
+	c.declare(s.Name, want, true)
+}
+
+func (c *checker) assign(s *ast.AssignStatement) {
+	t := c.expression(s.Value)
+
+	ident, ok := s.Target.(*ast.Identifier)
+	if !ok {
+		target := c.expression(s.Target)
+		if s.Operator != "=" {
+			c.infix(s, strings.TrimSuffix(s.Operator, "="), target, t)
+		}
+		return
+	}
+
+	v := c.lookup(ident.Value)
+	if v == nil {
+		return
+	}
+
+	if s.Operator != "=" {
+		t = c.infix(s, strings.TrimSuffix(s.Operator, "="), v.typ, t)
+	}
+
+	if v.annotated {
+		c.expect(s.Value, t, v.typ, "assignment to "+ident.Value)
+		return
+	}
+	c.widen(v, t)
+}
+
+func (c *checker) returnStatement(s *ast.ReturnStatement) {
+	t := Type(Null)
+	if s.ReturnValue != nil {
+		t = c.expression(s.ReturnValue)
+	}
+
+	if c.fn == nil {
+		return
+	}
+
+	if c.fn.result != nil {
+		c.expect(s, t, c.fn.result, "return statement")
+	}
+	c.fn.returns = append(c.fn.returns, t)
+}
+
+// condition checks the condition of an if expression or a while loop, which
+// is only met by true.
+func (c *checker) condition(cond ast.Expression, what string) {
+	if t := c.expression(cond); t != Any && t != Bool {
+		c.errorf(cond, codeNonBoolCondition, "%s condition must be bool, got %s", what, t)
+	}
+}
+
+// block checks the statements of b and returns the type of the value it
+// evaluates to, or nil if it always returns before its end.
+func (c *checker) block(b *ast.BlockStatement) Type {
+	if b == nil || len(b.Statements) == 0 {
+		return Null
+	}
+
+	last := len(b.Statements) - 1
+	c.statements(b.Statements[:last])
+
+	switch s := b.Statements[last].(type) {
+	case *ast.ExpressionStatement:
+		if ie, ok := s.Expression.(*ast.IfExpression); ok {
+			return c.ifExpression(ie)
+		}
+		return c.expression(s.Expression)
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		c.statement(s)
+		return nil
+	default:
+		c.statement(s)
+		return Any
+	}
+}
+
+// value checks expr, whose value is used where one of type want is
+// expected, and returns its type. The elements of an array literal are each
+// checked against the element type, as their joined type loses which of
+// them does not fit.
+func (c *checker) value(expr ast.Expression, want Type, context string) Type {
+	al, ok := expr.(*ast.ArrayLiteral)
+	array, isArray := want.(*Array)
+	if !ok || !isArray {
+		t := c.expression(expr)
+		c.expect(expr, t, want, context)
+		return t
+	}
+
+	var t Type
+	for _, el := range al.Elements {
+		if spread, ok := el.(*ast.SpreadExpression); ok {
+			et := c.element(spread.Value, c.expression(spread.Value))
+			c.expect(spread.Value, et, array.Element, context)
+			t = join(t, et)
+			continue
+		}
+		t = join(t, c.value(el, array.Element, context))
+	}
+	if t == nil {
+		return &Array{Element: Any}
+	}
+	return &Array{Element: t}
+}
+
+func (c *checker) expression(expr ast.Expression) Type {
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.BooleanLiteral:
+		return Bool
+	case *ast.InterpolatedString:
+		for _, part := range e.Expressions {
+			c.expression(part)
+		}
+		return String
+	case *ast.Identifier:
+		if v := c.lookup(e.Value); v != nil {
+			return v.typ
+		}
+		if f, ok := builtins[e.Value]; ok {
+			return f
+		}
+	case *ast.PrefixExpression:
+		return c.prefix(e, c.expression(e.Right))
+	case *ast.InfixExpression:
+		return c.infix(e, e.Operator, c.expression(e.Left), c.expression(e.Right))
+	case *ast.IfExpression:
+		if t := c.ifExpression(e); t != nil {
+			return t
+		}
+	case *ast.TryExpression:
+		t := c.block(e.Block)
+		if e.Catch != nil {
+			c.beginScope()
+			if e.Param != nil {
+				c.declare(e.Param, Any, false)
+			}
+			t = join(t, c.block(e.Catch))
+			c.endScope()
+		}
+		c.block(e.Finally)
+		if t != nil {
+			return t
+		}
+	case *ast.FunctionLiteral:
+		return c.function(e, c.signature(e))
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.ArrayLiteral:
+		return &Array{Element: c.elements(e.Elements)}
+	case *ast.HashLiteral:
+		var key, value Type
+		for _, k := range e.Keys {
+			key = join(key, c.expression(k))
+			value = join(value, c.expression(e.Pairs[k]))
+		}
+		if key == nil {
+			return &Hash{Key: Any, Value: Any}
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.IndexExpression:
+		return c.index(e, c.expression(e.Left), c.expression(e.Index))
+	case *ast.SliceExpression:
+		return c.slice(e)
+	case *ast.SelectorExpression:
+		c.expression(e.Left)
+	case *ast.SpreadExpression:
+		c.expression(e.Value)
+	case *ast.KeywordArgument:
+		c.expression(e.Value)
+	}
+	return Any
+}
+
+// ifExpression returns the type of the value of ie, or nil if both of its
+// branches return.
+func (c *checker) ifExpression(ie *ast.IfExpression) Type {
+	c.condition(ie.Condition, "if")
+
+	t := c.block(ie.Consequence)
+	if ie.Alternative == nil {
+		return join(t, Null)
+	}
+	return join(t, c.block(ie.Alternative))
+}
+
+func (c *checker) prefix(pe *ast.PrefixExpression, right Type) Type {
+	if right == Any {
+		if pe.Operator == "!" {
+			return Bool
+		}
+		return Any
+	}
+
+	switch pe.Operator {
+	case "!":
+		if right == Bool {
+			return Bool
+		}
+	case "-":
+		if isNumber(right) {
+			return right
+		}
+	case "~":
+		if right == Int {
+			return Int
+		}
+	}
+
+	c.errorf(pe, codeInvalidOperation, "unknown operator: %s%s", pe.Operator, right)
+	return Any
+}
+
+// infix returns the type of `left operator right`, computed by node, with
+// the same rules the evaluator applies to values.
+func (c *checker) infix(node ast.Node, operator string, left, right Type) Type {
+	comparison := false
+	switch operator {
+	case "==", "!=", "<", ">", "<=", ">=":
+		comparison = true
+	}
+
+	if left == Any || right == Any {
+		switch {
+		case comparison, operator == "&&", operator == "||":
+			return Bool
+		case operator == ".." || operator == "..<":
+			return Range
+		}
+		return Any
+	}
+
+	// an integer mixed with a float is promoted to a float
+	if isNumber(left) && isNumber(right) && (left == Float || right == Float) {
+		switch {
+		case comparison:
+			return Bool
+		case operator == "+", operator == "-", operator == "*", operator == "/", operator == "%":
+			return Float
+		}
+		c.errorf(node, codeInvalidOperation, "unknown operator: %s %s %s", left, operator, right)
+		return Any
+	}
+
+	if !Identical(left, right) {
+		c.errorf(node, codeInvalidOperation, "type mismatch: %s %s %s", left, operator, right)
+		return Any
+	}
+
+	switch left {
+	case Int:
+		switch operator {
+		case "+", "-", "*", "/", "%", "&", "|", "^", "<<", ">>":
+			return Int
+		case "..", "..<":
+			return Range
+		}
+		if comparison {
+			return Bool
+		}
+	case Bool:
+		switch operator {
+		case "==", "!=", "&&", "||":
+			return Bool
+		}
+	case String:
+		if operator == "+" {
+			return String
+		}
+		if comparison {
+			return Bool
+		}
+	default:
+		c.errorf(node, codeInvalidOperation, "type mismatch: %s %s %s", left, operator, right)
+		return Any
+	}
+
+	c.errorf(node, codeInvalidOperation, "unknown operator: %s %s %s", left, operator, right)
+	return Any
+}
+
+// elements returns the type of the elements of an array literal.
+func (c *checker) elements(exprs []ast.Expression) Type {
+	var t Type
+	for _, el := range exprs {
+		if spread, ok := el.(*ast.SpreadExpression); ok {
+			t = join(t, c.element(spread.Value, c.expression(spread.Value)))
+			continue
+		}
+		t = join(t, c.expression(el))
+	}
+	if t == nil {
+		return Any
+	}
+	return t
+}
+
+// element returns the type of the values a for loop over a value of type t,
+// computed by node, visits.
+func (c *checker) element(node ast.Node, t Type) Type {
+	switch t := t.(type) {
+	case *Array:
+		return t.Element
+	case *Hash:
+		return t.Key
+	}
+
+	switch t {
+	case Any:
+		return Any
+	case String:
+		return String
+	case Range:
+		return Int
+	}
+
+	c.errorf(node, codeInvalidOperation, "cannot iterate over %s", t)
+	return Any
+}
+
+func (c *checker) index(ie *ast.IndexExpression, left, index Type) Type {
+	var element Type
+	switch l := left.(type) {
+	case *Array:
+		element = l.Element
+	case *Hash:
+		return l.Value
+	}
+
+	switch left {
+	case Any:
+		return Any
+	case String:
+		element = String
+	case Range:
+		element = Int
+	}
+
+	if element == nil {
+		c.errorf(ie, codeInvalidOperation, "cannot index %s", left)
+		return Any
+	}
+
+	if index != Any && index != Int {
+		c.errorf(ie.Index, codeInvalidOperation, "cannot index %s with %s", left, index)
+	}
+	return element
+}
+
+func (c *checker) slice(se *ast.SliceExpression) Type {
+	left := c.expression(se.Left)
+
+	for _, bound := range []ast.Expression{se.Low, se.High} {
+		if bound == nil {
+			continue
+		}
+		if t := c.expression(bound); t != Any && t != Int {
+			c.errorf(bound, codeInvalidOperation, "cannot slice %s with %s", left, t)
+		}
+	}
+
+	if _, ok := left.(*Array); ok {
+		return left
+	}
+
+	switch left {
+	case Any, String, Range:
+		return left
+	}
+
+	c.errorf(se, codeInvalidOperation, "cannot slice %s", left)
+	return Any
+}
+
+// signature returns the type of fl as far as its annotations tell, with a
+// result of type any unless it is annotated.
+func (c *checker) signature(fl *ast.FunctionLiteral) *Function {
+	f := &Function{Result: Any}
+
+	for i, p := range fl.Parameters {
+		t := Type(Any)
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			t = c.typeOf(fl.ParameterTypes[i])
+		}
+		f.Parameters = append(f.Parameters, t)
+		f.Names = append(f.Names, p.Value)
+
+		if i >= len(fl.Defaults) || fl.Defaults[i] == nil {
+			f.Required++
+		}
+	}
+
+	if fl.Rest != nil {
+		f.Rest = Any
+		if fl.RestType != nil {
+			f.Rest = c.typeOf(fl.RestType)
+		}
+	}
+
+	if fl.ReturnType != nil {
+		f.Result = c.typeOf(fl.ReturnType)
+	}
+
+	return f
+}
+
+// function checks the body of fl, whose annotations sig describes, and
+// returns its type. Without an annotation the result is inferred from the
+// values the body returns.
+func (c *checker) function(fl *ast.FunctionLiteral, sig *Function) *Function {
+	fn := &function{outer: c.fn}
+	if fl.ReturnType != nil {
+		fn.result = sig.Result
+	}
+	c.fn = fn
+	c.beginScope()
+	defer func() {
+		c.endScope()
+		c.fn = fn.outer
+	}()
+
+	for i, p := range fl.Parameters {
+		annotated := i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil
+		c.declare(p, sig.Parameters[i], annotated)
+	}
+	if fl.Rest != nil {
+		c.declare(fl.Rest, &Array{Element: sig.Rest}, fl.RestType != nil)
+	}
+
+	for i, def := range fl.Defaults {
+		if def != nil {
+			c.value(def, sig.Parameters[i], "default of "+fl.Parameters[i].Value)
+		}
+	}
+
+	t := c.block(fl.Body)
+
+	if fn.result != nil {
+		var last ast.Statement
+		if fl.Body != nil && len(fl.Body.Statements) > 0 {
+			last = fl.Body.Statements[len(fl.Body.Statements)-1]
+		}
+
+		// a body that is empty or ends in a declaration or an assignment
+		// gives null
+		switch last.(type) {
+		case nil:
+			c.expect(fl, Null, fn.result, "result of function")
+		case *ast.LetStatement, *ast.AssignStatement:
+			c.expect(last, Null, fn.result, "result of function")
+		default:
+			if t != nil {
+				c.expect(last, t, fn.result, "result of function")
+			}
+		}
+		return sig
+	}
+
+	for _, r := range fn.returns {
+		t = join(t, r)
+	}
+	if t == nil {
+		t = Any
+	}
+
+	result := *sig
+	result.Result = t
+	return &result
+}
+
+func (c *checker) call(ce *ast.CallExpression) Type {
+	callee := c.expression(ce.Function)
+
+	f, ok := callee.(*Function)
+	if !ok {
+		for _, arg := range ce.Arguments {
+			c.expression(arg)
+		}
+		if callee != Any {
+			c.errorf(ce.Function, codeInvalidOperation, "not a function: %s", callee)
+		}
+		return Any
+	}
+
+	args := c.arguments(ce, f)
+
+	if ident, ok := ce.Function.(*ast.Identifier); ok && c.lookup(ident.Value) == nil {
+		if result, ok := builtinResult(ident.Value, args); ok {
+			return result
+		}
+	}
+
+	return f.Result
+}
+
+// arguments checks the arguments of a call of f and returns the types of
+// its positional arguments.
+func (c *checker) arguments(ce *ast.CallExpression, f *Function) []Type {
+	var positional []Type
+	var keywords []*ast.KeywordArgument
+	// unmatched is set once the arguments cannot be matched to parameters:
+	// after a spread, or a keyword argument that was already reported
+	unmatched := false
+	bound := make([]bool, len(f.Parameters))
+
+	for _, arg := range ce.Arguments {
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			c.expression(arg.Value)
+			unmatched = true
+		case *ast.KeywordArgument:
+			keywords = append(keywords, arg)
+			i := -1
+			if f.Names != nil {
+				i = indexOf(f.Names, arg.Name.Value)
+			}
+			switch {
+			case f.Names == nil:
+				c.expression(arg.Value)
+			case i < 0:
+				c.expression(arg.Value)
+				c.errorf(arg, codeWrongArguments, "unexpected keyword argument: %s", arg.Name.Value)
+				unmatched = true
+			case bound[i]:
+				c.expression(arg.Value)
+				c.errorf(arg, codeWrongArguments, "multiple values for argument: %s", arg.Name.Value)
+				unmatched = true
+			default:
+				bound[i] = true
+				c.value(arg.Value, f.Parameters[i], "argument "+arg.Name.Value)
+			}
+		default:
+			if unmatched {
+				c.expression(arg)
+				continue
+			}
+			n := len(positional)
+			if n < len(bound) {
+				bound[n] = true
+			}
+			positional = append(positional, c.value(arg, f.parameter(n), fmt.Sprintf("argument %d", n+1)))
+		}
+	}
+
+	if unmatched || (f.Names == nil && len(keywords) > 0) {
+		return positional
+	}
+
+	missing := false
+	for i := 0; i < f.Required; i++ {
+		missing = missing || !bound[i]
+	}
+	if missing || (len(positional) > len(f.Parameters) && f.Rest == nil) {
+		c.errorf(ce, codeWrongArguments, "wrong number of arguments. got=%d, want=%s",
+			len(positional)+len(keywords), arity(f))
+	}
+
+	return positional
+}
+
+// arity describes how many arguments f accepts, e.g. "2", "1..3" or
+// "at least 1".
+func arity(f *Function) string {
+	switch {
+	case f.Rest != nil:
+		return fmt.Sprintf("at least %d", f.Required)
+	case f.Required == len(f.Parameters):
+		return fmt.Sprintf("%d", f.Required)
+	default:
+		return fmt.Sprintf("%d..%d", f.Required, len(f.Parameters))
+	}
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
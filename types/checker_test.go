@@ -0,0 +1,132 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/threeaccents/digolang/diag"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/parser"
+)
+
+func check(t *testing.T, input string) []diag.Diagnostic {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Check(program)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x: int = 1; x + 2", nil},
+		{"1 + \"a\"", []string{"1:1: type mismatch: int + string"}},
+		{"1 + 2.5; 1 < 2.5; \"a\" + \"b\"; 1..3", nil},
+		{"\"a\" - \"b\"", []string{"1:1: unknown operator: string - string"}},
+		{"-true", []string{"1:1: unknown operator: -bool"}},
+		{"[1] == [1]", []string{"1:1: type mismatch: [int] == [int]"}},
+		{"let x: string = 1", []string{"1:17: cannot use int as string in declaration of x"}},
+		{"let x: float = 1", nil},
+		{"let x: int = 1.5", []string{"1:14: cannot use float as int in declaration of x"}},
+		{"let x: [int] = [1, 2]; let y: [string] = x", []string{"1:42: cannot use [int] as [string] in declaration of y"}},
+		{"let h: {string: int} = {\"a\": 1}; h[\"a\"] + 1", nil},
+		{"let xs: [int] = []; xs[0] + \"a\"", []string{"1:21: type mismatch: int + string"}},
+		{"let x: number = 1", []string{"1:8: unknown type: number"}},
+		{"let x: int = 1; x = \"a\"", []string{"1:21: cannot use string as int in assignment to x"}},
+		{"let x = 1; x = \"a\"; x + 1", nil},
+		{"let x = 1; let f = fn() { x + 1 }; x = \"a\"", nil},
+		{"let s = \"a\"; s += 1", []string{"1:14: type mismatch: string + int"}},
+		{"let x = 1; if (x) { 2 }", []string{"1:16: if condition must be bool, got int"}},
+		{"while (\"a\") { 1 }", []string{"1:8: while condition must be bool, got string"}},
+		{"let f = fn(a: string, b: [int]) -> bool { len(b) > len(a) }; f(\"a\", [1])", nil},
+		{"let f = fn(a: string) { a }; f(1)", []string{"1:32: cannot use int as string in argument 1"}},
+		{"let f = fn(a: string) { a }; f(\"a\") + 1", []string{"1:30: type mismatch: string + int"}},
+		{"let f = fn(a, b = 1) { a }; f(); f(1, 2, 3)", []string{
+			"1:29: wrong number of arguments. got=0, want=1..2",
+			"1:34: wrong number of arguments. got=3, want=1..2",
+		}},
+		{"let f = fn(a: int, ...rest: string) { rest }; f(1, \"a\", 2)", []string{"1:57: cannot use int as string in argument 3"}},
+		{"let f = fn(a, b: int) { a }; f(1, b = \"x\"); f(1, c = 2)", []string{
+			"1:39: cannot use string as int in argument b",
+			"1:50: unexpected keyword argument: c",
+		}},
+		{"let f = fn() -> int { \"a\" }", []string{"1:23: cannot use string as int in result of function"}},
+		{"let g = fn() -> int { }", []string{"1:9: cannot use null as int in result of function"}},
+		{"let g = fn() -> int { let x = 1 }", []string{"1:23: cannot use null as int in result of function"}},
+		{"let g = fn() -> int { return 1 }; let h = fn() { }", nil},
+		{"let arr: [int] = [1, \"a\"]", []string{"1:22: cannot use string as int in declaration of arr"}},
+		{"let x: [[int]] = [[1], [\"a\"]]", []string{"1:25: cannot use string as int in declaration of x"}},
+		{"let f = fn(xs: [int], ys: [int] = [\"b\"]) { xs }; f([1, \"a\"]); f(xs = [2, \"c\"])", []string{
+			"1:36: cannot use string as int in default of ys",
+			"1:56: cannot use string as int in argument 1",
+			"1:74: cannot use string as int in argument xs",
+		}},
+		{"let x: [float] = [1, 2.5, ...[3]]; let y: [int] = [...[\"a\"]]", []string{"1:55: cannot use string as int in declaration of y"}},
+		{"let f = fn(n: int) -> int { if (n < 1) { return \"a\" }; n }", []string{"1:42: cannot use string as int in return statement"}},
+		{"let fib = fn(n: int) -> int { if (n < 2) { return n }; fib(n - 1) + fib(n - 2) }; fib(\"a\")", []string{"1:87: cannot use string as int in argument 1"}},
+		{"let f = fn(n) { return n > 1 }; f(1) + 1", []string{"1:33: type mismatch: bool + int"}},
+		{"let g: fn(int) -> int = fn(x: int) -> int { x }; g = fn(s: string) { s }", []string{
+			"1:54: cannot use fn(string) -> string as fn(int) -> int in assignment to g",
+		}},
+		{"let x = 1; x()", []string{"1:12: not a function: int"}},
+		{"for (c in \"abc\") { c + 1 }", []string{"1:20: type mismatch: string + int"}},
+		{"for (i in 5) { i }", []string{"1:11: cannot iterate over int"}},
+		{"let xs = [1, 2]; xs[\"a\"]", []string{"1:21: cannot index [int] with string"}},
+		{"first([1, 2]) + \"a\"; len(\"abc\") + 1; push([1], 2)[0] - 1", []string{"1:1: type mismatch: int + string"}},
+		{"let x = try { 1 } catch (e) { e.message }; x + 1", nil},
+		{"let x = if (true) { 1 } else { 2 }; x + \"a\"", []string{"1:37: type mismatch: int + string"}},
+		{"unknown + 1; let any = fn(x) { x }; any(1) + any(\"a\")", nil},
+	}
+
+	for _, tt := range tests {
+		diags := check(t, tt.input)
+
+		var got []string
+		for _, d := range diags {
+			if d.Severity != diag.Error {
+				t.Errorf("%q: diagnostic %q is not an error", tt.input, d.Error())
+			}
+			got = append(got, d.Error())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong diagnostics.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignableTo(t *testing.T) {
+	fn := func(params []Type, required int, result Type) *Function {
+		return &Function{Parameters: params, Required: required, Result: result}
+	}
+
+	tests := []struct {
+		from, to Type
+		expected bool
+	}{
+		{Int, Any, true},
+		{Any, &Array{Element: String}, true},
+		{Int, Float, true},
+		{Float, Int, false},
+		{&Array{Element: Int}, &Array{Element: Any}, true},
+		{&Hash{Key: String, Value: Int}, &Hash{Key: String, Value: String}, false},
+		{fn([]Type{Any}, 1, Int), fn([]Type{String}, 1, Int), true},
+		{fn([]Type{Int, Int}, 1, Int), fn([]Type{Int}, 1, Any), true},
+		{fn([]Type{Int, Int}, 2, Int), fn([]Type{Int}, 1, Any), false},
+		{fn([]Type{Int}, 1, String), fn([]Type{Int}, 1, Int), false},
+		{Null, Int, false},
+	}
+
+	for _, tt := range tests {
+		if got := AssignableTo(tt.from, tt.to); got != tt.expected {
+			t.Errorf("AssignableTo(%s, %s) = %t, want %t", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
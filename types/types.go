@@ -0,0 +1,170 @@
+// Package types checks the type annotations of a program before it runs.
+//
+// Typing is gradual: a variable or parameter without an annotation gets the
+// type of the value it is given where that is known and `any` otherwise, and
+// `any` is compatible with every type in both directions. Only operations on
+// values whose types are known are checked, so an unannotated program never
+// fails to check unless it would fail when run.
+package types
+
+import (
+	"strings"
+)
+
+// Type is the static type of a value.
+type Type interface {
+	String() string
+}
+
+// Basic is a type without components, such as int.
+type Basic struct {
+	Name string
+}
+
+func (b *Basic) String() string { return b.Name }
+
+var (
+	Any    = &Basic{Name: "any"}
+	Int    = &Basic{Name: "int"}
+	Float  = &Basic{Name: "float"}
+	String = &Basic{Name: "string"}
+	Bool   = &Basic{Name: "bool"}
+	Null   = &Basic{Name: "null"}
+	Range  = &Basic{Name: "range"}
+	Error  = &Basic{Name: "error"}
+)
+
+// basics are the types annotations may refer to by name.
+var basics = map[string]Type{
+	"any":    Any,
+	"int":    Int,
+	"float":  Float,
+	"string": String,
+	"bool":   Bool,
+	"null":   Null,
+	"range":  Range,
+	"error":  Error,
+}
+
+// Array is `[Element]`.
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return "[" + a.Element.String() + "]" }
+
+// Hash is `{Key: Value}`.
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string { return "{" + h.Key.String() + ": " + h.Value.String() + "}" }
+
+// Function is `fn(Parameters..., ...Rest) -> Result`.
+type Function struct {
+	Parameters []Type
+	// Required is the number of leading parameters without a default.
+	Required int
+	// Rest is the type of each argument a rest parameter collects, or nil if
+	// the function has none.
+	Rest   Type
+	Result Type
+	// Names holds the names of the parameters of a function literal, which
+	// keyword arguments refer to. It is nil for function types written as
+	// annotations.
+	Names []string
+}
+
+func (f *Function) String() string {
+	var params []string
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
+	}
+
+	out := "fn(" + strings.Join(params, ", ") + ")"
+	if f.Result != Any {
+		out += " -> " + f.Result.String()
+	}
+	return out
+}
+
+// Identical reports whether a and b are the same type.
+func Identical(a, b Type) bool {
+	return a.String() == b.String()
+}
+
+// AssignableTo reports whether a value of type from may be used where a
+// value of type to is expected. An int may be used as a float, since the
+// two mix freely in arithmetic.
+func AssignableTo(from, to Type) bool {
+	if from == Any || to == Any {
+		return true
+	}
+
+	switch to := to.(type) {
+	case *Basic:
+		return from == to || (from == Int && to == Float)
+	case *Array:
+		from, ok := from.(*Array)
+		return ok && AssignableTo(from.Element, to.Element)
+	case *Hash:
+		from, ok := from.(*Hash)
+		return ok && AssignableTo(from.Key, to.Key) && AssignableTo(from.Value, to.Value)
+	case *Function:
+		from, ok := from.(*Function)
+		if !ok || !accepts(from, len(to.Parameters)) || (to.Rest != nil && from.Rest == nil) {
+			return false
+		}
+		for i, p := range to.Parameters {
+			if !AssignableTo(p, from.parameter(i)) {
+				return false
+			}
+		}
+		if to.Rest != nil && !AssignableTo(to.Rest, from.Rest) {
+			return false
+		}
+		return AssignableTo(from.Result, to.Result)
+	}
+
+	return false
+}
+
+// accepts reports whether f may be called with n positional arguments.
+func accepts(f *Function, n int) bool {
+	return n >= f.Required && (n <= len(f.Parameters) || f.Rest != nil)
+}
+
+// parameter returns the type of the i-th positional argument of f.
+func (f *Function) parameter(i int) Type {
+	if i < len(f.Parameters) {
+		return f.Parameters[i]
+	}
+	if f.Rest != nil {
+		return f.Rest
+	}
+	return Any
+}
+
+// join returns the type of a value that is either of type a or b. A nil
+// type stands for no value at all, such as that of a block that always
+// returns.
+func join(a, b Type) Type {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if Identical(a, b) {
+		return a
+	}
+	return Any
+}
+
+func isNumber(t Type) bool {
+	return t == Int || t == Float
+}
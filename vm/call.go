@@ -0,0 +1,329 @@
+package vm
+
+import (
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/token"
+)
+
+// call calls the function below the argc arguments on top of the stack.
+// A call of a closure pushes a frame for it; any other call pushes its
+// result. When tail is set, a closure replaces the current frame instead.
+func (vm *VM) call(argc int, kwNames []string, kwValues []object.Object, tail bool) *object.Error {
+	fn := vm.stack[vm.sp-argc-1]
+
+	cl, ok := fn.(*object.Closure)
+	if !ok {
+		return vm.callValue(fn, argc, kwNames, kwValues)
+	}
+
+	if !tail {
+		return vm.enterClosure(cl, argc, kwNames, kwValues)
+	}
+
+	if err := vm.checkArguments(cl, argc, kwNames); err != nil {
+		return err
+	}
+
+	// move the callee and its arguments down to where the current call's
+	// are, and make the call in its place
+	f := vm.currentFrame()
+	start := vm.sp - argc - 1
+	copy(vm.stack[f.base-1:], vm.stack[start:vm.sp])
+	for i := f.base + argc; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = f.base + argc
+
+	native, original, pos := f.native, f.original, f.pos()
+	caller := f.cl
+	vm.framesIndex--
+	vm.state.Depth--
+
+	if err := vm.enterClosure(cl, argc, kwNames, kwValues); err != nil {
+		return err
+	}
+
+	next := vm.currentFrame()
+	next.native = native
+	next.original = original
+	next.caller = caller
+	next.tailPos = pos
+
+	return nil
+}
+
+// checkArguments returns the error a call of cl with argc positional
+// arguments and the given keyword arguments fails with before running.
+func (vm *VM) checkArguments(cl *object.Closure, argc int, kwNames []string) *object.Error {
+	fn := cl.Fn
+
+	if argc > fn.NumParameters && !fn.Rest {
+		return eval.NewError("wrong number of arguments. got=%d, want=%s", argc+len(kwNames), fn.Arity)
+	}
+
+	for i, kw := range kwNames {
+		p := parameterIndex(fn, kw)
+		if p < 0 {
+			return eval.NewError("unexpected keyword argument: %s", kw)
+		}
+		if p < argc || contains(kwNames[:i], kw) {
+			return eval.NewError("multiple values for argument: %s", kw)
+		}
+	}
+
+	return nil
+}
+
+// enterClosure pushes a frame for a call of cl with the argc arguments on
+// top of the stack, which become its first locals.
+func (vm *VM) enterClosure(cl *object.Closure, argc int, kwNames []string, kwValues []object.Object) *object.Error {
+	fn := cl.Fn
+
+	if err := eval.CheckDepth(vm.state); err != nil {
+		return err
+	}
+	if err := vm.checkArguments(cl, argc, kwNames); err != nil {
+		return err
+	}
+
+	base := vm.sp - argc
+	vm.reserve(base + fn.NumLocals + argc)
+
+	n := fn.NumParameters
+	var rest *object.Array
+	if fn.Rest {
+		extra := []object.Object{}
+		if argc > n {
+			extra = append(extra, vm.stack[base+n:base+argc]...)
+		}
+		rest = &object.Array{Elements: extra}
+	}
+
+	bound := argc
+	if bound > n {
+		bound = n
+	}
+	for i := base + bound; i < base+fn.NumLocals || i < base+argc; i++ {
+		vm.stack[i] = nil
+	}
+	if rest != nil {
+		vm.stack[base+n] = rest
+	}
+	for i, kw := range kwNames {
+		vm.stack[base+parameterIndex(fn, kw)] = kwValues[i]
+	}
+	for _, slot := range fn.Cells {
+		vm.stack[base+slot] = &object.Cell{Value: vm.stack[base+slot]}
+	}
+	vm.sp = base + fn.NumLocals
+
+	f := vm.pushFrame(cl, base)
+	f.args = argc + len(kwNames)
+	f.original = cl
+	if len(kwNames) > 0 {
+		f.ip = fn.Entries[0]
+	} else {
+		f.ip = fn.Entries[bound]
+	}
+
+	vm.state.Depth++
+
+	return nil
+}
+
+// leaveFrame pops f, the current frame, and its locals.
+func (vm *VM) leaveFrame(f *Frame) {
+	for i := f.base - 1; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = f.base - 1
+	vm.framesIndex--
+	if f.original != nil {
+		vm.state.Depth--
+	}
+}
+
+// callValue calls fn, a value other than a closure, and pushes its result.
+func (vm *VM) callValue(fn object.Object, argc int, kwNames []string, kwValues []object.Object) *object.Error {
+	args := make([]object.Object, argc)
+	copy(args, vm.stack[vm.sp-argc:vm.sp])
+	for i := vm.sp - argc - 1; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp -= argc + 1
+
+	var kwargs []eval.KeywordArg
+	for i, name := range kwNames {
+		kwargs = append(kwargs, eval.KeywordArg{Name: name, Value: kwValues[i]})
+	}
+
+	result := eval.Call(vm.state, fn, args, kwargs)
+
+	if !eval.IsError(result) {
+		vm.push(result)
+		return nil
+	}
+
+	err := result.(*object.Error)
+	switch fn.(type) {
+	case *object.Function, *object.Selector:
+		// record where the call eval added a frame for was made
+		if err.Pos.IsValid() {
+			err.Stack[len(err.Stack)-1].Pos = vm.currentFrame().pos()
+		}
+	}
+
+	return err
+}
+
+// raise unwinds the stack to the innermost handler of err. It returns err
+// and true if the error leaves the code the current run of the VM started
+// with.
+func (vm *VM) raise(err *object.Error) (object.Object, bool) {
+	if !err.Pos.IsValid() {
+		err.Pos = vm.currentFrame().pos()
+	}
+
+	for {
+		index := vm.framesIndex - 1
+		f := vm.frames[index]
+
+		if n := len(vm.handlers); n > 0 && vm.handlers[n-1].frame == index {
+			h := vm.handlers[n-1]
+			vm.handlers = vm.handlers[:n-1]
+
+			// try expressions let fatal errors pass
+			if err.Fatal {
+				continue
+			}
+
+			for i := h.sp; i < vm.sp; i++ {
+				vm.stack[i] = nil
+			}
+			vm.sp = h.sp
+			if h.catch {
+				err.Handled = true
+			}
+			vm.push(err)
+			f.ip = h.ip
+
+			return nil, false
+		}
+
+		if f.original != nil && err.Pos.IsValid() {
+			vm.addFrames(err, f)
+		}
+		vm.leaveFrame(f)
+
+		if f.native {
+			return err, true
+		}
+	}
+}
+
+// addFrames records the call of f, which err unwinds, in its stack.
+func (vm *VM) addFrames(err *object.Error, f *Frame) {
+	if f.caller != nil {
+		// the frames of the tail calls before the last one are gone
+		err.Stack = append(err.Stack, object.Frame{Function: name(f.cl), Pos: f.tailPos})
+		if f.caller != f.original {
+			err.Stack = append(err.Stack, object.Frame{Function: name(f.caller)})
+		}
+	}
+
+	err.Stack = append(err.Stack, object.Frame{Function: name(f.original), Pos: vm.callPos(f)})
+}
+
+// callPos returns the position of the call f runs, which is either the
+// current frame or the frame just left. It is unknown for calls made from
+// outside compiled code.
+func (vm *VM) callPos(f *Frame) token.Position {
+	if f.native {
+		return token.Position{}
+	}
+
+	i := vm.framesIndex - 1
+	if vm.frames[i] == f {
+		i--
+	}
+	return vm.frames[i].pos()
+}
+
+// lookup returns the value of the first bound binding of chain and its
+// index, or nil and -1 if none is bound.
+func (vm *VM) lookup(f *Frame, chain []object.Binding) (object.Object, int) {
+	for i, b := range chain {
+		var val object.Object
+		switch b.Scope {
+		case object.GlobalBinding:
+			val = vm.globals[b.Index]
+		case object.LocalBinding:
+			val = vm.stack[f.base+b.Index]
+		case object.CellBinding:
+			if cell, ok := vm.stack[f.base+b.Index].(*object.Cell); ok {
+				val = cell.Value
+			}
+		case object.FreeBinding:
+			val = f.cl.Free[b.Index].Value
+		case object.BuiltinBinding:
+			val = vm.constants[b.Index]
+		}
+		if val != nil {
+			return val, i
+		}
+	}
+
+	return nil, -1
+}
+
+func (vm *VM) store(f *Frame, b object.Binding, val object.Object) {
+	switch b.Scope {
+	case object.GlobalBinding:
+		vm.globals[b.Index] = val
+	case object.LocalBinding:
+		vm.stack[f.base+b.Index] = val
+	case object.CellBinding:
+		vm.stack[f.base+b.Index].(*object.Cell).Value = val
+	case object.FreeBinding:
+		f.cl.Free[b.Index].Value = val
+	}
+}
+
+func (vm *VM) bindingName(f *Frame, b object.Binding) string {
+	switch b.Scope {
+	case object.GlobalBinding:
+		return vm.globalNames[b.Index]
+	case object.FreeBinding:
+		return f.cl.Fn.Free[b.Index]
+	default:
+		return f.cl.Fn.Locals[b.Index]
+	}
+}
+
+// bound reports whether local i of f, a parameter, was given a value.
+func (vm *VM) bound(f *Frame, i int) bool {
+	val := vm.stack[f.base+i]
+	if cell, ok := val.(*object.Cell); ok {
+		return cell.Value != nil
+	}
+	return val != nil
+}
+
+func parameterIndex(fn *object.CompiledFunction, name string) int {
+	for i, p := range fn.Parameters {
+		if p == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package vm
+
+import (
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/token"
+)
+
+// Frame is a running call of a closure, or the top level of the program.
+type Frame struct {
+	cl *object.Closure
+	ip int
+	// op is the offset of the instruction being run, which errors are
+	// reported at.
+	op int
+	// base is the stack index of the first local.
+	base int
+
+	// args is the number of arguments the call was given.
+	args int
+	// native is set for calls made from outside compiled code, which
+	// return to their caller rather than to the frame below.
+	native bool
+
+	// original is the closure called, which tail calls made since replaced.
+	original *object.Closure
+	// caller and tailPos describe the last tail call made in place of the
+	// call, if any.
+	caller  *object.Closure
+	tailPos token.Position
+}
+
+func (f *Frame) Instructions() []byte {
+	return f.cl.Fn.Instructions
+}
+
+// pos returns the position of the instruction being run.
+func (f *Frame) pos() token.Position {
+	return f.cl.Fn.Pos(f.op)
+}
+
+// name returns the name calls of cl are shown as in stack traces.
+func name(cl *object.Closure) string {
+	if cl.Fn.Name == "" {
+		return "<anonymous>"
+	}
+	return cl.Fn.Name
+}
+
+// iterator is the state of a for-in loop, kept in a hidden local.
+type iterator struct {
+	next func() (object.Object, bool)
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// handler is the catch or finally clause of a try expression being run.
+type handler struct {
+	// frame is the index of the frame running the try expression.
+	frame int
+	sp    int
+	ip    int
+	catch bool
+}
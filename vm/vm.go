@@ -0,0 +1,768 @@
+package vm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/threeaccents/digolang/ast"
+	"github.com/threeaccents/digolang/code"
+	"github.com/threeaccents/digolang/compiler"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/object"
+)
+
+const (
+	StackSize   = 2048
+	MaxFrames   = 1024
+	GlobalsSize = 65536
+)
+
+var (
+	NULL  = eval.NULL
+	TRUE  = eval.TRUE
+	FALSE = eval.FALSE
+)
+
+// VM runs compiled programs. Operations on values are shared with eval, so
+// a program behaves the same whichever of them runs it.
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+	imports     []*ast.ImportStatement
+	main        *object.CompiledFunction
+
+	// env provides the module the program belongs to and the state of the
+	// evaluation.
+	env   *object.Environment
+	state *object.State
+	// limited is set when steps must be counted.
+	limited bool
+
+	stack []object.Object
+	sp    int // the next free slot; the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	handlers []handler
+}
+
+// New returns a VM running bytecode as the program of env.
+func New(bytecode *compiler.Bytecode, env *object.Environment) *VM {
+	return NewWithGlobals(bytecode, env, nil)
+}
+
+// NewWithGlobals returns a VM whose globals start out as those of a
+// previous run, as returned by Globals. The closures of that run keep
+// seeing the same variables as long as globals has room for all of them,
+// which a slice of GlobalsSize usually does.
+func NewWithGlobals(bytecode *compiler.Bytecode, env *object.Environment, globals []object.Object) *VM {
+	if len(globals) < len(bytecode.Globals) {
+		g := make([]object.Object, len(bytecode.Globals))
+		copy(g, globals)
+		globals = g
+	}
+
+	frames := make([]*Frame, 0, MaxFrames)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		globalNames: bytecode.Globals,
+		imports:     bytecode.Imports,
+		main:        bytecode.Main,
+		env:         env,
+		state:       env.State(),
+		stack:       make([]object.Object, StackSize),
+		frames:      frames,
+	}
+}
+
+// Globals returns the values of the global variables.
+func (vm *VM) Globals() []object.Object {
+	return vm.globals
+}
+
+// Run runs the program and returns its value, like eval.Eval.
+func (vm *VM) Run() object.Object {
+	vm.limited = vm.state.MaxSteps > 0 || vm.state.Context != nil
+	vm.sp = 0
+	vm.framesIndex = 0
+	vm.handlers = vm.handlers[:0]
+
+	main := &object.Closure{Fn: vm.main, Runtime: vm}
+	vm.push(main)
+
+	f := vm.pushFrame(main, vm.sp)
+	f.native = true
+	vm.reserve(f.base + main.Fn.NumLocals)
+	for i := f.base; i < f.base+main.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = f.base + main.Fn.NumLocals
+
+	return vm.run()
+}
+
+// RunContext runs the program like Run, but stops once ctx is done or the
+// program exceeds limits, like eval.EvalContext.
+func (vm *VM) RunContext(ctx context.Context, limits eval.Limits) (object.Object, error) {
+	return eval.RunContext(ctx, vm.env, limits, vm.Run)
+}
+
+// CallClosure calls cl on behalf of code outside the VM, such as a builtin
+// method given a callback.
+func (vm *VM) CallClosure(cl *object.Closure, args []object.Object, kwNames []string, kwValues []object.Object) object.Object {
+	sp := vm.sp
+
+	vm.push(cl)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+
+	if err := vm.enterClosure(cl, len(args), kwNames, kwValues); err != nil {
+		vm.sp = sp
+		return err
+	}
+	vm.frames[vm.framesIndex-1].native = true
+
+	return vm.run()
+}
+
+func (vm *VM) push(o object.Object) {
+	if vm.sp == len(vm.stack) {
+		vm.reserve(vm.sp + 1)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	o := vm.stack[vm.sp]
+	vm.stack[vm.sp] = nil
+	return o
+}
+
+// reserve grows the stack to at least n slots.
+func (vm *VM) reserve(n int) {
+	if n <= len(vm.stack) {
+		return
+	}
+
+	size := 2 * len(vm.stack)
+	for size < n {
+		size *= 2
+	}
+
+	stack := make([]object.Object, size)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) pushFrame(cl *object.Closure, base int) *Frame {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, &Frame{})
+	}
+
+	f := vm.frames[vm.framesIndex]
+	*f = Frame{cl: cl, base: base}
+	vm.framesIndex++
+
+	return f
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) run() object.Object {
+	f := vm.currentFrame()
+	ins := f.cl.Fn.Instructions
+
+	for {
+		var raised *object.Error
+
+		if vm.limited {
+			if err := eval.Step(vm.state); err != nil {
+				f.op = f.ip
+				raised = err
+				goto raise
+			}
+		}
+
+		f.op = f.ip
+
+		switch op := code.Opcode(ins[f.ip]); op {
+		case code.OpConstant:
+			vm.push(vm.constants[code.ReadUint32(ins[f.ip+1:])])
+			f.ip += 5
+		case code.OpNull:
+			vm.push(NULL)
+			f.ip++
+		case code.OpTrue:
+			vm.push(TRUE)
+			f.ip++
+		case code.OpFalse:
+			vm.push(FALSE)
+			f.ip++
+		case code.OpNil:
+			vm.push(nil)
+			f.ip++
+		case code.OpPop:
+			vm.pop()
+			f.ip++
+		case code.OpSwap:
+			vm.stack[vm.sp-1], vm.stack[vm.sp-2] = vm.stack[vm.sp-2], vm.stack[vm.sp-1]
+			f.ip++
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpLess, code.OpGreater,
+			code.OpLessEqual, code.OpGreaterEqual:
+			f.ip++
+			raised = vm.binary(op)
+
+		case code.OpInfix:
+			operator := code.Operators[ins[f.ip+1]]
+			f.ip += 2
+			right := vm.pop()
+			left := vm.pop()
+			raised = vm.pushResult(eval.Infix(operator, left, right), true)
+
+		case code.OpPrefix:
+			operator := code.Operators[ins[f.ip+1]]
+			f.ip += 2
+			raised = vm.pushResult(eval.Prefix(operator, vm.pop()), false)
+
+		case code.OpJump:
+			f.ip = int(code.ReadUint32(ins[f.ip+1:]))
+
+		case code.OpBranch:
+			kind := ins[f.ip+1]
+			target := int(code.ReadUint32(ins[f.ip+2:]))
+			f.ip += 6
+
+			condition := vm.pop()
+			if condition.Type() != object.BOOLEAN_OBJ {
+				construct := "if("
+				if kind == code.BranchWhile {
+					construct = "while("
+				}
+				raised = eval.NewError("unknown operator: %s%s%s", construct, condition.Type(), ")")
+				break
+			}
+			if condition != TRUE {
+				f.ip = target
+			}
+
+		case code.OpShortCircuit:
+			operator := code.Operators[ins[f.ip+1]]
+			target := int(code.ReadUint32(ins[f.ip+2:]))
+			f.ip += 6
+			if eval.ShortCircuits(operator, vm.stack[vm.sp-1]) {
+				f.ip = target
+			}
+
+		case code.OpGetGlobal:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			val := vm.globals[i]
+			if val == nil {
+				raised = notFound(vm.globalNames[i])
+				break
+			}
+			vm.push(val)
+		case code.OpSetGlobal:
+			vm.globals[code.ReadUint32(ins[f.ip+1:])] = vm.pop()
+			f.ip += 5
+		case code.OpAssignGlobal:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			if vm.globals[i] == nil {
+				raised = undeclared(vm.globalNames[i])
+				break
+			}
+			vm.globals[i] = vm.pop()
+
+		case code.OpGetLocal:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			val := vm.stack[f.base+i]
+			if val == nil {
+				raised = notFound(f.cl.Fn.Locals[i])
+				break
+			}
+			vm.push(val)
+		case code.OpSetLocal:
+			vm.stack[f.base+int(code.ReadUint32(ins[f.ip+1:]))] = vm.pop()
+			f.ip += 5
+		case code.OpAssignLocal:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			if vm.stack[f.base+i] == nil {
+				raised = undeclared(f.cl.Fn.Locals[i])
+				break
+			}
+			vm.stack[f.base+i] = vm.pop()
+
+		case code.OpGetCell:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			cell, _ := vm.stack[f.base+i].(*object.Cell)
+			if cell == nil || cell.Value == nil {
+				raised = notFound(f.cl.Fn.Locals[i])
+				break
+			}
+			vm.push(cell.Value)
+		case code.OpSetCell:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			vm.stack[f.base+i].(*object.Cell).Value = vm.pop()
+		case code.OpAssignCell:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			cell, _ := vm.stack[f.base+i].(*object.Cell)
+			if cell == nil || cell.Value == nil {
+				raised = undeclared(f.cl.Fn.Locals[i])
+				break
+			}
+			cell.Value = vm.pop()
+
+		case code.OpGetFree:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			val := f.cl.Free[i].Value
+			if val == nil {
+				raised = notFound(f.cl.Fn.Free[i])
+				break
+			}
+			vm.push(val)
+		case code.OpSetFree:
+			f.cl.Free[code.ReadUint32(ins[f.ip+1:])].Value = vm.pop()
+			f.ip += 5
+		case code.OpAssignFree:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			if f.cl.Free[i].Value == nil {
+				raised = undeclared(f.cl.Fn.Free[i])
+				break
+			}
+			f.cl.Free[i].Value = vm.pop()
+
+		case code.OpGetName:
+			chain := f.cl.Fn.Chains[code.ReadUint32(ins[f.ip+1:])]
+			f.ip += 5
+			val, _ := vm.lookup(f, chain)
+			if val == nil {
+				raised = notFound(vm.bindingName(f, chain[0]))
+				break
+			}
+			vm.push(val)
+		case code.OpAssignName:
+			chain := f.cl.Fn.Chains[code.ReadUint32(ins[f.ip+1:])]
+			f.ip += 5
+			_, b := vm.lookup(f, chain)
+			if b < 0 {
+				raised = undeclared(vm.bindingName(f, chain[0]))
+				break
+			}
+			vm.store(f, chain[b], vm.pop())
+
+		case code.OpNewCell:
+			vm.stack[f.base+int(code.ReadUint32(ins[f.ip+1:]))] = &object.Cell{}
+			f.ip += 5
+		case code.OpClearLocal:
+			vm.stack[f.base+int(code.ReadUint32(ins[f.ip+1:]))] = nil
+			f.ip += 5
+
+		case code.OpArray:
+			n := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			raised = vm.pushResult(&object.Array{Elements: elements}, true)
+		case code.OpAppend:
+			f.ip++
+			val := vm.pop()
+			arr := vm.stack[vm.sp-1].(*object.Array)
+			arr.Elements = append(arr.Elements, val)
+		case code.OpExtend:
+			f.ip++
+			items, err := eval.Spread(vm.state, vm.pop())
+			if err != nil {
+				raised = err
+				break
+			}
+			arr := vm.stack[vm.sp-1].(*object.Array)
+			arr.Elements = append(arr.Elements, items...)
+		case code.OpHash:
+			n := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair, n)}
+			for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+				key := vm.stack[i]
+				hash.Set(key.(object.Hashable).HashKey(), object.HashPair{Key: key, Value: vm.stack[i+1]})
+			}
+			vm.sp -= 2 * n
+			raised = vm.pushResult(hash, true)
+		case code.OpHashKey:
+			f.ip++
+			if key := vm.stack[vm.sp-1]; !isHashable(key) {
+				raised = eval.NewError("unusable as hash key: %s", key.Type())
+			}
+
+		case code.OpIndex:
+			f.ip++
+			index := vm.pop()
+			left := vm.pop()
+			if arr, ok := left.(*object.Array); ok {
+				if i, ok := index.(*object.Integer); ok && i.Value >= 0 && i.Value < int64(len(arr.Elements)) {
+					vm.push(arr.Elements[i.Value])
+					break
+				}
+			}
+			raised = vm.pushResult(eval.Index(left, index), false)
+		case code.OpSlice:
+			flags := ins[f.ip+1]
+			f.ip += 2
+			var low, high object.Object
+			if flags&code.SliceHigh != 0 {
+				high = vm.pop()
+			}
+			if flags&code.SliceLow != 0 {
+				low = vm.pop()
+			}
+			raised = vm.pushResult(eval.Slice(vm.pop(), low, high), true)
+		case code.OpSelect:
+			name := vm.constants[code.ReadUint32(ins[f.ip+1:])].(*object.String).Value
+			f.ip += 5
+			raised = vm.pushResult(eval.Select(vm.pop(), name), false)
+		case code.OpSetIndex:
+			operator := assignOperator(ins[f.ip+1])
+			f.ip += 2
+			index := vm.pop()
+			left := vm.pop()
+			if err, ok := eval.SetIndex(operator, left, index, vm.pop()).(*object.Error); ok {
+				raised = err
+			}
+		case code.OpSetField:
+			operator := assignOperator(ins[f.ip+1])
+			name := vm.constants[code.ReadUint32(ins[f.ip+2:])].(*object.String).Value
+			f.ip += 6
+			left := vm.pop()
+			if err, ok := eval.SetField(operator, left, name, vm.pop()).(*object.Error); ok {
+				raised = err
+			}
+		case code.OpInterpolate:
+			strs := vm.constants[code.ReadUint32(ins[f.ip+1:])].(*object.Array).Elements
+			n := int(code.ReadUint32(ins[f.ip+5:]))
+			f.ip += 9
+			raised = vm.pushResult(vm.interpolate(strs, n), true)
+
+		case code.OpCall, code.OpTailCall:
+			argc := int(code.ReadUint16(ins[f.ip+1:]))
+			f.ip += 3
+			raised = vm.call(argc, nil, nil, op == code.OpTailCall)
+			f = vm.currentFrame()
+			ins = f.cl.Fn.Instructions
+		case code.OpCallEx:
+			argc := int(code.ReadUint16(ins[f.ip+1:]))
+			names := vm.constants[code.ReadUint32(ins[f.ip+3:])].(*object.Array).Elements
+			flags := ins[f.ip+7]
+			f.ip += 8
+
+			kwNames := make([]string, len(names))
+			kwValues := make([]object.Object, len(names))
+			for i, n := range names {
+				kwNames[i] = n.(*object.String).Value
+			}
+			copy(kwValues, vm.stack[vm.sp-len(names):vm.sp])
+			vm.sp -= len(names)
+
+			if flags&code.CallSpread != 0 {
+				args := vm.pop().(*object.Array).Elements
+				for _, arg := range args {
+					vm.push(arg)
+				}
+				argc = len(args)
+			}
+
+			raised = vm.call(argc, kwNames, kwValues, flags&code.CallTail != 0)
+			f = vm.currentFrame()
+			ins = f.cl.Fn.Instructions
+
+		case code.OpReturnValue:
+			result := vm.pop()
+			vm.leaveFrame(f)
+			if f.native {
+				return result
+			}
+			vm.push(result)
+			f = vm.currentFrame()
+			ins = f.cl.Fn.Instructions
+
+		case code.OpClosure:
+			fn := vm.constants[code.ReadUint32(ins[f.ip+1:])].(*object.CompiledFunction)
+			f.ip += 5
+			free := make([]*object.Cell, len(fn.Captures))
+			for i, c := range fn.Captures {
+				if c.Local {
+					free[i] = vm.stack[f.base+c.Index].(*object.Cell)
+				} else {
+					free[i] = f.cl.Free[c.Index]
+				}
+			}
+			vm.push(&object.Closure{Fn: fn, Free: free, Runtime: vm})
+
+		case code.OpCheckArgument:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			if vm.bound(f, i) {
+				break
+			}
+
+			// the call itself failed, rather than the function
+			err := eval.NewError("wrong number of arguments. got=%d, want=%s", f.args, f.cl.Fn.Arity)
+			vm.leaveFrame(f)
+			switch {
+			case f.caller != nil:
+				err.Pos = f.tailPos
+				if f.caller != f.original {
+					err.Stack = append(err.Stack, object.Frame{Function: name(f.caller)})
+				}
+				err.Stack = append(err.Stack, object.Frame{Function: name(f.original), Pos: vm.callPos(f)})
+			case !f.native:
+				err.Pos = vm.currentFrame().pos()
+			}
+			if f.native {
+				return err
+			}
+			raised = err
+			f = vm.currentFrame()
+			ins = f.cl.Fn.Instructions
+		case code.OpJumpIfBound:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			target := int(code.ReadUint32(ins[f.ip+5:]))
+			f.ip += 9
+			if vm.bound(f, i) {
+				f.ip = target
+			}
+
+		case code.OpIter:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			next, err := eval.Iterate(vm.pop())
+			if err != nil {
+				raised = err
+				break
+			}
+			vm.stack[f.base+i] = &iterator{next: next}
+		case code.OpIterNext:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			target := int(code.ReadUint32(ins[f.ip+5:]))
+			f.ip += 9
+			item, ok := vm.stack[f.base+i].(*iterator).next()
+			if !ok {
+				vm.stack[f.base+i] = nil
+				f.ip = target
+				break
+			}
+			vm.push(item)
+
+		case code.OpTry:
+			vm.handlers = append(vm.handlers, handler{
+				frame: vm.framesIndex - 1,
+				sp:    vm.sp,
+				ip:    int(code.ReadUint32(ins[f.ip+1:])),
+				catch: ins[f.ip+5] == 1,
+			})
+			f.ip += 6
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			f.ip++
+		case code.OpThrow:
+			f.ip++
+			raised = eval.Throw(vm.pop())
+		case code.OpRethrow:
+			i := int(code.ReadUint32(ins[f.ip+1:]))
+			f.ip += 5
+			raised = vm.stack[f.base+i].(*object.Error)
+		case code.OpError:
+			msg := vm.constants[code.ReadUint32(ins[f.ip+1:])].(*object.String).Value
+			f.ip += 5
+			raised = eval.NewError("%s", msg)
+		case code.OpImport:
+			node := vm.imports[code.ReadUint32(ins[f.ip+1:])]
+			f.ip += 5
+			values, err := eval.Import(node, vm.env)
+			if err != nil {
+				raised = err
+				break
+			}
+			for _, v := range values {
+				vm.push(v)
+			}
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			panic("vm: unknown instruction " + def.Name)
+		}
+
+		if raised == nil {
+			continue
+		}
+
+	raise:
+		if result, done := vm.raise(raised); done {
+			return result
+		}
+		f = vm.currentFrame()
+		ins = f.cl.Fn.Instructions
+	}
+}
+
+// binary applies an arithmetic or comparison operator, taking a shortcut
+// for integers.
+func (vm *VM) binary(op code.Opcode) *object.Error {
+	right := vm.stack[vm.sp-1]
+	left := vm.stack[vm.sp-2]
+
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			var result object.Object
+			switch op {
+			case code.OpAdd:
+				result = integer(l.Value + r.Value)
+			case code.OpSub:
+				result = integer(l.Value - r.Value)
+			case code.OpMul:
+				result = integer(l.Value * r.Value)
+			case code.OpEqual:
+				result = nativeBool(l.Value == r.Value)
+			case code.OpNotEqual:
+				result = nativeBool(l.Value != r.Value)
+			case code.OpLess:
+				result = nativeBool(l.Value < r.Value)
+			case code.OpGreater:
+				result = nativeBool(l.Value > r.Value)
+			case code.OpLessEqual:
+				result = nativeBool(l.Value <= r.Value)
+			case code.OpGreaterEqual:
+				result = nativeBool(l.Value >= r.Value)
+			}
+			if result != nil {
+				vm.sp--
+				vm.stack[vm.sp] = nil
+				vm.stack[vm.sp-1] = result
+				return nil
+			}
+		}
+	}
+
+	vm.sp -= 2
+	return vm.pushResult(eval.Infix(binaryOperators[op], left, right), true)
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLess:         "<",
+	code.OpGreater:      ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+// smallIntegers holds the integers most arithmetic results are, so the VM
+// need not allocate them. Integers are never modified once created.
+var smallIntegers [1280]object.Integer
+
+func init() {
+	for i := range smallIntegers {
+		smallIntegers[i].Value = int64(i) - 256
+	}
+}
+
+func integer(v int64) *object.Integer {
+	if v >= -256 && v < int64(len(smallIntegers))-256 {
+		return &smallIntegers[v+256]
+	}
+	return &object.Integer{Value: v}
+}
+
+func nativeBool(b bool) *object.Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// pushResult pushes the result of an operation, or returns it if it is an
+// error. New values count against the allocation limit.
+func (vm *VM) pushResult(result object.Object, allocated bool) *object.Error {
+	if eval.IsError(result) {
+		return result.(*object.Error)
+	}
+	if allocated && vm.state.MaxAlloc > 0 {
+		if err := eval.Allocate(vm.state, result); err != nil {
+			return err
+		}
+	}
+	vm.push(result)
+	return nil
+}
+
+func (vm *VM) interpolate(strs []object.Object, n int) object.Object {
+	var out strings.Builder
+
+	values := vm.stack[vm.sp-n : vm.sp]
+	for i, s := range strs {
+		out.WriteString(s.(*object.String).Value)
+		if i >= n {
+			break
+		}
+
+		switch v := values[i].(type) {
+		case *object.String:
+			out.WriteString(v.Value)
+		case nil:
+			out.WriteString(NULL.Inspect())
+		default:
+			out.WriteString(v.Inspect())
+		}
+	}
+
+	for i := range values {
+		values[i] = nil
+	}
+	vm.sp -= n
+
+	return &object.String{Value: out.String()}
+}
+
+func isHashable(obj object.Object) bool {
+	_, ok := obj.(object.Hashable)
+	return ok
+}
+
+// assignOperator decodes the operator of an index or field assignment.
+func assignOperator(operand byte) string {
+	op := code.Operators[operand]
+	if op == "=" {
+		return op
+	}
+	return op + "="
+}
+
+func notFound(name string) *object.Error {
+	return eval.NewError("identifier not found: %s", name)
+}
+
+func undeclared(name string) *object.Error {
+	return eval.NewError("cannot assign to undeclared identifier: %s", name)
+}
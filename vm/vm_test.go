@@ -0,0 +1,168 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/threeaccents/digolang/compiler"
+	"github.com/threeaccents/digolang/eval"
+	"github.com/threeaccents/digolang/lexer"
+	"github.com/threeaccents/digolang/object"
+	"github.com/threeaccents/digolang/parser"
+)
+
+// The evaluator tests check the VM runs programs the way eval does; these
+// cover what only the VM has.
+
+func compile(t testing.TB, input string) *compiler.Bytecode {
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return c.Bytecode()
+}
+
+func TestGlobalsAcrossRuns(t *testing.T) {
+	globals := compiler.NewGlobalTable()
+	var constants []object.Object
+	values := make([]object.Object, GlobalsSize)
+	env := object.NewEnvironment()
+
+	lines := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 2; x", 2},
+		{"let double = fn(n) { n * x }; double(3)", 6},
+		{"x = 10; double(3)", 30},
+		{"[1, 2].map(double)[1]", 20},
+	}
+
+	for _, tt := range lines {
+		c := compiler.NewWithState(globals, constants)
+		if err := c.Compile(parser.New(lexer.New(tt.input)).ParseProgram()); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		bytecode := c.Bytecode()
+		constants = bytecode.Constants
+
+		vm := NewWithGlobals(bytecode, env, values)
+		result := vm.Run()
+		values = vm.Globals()
+
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			t.Errorf("%q: result is not Integer. got=%T (%+v)", tt.input, result, result)
+			continue
+		}
+		if integer.Value != tt.expected {
+			t.Errorf("%q: wrong result. got=%d, want=%d", tt.input, integer.Value, tt.expected)
+		}
+	}
+}
+
+func TestTailCallsReuseFrames(t *testing.T) {
+	input := `
+	let loop = fn(n, acc) {
+		if (n == 0) { return acc }
+		return loop(n - 1, acc + 1)
+	};
+	loop(100000, 0)`
+
+	vm := New(compile(t, input), object.NewEnvironment())
+	result := vm.Run()
+
+	integer, ok := result.(*object.Integer)
+	if !ok {
+		t.Fatalf("result is not Integer. got=%T (%+v)", result, result)
+	}
+	if integer.Value != 100000 {
+		t.Errorf("wrong result. got=%d", integer.Value)
+	}
+	if len(vm.frames) > 2 {
+		t.Errorf("wrong number of frames. got=%d", len(vm.frames))
+	}
+}
+
+// Programs past the range of 16-bit operands: jumps over more than 64 KB of
+// code, more than 65535 constants and array elements, and calls with more
+// arguments than fit in a byte or in the operand itself, and more than 65535
+// global and local variables.
+func TestLargePrograms(t *testing.T) {
+	list := func(n int) string {
+		items := make([]string, n)
+		for i := range items {
+			items[i] = fmt.Sprint(i)
+		}
+		return strings.Join(items, ", ")
+	}
+
+	var body strings.Builder
+	for i := 0; i < 6000; i++ {
+		fmt.Fprintf(&body, "x = x + %d;\n", i%7)
+	}
+
+	var variables strings.Builder
+	for i := 0; i < 70000; i++ {
+		fmt.Fprintf(&variables, "let v%d = %d;\n", i, i)
+	}
+	variables.WriteString("v0 + v65536 + v69999\n")
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 0; if (x == 0) {\n" + body.String() + "}; x", 17997},
+		{"let x = 0; for (i in 0..<2) {\n" + body.String() + "}; x", 35994},
+		{"let a = [" + list(70000) + "]; len(a) + a[69999]", 139999},
+		{"let f = fn(...xs) { len(xs) + xs[299] }; f(" + list(300) + ")", 599},
+		{"let f = fn(a, b, ...xs) { a + b + len(xs) }; f(" + list(70000) + ")", 69999},
+		{variables.String(), 135535},
+		{"let f = fn() {\n" + variables.String() + "}; f()", 135535},
+	}
+
+	for i, tt := range tests {
+		bytecode := compile(t, tt.input)
+		if i == 0 && len(bytecode.Main.Instructions) <= 0xFFFF {
+			t.Fatalf("program is not larger than 64 KB. got=%d bytes", len(bytecode.Main.Instructions))
+		}
+
+		result := New(bytecode, object.NewEnvironment()).Run()
+
+		integer, ok := result.(*object.Integer)
+		if !ok {
+			t.Errorf("test %d: result is not Integer. got=%T (%+v)", i, result, result)
+			continue
+		}
+		if integer.Value != tt.expected {
+			t.Errorf("test %d: wrong result. got=%d, want=%d", i, integer.Value, tt.expected)
+		}
+	}
+}
+
+const fibInput = `
+let fib = fn(n) {
+	if (n < 2) { return n }
+	fib(n - 1) + fib(n - 2)
+};
+fib(20)`
+
+func BenchmarkFibVM(b *testing.B) {
+	bytecode := compile(b, fibInput)
+
+	for i := 0; i < b.N; i++ {
+		New(bytecode, object.NewEnvironment()).Run()
+	}
+}
+
+func BenchmarkFibEval(b *testing.B) {
+	program := parser.New(lexer.New(fibInput)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		eval.Eval(program, object.NewEnvironment())
+	}
+}